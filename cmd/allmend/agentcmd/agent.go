@@ -3,7 +3,9 @@ package agentcmd
 import (
 	"fmt"
 
+	"github.com/SUSE/allmend/pkg/agent"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var AgentCmd = &cobra.Command{
@@ -14,3 +16,26 @@ var AgentCmd = &cobra.Command{
 		fmt.Println("Please specify a subcommand like 'list'.")
 	},
 }
+
+// findAgent loads the agent with the given name from the configured agent paths.
+func findAgent(name string) (*agent.Agent, error) {
+	paths := viper.GetStringSlice("agent_paths")
+	agents, err := agent.Get(paths)
+	if err != nil {
+		return nil, err
+	}
+	a, ok := agents[name]
+	if !ok {
+		return nil, fmt.Errorf("Agent '%s' not found in paths: %v\n", name, paths)
+	}
+	return a, nil
+}
+
+// completeAgentNames completes the first argument with the names of the agents.
+func completeAgentNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	paths := viper.GetStringSlice("agent_paths")
+	return agent.ListNames(paths), cobra.ShellCompDirectiveNoFileComp
+}
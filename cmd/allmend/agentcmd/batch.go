@@ -0,0 +1,168 @@
+package agentcmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+
+	"github.com/SUSE/allmend/pkg/agent"
+	"github.com/SUSE/allmend/pkg/provider"
+	"github.com/SUSE/allmend/pkg/runner"
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch [agent name]",
+	Short: "Run an agent for every record of a JSONL file",
+	Long: `Run an agent for every record of a JSONL input file.
+
+Every line of the input is a JSON object with the prompt and optionally an
+id and the mission variables of the record:
+
+  {"id": "host1", "prompt": "Check the host.", "variables": {"target": "10.0.0.1"}}
+
+The line number is used as id if it is missing. For every record a JSON line
+with the id, text, tool calls, usage, duration and error is appended to the
+output. Running the batch again with the same output resumes it: records
+which already succeeded are skipped, failed ones are run again.
+
+Tool calls can't be approved interactively, use --yes or --approval-policy
+for tools which are not read-only. The exit code is 1 if any record failed.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeAgentNames,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		targetAgent, err := findAgent(args[0])
+		if err != nil {
+			return setupError(err)
+		}
+		vars, _ := cmd.Flags().GetStringArray("var")
+		defaults, err := agent.ParseVarAssignments(vars)
+		if err != nil {
+			return setupError(err)
+		}
+
+		inputPath, _ := cmd.Flags().GetString("input")
+		outputPath, _ := cmd.Flags().GetString("output")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		if concurrency < 1 {
+			return setupError(fmt.Errorf("Error: The concurrency has to be at least 1.\n"))
+		}
+
+		in, err := os.Open(inputPath)
+		if err != nil {
+			return setupError(fmt.Errorf("Error opening input: %v\n", err))
+		}
+		records, err := runner.ReadRecords(in)
+		in.Close()
+		if err != nil {
+			return setupError(fmt.Errorf("Error reading input %s: %v\n", inputPath, err))
+		}
+		done, err := runner.Completed(outputPath)
+		if err != nil {
+			return setupError(fmt.Errorf("Error reading previous results: %v\n", err))
+		}
+		out, err := openResults(outputPath)
+		if err != nil {
+			return setupError(err)
+		}
+		defer out.Close()
+
+		setup, err := setupRun(ctx, cmd, targetAgent, os.Stderr)
+		if err != nil {
+			return err
+		}
+		defer setup.Close()
+		// Nobody can be asked during a batch run
+		setup.Approver.Prompt = nil
+
+		batch := &runner.Batch{
+			Concurrency: concurrency,
+			Output:      out,
+			Skip:        done,
+			Run: func(ctx context.Context, rec *runner.Record) (*runner.Result, error) {
+				values := make(map[string]string)
+				maps.Copy(values, defaults)
+				maps.Copy(values, rec.Values())
+				instance, _, err := instantiateAgent(targetAgent, values)
+				if err != nil {
+					return nil, err
+				}
+				adkAgent, err := setup.newADKAgent(instance)
+				if err != nil {
+					return nil, err
+				}
+				return runner.Run(ctx, runner.Config{
+					Agent:    adkAgent,
+					Message:  genai.NewContentFromText(rec.Prompt, genai.RoleUser),
+					ModelKey: provider.ServedByKey,
+				})
+			},
+			Progress: func(res *runner.RecordResult) {
+				status := "ok"
+				if res.Error != "" {
+					status = "FAILED: " + res.Error
+				}
+				fmt.Fprintf(os.Stderr, "%s: %s (%d ms)\n", res.ID, status, res.DurationMS)
+			},
+		}
+		if len(done) > 0 {
+			fmt.Fprintf(os.Stderr, "Resuming batch, %d records already succeeded.\n", len(done))
+		}
+		summary, err := batch.Execute(ctx, records)
+		fmt.Fprintln(os.Stderr, summary)
+		if err != nil {
+			return fmt.Errorf("Error writing results: %v\n", err)
+		}
+		switch {
+		case summary.Interrupted > 0:
+			return &ExitError{Code: ExitInterrupted, Err: fmt.Errorf("Batch interrupted, run it again to resume.")}
+		case summary.Failed > 0:
+			return &ExitError{Code: ExitRunFailed, Err: fmt.Errorf("%d of %d records failed.", summary.Failed, summary.Total)}
+		}
+		return nil
+	},
+}
+
+// openResults opens the results file for appending. A line left
+// unterminated by an interrupted run is ended first.
+func openResults(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening output: %v\n", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("Error opening output: %v\n", err)
+	}
+	if info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err != nil && err != io.EOF {
+			f.Close()
+			return nil, fmt.Errorf("Error reading output: %v\n", err)
+		}
+		if last[0] != '\n' {
+			f.WriteString("\n")
+		}
+	}
+	return f, nil
+}
+
+func init() {
+	addRunFlags(batchCmd)
+	batchCmd.Flags().StringArray("var", nil, "Set a mission variable for all records as key=value (can be repeated)")
+	batchCmd.Flags().StringP("input", "i", "", "JSONL file with the records")
+	batchCmd.Flags().StringP("output", "o", "", "JSONL file the results are appended to")
+	batchCmd.Flags().IntP("concurrency", "c", 4, "Number of records run at the same time")
+	batchCmd.MarkFlagRequired("input")
+	batchCmd.MarkFlagRequired("output")
+	AgentCmd.AddCommand(batchCmd)
+}
@@ -0,0 +1,24 @@
+package agentcmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenResults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("{\"id\": \"1\"}\n{\"id\": \"2\", \"te"), 0644))
+
+	f, err := openResults(path)
+	require.NoError(t, err)
+	f.WriteString("{\"id\": \"2\"}\n")
+	f.Close()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"id\": \"1\"}\n{\"id\": \"2\", \"te\n{\"id\": \"2\"}\n", string(data))
+}
@@ -0,0 +1,438 @@
+package agentcmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SUSE/allmend/cmd/allmend/modelcmd"
+	"github.com/SUSE/allmend/cmd/allmend/providercmd"
+	"github.com/SUSE/allmend/cmd/allmend/toolcmd"
+	"github.com/SUSE/allmend/pkg/agent"
+	"github.com/SUSE/allmend/pkg/model"
+	"github.com/SUSE/allmend/pkg/provider"
+	"github.com/SUSE/allmend/pkg/tools"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	adkagent "google.golang.org/adk/agent"
+	"google.golang.org/adk/agent/llmagent"
+	adkmodel "google.golang.org/adk/model"
+)
+
+// Exit codes of allmend for agent runs.
+const (
+	// ExitRunFailed is used when the model or a tool failed during the run.
+	ExitRunFailed = 1
+	// ExitSetupFailed is used when the agent couldn't be prepared, like
+	// a missing agent, variable, model or required tool.
+	ExitSetupFailed = 2
+	// ExitInterrupted is used when the run was interrupted.
+	ExitInterrupted = 130
+)
+
+// ExitError makes allmend exit with Code instead of 1.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string { return e.Err.Error() }
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// ExitCode returns the exit code of the process.
+func (e *ExitError) ExitCode() int { return e.Code }
+
+func setupError(err error) error {
+	return &ExitError{Code: ExitSetupFailed, Err: err}
+}
+
+// addRunFlags adds the flags used by setupRun to cmd.
+func addRunFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("model", "m", "", "Model to use instead of the model of the agent or the default model, as name or provider/name")
+	cmd.RegisterFlagCompletionFunc("model", modelcmd.CompleteModelRefs)
+	cmd.Flags().StringArray("param", nil, "Set a generation parameter as key=value, like temperature=0.2 (can be repeated)")
+	cmd.Flags().BoolP("yes", "y", false, "Approve all tool calls which are not denied by the approval policy")
+	cmd.Flags().String("approval-policy", "", "YAML file with allow/deny lists deciding tool calls without asking")
+	cmd.Flags().String("approval-log", "", "Append a JSON record of every tool call decision to this file")
+}
+
+// runSetup holds everything created to run an agent.
+type runSetup struct {
+	Agent        *agent.Agent
+	ModelName    string
+	ProviderName string
+	LLM          adkmodel.LLM
+	Toolbox      *tools.Toolbox
+	Approver     *tools.Approver
+}
+
+// ModelID returns the provider-qualified name of the model, or the name of
+// the group or alias serving the run first.
+func (s *runSetup) ModelID() string {
+	if s.ProviderName == "" {
+		return s.ModelName
+	}
+	return s.ProviderName + "/" + s.ModelName
+}
+
+// Close ends the tool connections and closes the approval log.
+func (s *runSetup) Close() {
+	if s.Toolbox != nil {
+		s.Toolbox.Close()
+	}
+	if s.Approver != nil {
+		if c, ok := s.Approver.Log.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
+
+// instantiateAgent substitutes the variables in the agent definition and
+// composes the system instruction.
+func instantiateAgent(a *agent.Agent, values map[string]string) (*agent.Agent, string, error) {
+	instance, err := a.WithVariables(values)
+	if err != nil {
+		return nil, "", setupError(fmt.Errorf("Agent '%s': %v\n", a.Name, err))
+	}
+	return instance, instance.SystemInstruction(), nil
+}
+
+// newADKAgent creates the ADK agent for instance, an agent returned by
+// instantiateAgent, with the model and tools of the setup.
+func (s *runSetup) newADKAgent(instance *agent.Agent) (adkagent.Agent, error) {
+	// The instruction is handed over by a provider, so that ADK doesn't
+	// try to substitute {placeholders} in the manifest or mission.
+	instruction := instance.SystemInstruction()
+	adkAgent, err := llmagent.New(llmagent.Config{
+		Model: s.LLM,
+		InstructionProvider: func(adkagent.ReadonlyContext) (string, error) {
+			return instruction, nil
+		},
+		Name:                instance.Name,
+		Tools:               s.Toolbox.Tools,
+		BeforeToolCallbacks: []llmagent.BeforeToolCallback{s.Approver.BeforeToolCallback()},
+	})
+	if err != nil {
+		return nil, setupError(fmt.Errorf("Error creating ADK agent: %v\n", err))
+	}
+	return adkAgent, nil
+}
+
+// setupRun prepares running the agent a: it loads the model and its
+// provider, connects the tools and sets up the approval of tool calls from
+// the flags of cmd. Diagnostics are written to diag. The caller has to Close
+// the returned runSetup.
+func setupRun(ctx context.Context, cmd *cobra.Command, a *agent.Agent, diag io.Writer) (*runSetup, error) {
+	setup := &runSetup{Agent: a}
+
+	// 1. Load model
+	modelsPath, err := modelcmd.GetModelsFilePath()
+	if err != nil {
+		return nil, setupError(fmt.Errorf("Error determining models file path: %v\n", err))
+	}
+	modelStore, err := model.Load(modelsPath)
+	if err != nil {
+		return nil, setupError(fmt.Errorf("Error loading models: %v\n", err))
+	}
+
+	flagModel, _ := cmd.Flags().GetString("model")
+	modelRef := selectModel(flagModel, a, modelStore)
+	if modelRef == "" {
+		return nil, setupError(fmt.Errorf("Error: No model specified and no default model configured."))
+	}
+
+	key, m, err := modelStore.Resolve(modelRef)
+	if err != nil {
+		return nil, setupError(fmt.Errorf("Error: %v\n", err))
+	}
+	var agentFallback []string
+	if flagModel == "" {
+		agentFallback = a.Fallback
+	}
+	chain, err := modelChain(modelStore, key, m, agentFallback)
+	if err != nil {
+		return nil, setupError(fmt.Errorf("Error: %v\n", err))
+	}
+	setup.ModelName = chain[0].Name
+	setup.ProviderName = chain[0].Provider
+	params, err := runParams(cmd, a)
+	if err != nil {
+		return nil, setupError(fmt.Errorf("Error: %v\n", err))
+	}
+
+	// 2. Load providers
+	providersPath, err := providercmd.GetProvidersFilePath()
+	if err != nil {
+		return nil, setupError(fmt.Errorf("Error determining providers file path: %v\n", err))
+	}
+	providerStore, err := provider.Load(providersPath)
+	if err != nil {
+		return nil, setupError(fmt.Errorf("Error loading providers: %v\n", err))
+	}
+
+	// 3. Create ADK LLMs with their generation parameters, wrapped to retry
+	// and fall back to the next model
+	factory := &llmFactory{
+		models:        modelStore,
+		providers:     providerStore,
+		providersPath: providersPath,
+		params:        params,
+		diag:          diag,
+	}
+	fallback := &provider.Fallback{Log: diag}
+	if fallback.Retries, fallback.Backoff, err = retryPolicy(); err != nil {
+		return nil, setupError(fmt.Errorf("Error: %v\n", err))
+	}
+	for _, m := range chain {
+		llm, err := factory.create(ctx, m)
+		if err != nil {
+			return nil, setupError(fmt.Errorf("Error: %v\n", err))
+		}
+		fallback.Models = append(fallback.Models, provider.Candidate{Name: m.ID(), LLM: llm})
+	}
+	setup.LLM = fallback
+
+	// 4. Connect the MCP tools of the agent
+	toolsPath, err := toolcmd.GetToolsFilePath()
+	if err != nil {
+		return nil, setupError(fmt.Errorf("Error determining tools file path: %v\n", err))
+	}
+	toolStore, err := tools.Load(toolsPath)
+	if err != nil {
+		return nil, setupError(fmt.Errorf("Error loading tools: %v\n", err))
+	}
+	setup.Toolbox, err = tools.Resolve(ctx, toolStore, a.Tools)
+	if err != nil {
+		return nil, setupError(fmt.Errorf("Error resolving tools of agent '%s': %v\n", a.Name, err))
+	}
+	for name, v := range setup.Toolbox.Verified {
+		fmt.Fprintf(diag, "Tool '%s' verified: %s signed by %s\n", name, v.Artifact, v.Key)
+	}
+
+	setup.Approver, err = newApprover(cmd, setup.Toolbox)
+	if err != nil {
+		setup.Close()
+		return nil, setupError(err)
+	}
+	return setup, nil
+}
+
+// selectModel returns the reference of the model to run the agent a with. The
+// --model flag wins over the model preferred by the agent, which wins over
+// the default model of the store. The default_model setting of allmend.conf
+// is the last resort.
+func selectModel(flagModel string, a *agent.Agent, store *model.Store) string {
+	switch {
+	case flagModel != "":
+		return flagModel
+	case a.Model != "":
+		return a.Model
+	case store.DefaultModel != "":
+		return store.DefaultModel
+	}
+	return viper.GetString("default_model")
+}
+
+// modelChain returns the models to try in order for the model m stored
+// under key: m itself, then its fallback models, recursively, and last the
+// models of extra. Aliases are resolved to the models they stand for, every
+// other model needs a provider or has to be a group.
+func modelChain(store *model.Store, key string, m model.Model, extra []string) ([]model.Model, error) {
+	var chain []model.Model
+	seen := make(map[string]bool)
+	var add func(key string, m model.Model) error
+	add = func(key string, m model.Model) error {
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+		if m.Provider == "" && m.Group == nil {
+			return fmt.Errorf("model '%s' has no provider, use 'allmend model alias' or 'allmend model group' to name other models", m.ID())
+		}
+		chain = append(chain, m)
+		for _, ref := range m.Fallback {
+			k, fm, err := store.Resolve(ref)
+			if err != nil {
+				return fmt.Errorf("fallback of model '%s': %w", m.ID(), err)
+			}
+			if err := add(k, fm); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := add(key, m); err != nil {
+		return nil, err
+	}
+	for _, ref := range extra {
+		k, fm, err := store.Resolve(ref)
+		if err != nil {
+			return nil, fmt.Errorf("fallback of the agent: %w", err)
+		}
+		if err := add(k, fm); err != nil {
+			return nil, err
+		}
+	}
+	return chain, nil
+}
+
+// llmFactory creates the LLMs of the models of a chain.
+type llmFactory struct {
+	models        *model.Store
+	providers     *provider.Store
+	providersPath string
+	// params of the run override the ones of the models
+	params model.Params
+	diag   io.Writer
+}
+
+// create returns the LLM of the model m, or a provider.Group spreading the
+// requests over the members if m is a group.
+func (f *llmFactory) create(ctx context.Context, m model.Model) (adkmodel.LLM, error) {
+	if m.Group != nil {
+		return f.createGroup(ctx, m)
+	}
+	params, err := f.paramsOf(m)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := f.providers.Items[m.Provider]
+	if !ok {
+		return nil, fmt.Errorf("Provider '%s' (for model '%s') not found in %s", m.Provider, m.Name, f.providersPath)
+	}
+	llm, err := p.CreateLLM(ctx, m.Name)
+	if err != nil {
+		return nil, fmt.Errorf("creating LLM of model '%s': %v", m.ID(), err)
+	}
+	if n := params.ContextWindow; n > 0 {
+		if s, ok := llm.(provider.ContextWindowSetter); ok {
+			s.SetContextWindow(n)
+		} else {
+			fmt.Fprintf(f.diag, "Warning: Provider '%s' doesn't support choosing the context window, context_window is ignored\n", m.Provider)
+		}
+	}
+	return provider.WithParams(llm, params), nil
+}
+
+// paramsOf returns the generation parameters of the model m: the ones of
+// its config, overridden by the ones of the run.
+func (f *llmFactory) paramsOf(m model.Model) (model.Params, error) {
+	params, err := m.Params()
+	if err != nil {
+		return params, err
+	}
+	return params.Merge(f.params), nil
+}
+
+// createGroup creates the LLMs of the members of the group m. Members have
+// to be models with a provider, or aliases of such models.
+func (f *llmFactory) createGroup(ctx context.Context, m model.Model) (adkmodel.LLM, error) {
+	group := &provider.Group{}
+	switch m.Group.Strategy {
+	case "", model.RoundRobin, model.Weighted:
+	default:
+		return nil, fmt.Errorf("group '%s': unknown strategy '%s', use %s or %s", m.ID(), m.Group.Strategy, model.RoundRobin, model.Weighted)
+	}
+	for _, member := range m.Group.Members {
+		_, mm, err := f.models.Resolve(member.Model)
+		if err != nil {
+			return nil, fmt.Errorf("group '%s': %w", m.ID(), err)
+		}
+		if mm.Provider == "" {
+			return nil, fmt.Errorf("group '%s': model '%s' has no provider", m.ID(), mm.ID())
+		}
+		llm, err := f.create(ctx, mm)
+		if err != nil {
+			return nil, err
+		}
+		group.Members = append(group.Members, provider.Candidate{Name: mm.ID(), LLM: llm})
+		if m.Group.Strategy == model.Weighted {
+			group.Weights = append(group.Weights, member.Weight)
+		}
+	}
+	if len(group.Members) == 0 {
+		return nil, fmt.Errorf("group '%s' has no members", m.ID())
+	}
+	return group, nil
+}
+
+// retryPolicy returns how often a model is retried after transient errors
+// and the delay before the first retry, from model_retries and
+// model_retry_backoff of allmend.conf. By default a model is retried twice,
+// after one and two seconds. Negative values are rejected.
+func retryPolicy() (int, time.Duration, error) {
+	retries, backoff := 2, time.Second
+	if viper.IsSet("model_retries") {
+		retries = viper.GetInt("model_retries")
+		if retries < 0 {
+			return 0, 0, fmt.Errorf("model_retries must not be negative, got %d", retries)
+		}
+	}
+	if viper.IsSet("model_retry_backoff") {
+		backoff = viper.GetDuration("model_retry_backoff")
+		if backoff < 0 {
+			return 0, 0, fmt.Errorf("model_retry_backoff must not be negative, got %s", backoff)
+		}
+	}
+	return retries, backoff, nil
+}
+
+// runParams merges the generation parameters of the agent a and the
+// --param flags of cmd, in this order of precedence. They override the
+// parameters in the config of every model of the run.
+func runParams(cmd *cobra.Command, a *agent.Agent) (model.Params, error) {
+	params, err := a.Params()
+	if err != nil {
+		return params, err
+	}
+	flags, _ := cmd.Flags().GetStringArray("param")
+	values := make(map[string]interface{}, len(flags))
+	for _, f := range flags {
+		key, value, ok := strings.Cut(f, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return params, fmt.Errorf("invalid parameter %q, expected key=value", f)
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	flagParams, err := model.ParseParams(values)
+	if err != nil {
+		return params, err
+	}
+	return params.Merge(flagParams), nil
+}
+
+// newApprover creates the approval gate for tool calls from the flags of cmd.
+// The user is asked on the console if stdin is a terminal.
+func newApprover(cmd *cobra.Command, toolbox *tools.Toolbox) (*tools.Approver, error) {
+	approver := &tools.Approver{
+		Specs: toolbox.Specs,
+	}
+	if isTerminal(os.Stdin) {
+		approver.Prompt = tools.ConsolePrompt(os.Stdin, os.Stderr)
+	}
+	approver.Yes, _ = cmd.Flags().GetBool("yes")
+	if policyFile, _ := cmd.Flags().GetString("approval-policy"); policyFile != "" {
+		policy, err := tools.LoadPolicy(policyFile)
+		if err != nil {
+			return nil, err
+		}
+		approver.Policy = policy
+	}
+	if logFile, _ := cmd.Flags().GetString("approval-log"); logFile != "" {
+		f, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("Error opening approval log: %v\n", err)
+		}
+		approver.Log = f
+	}
+	return approver, nil
+}
+
+// isTerminal reports whether f is a character device like a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,135 @@
+package agentcmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SUSE/allmend/pkg/agent"
+	"github.com/SUSE/allmend/pkg/model"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectModel(t *testing.T) {
+	viper.Set("default_model", "legacy")
+	defer viper.Set("default_model", "")
+
+	store := &model.Store{DefaultModel: "granite4:3b"}
+	preferring := &agent.Agent{Name: "a", Model: "qwen3"}
+	plain := &agent.Agent{Name: "b"}
+
+	assert.Equal(t, "gpt-oss", selectModel("gpt-oss", preferring, store))
+	assert.Equal(t, "qwen3", selectModel("", preferring, store))
+	assert.Equal(t, "granite4:3b", selectModel("", plain, store))
+	assert.Equal(t, "legacy", selectModel("", plain, &model.Store{}))
+}
+
+func TestRetryPolicy(t *testing.T) {
+	defer viper.Set("model_retries", 2)
+	defer viper.Set("model_retry_backoff", time.Second)
+
+	viper.Set("model_retries", 0)
+	viper.Set("model_retry_backoff", "500ms")
+	retries, backoff, err := retryPolicy()
+	require.NoError(t, err)
+	assert.Equal(t, 0, retries)
+	assert.Equal(t, 500*time.Millisecond, backoff)
+
+	viper.Set("model_retries", -1)
+	_, _, err = retryPolicy()
+	assert.EqualError(t, err, "model_retries must not be negative, got -1")
+
+	viper.Set("model_retries", 1)
+	viper.Set("model_retry_backoff", "-1s")
+	_, _, err = retryPolicy()
+	assert.EqualError(t, err, "model_retry_backoff must not be negative, got -1s")
+}
+
+func TestGenerationParams(t *testing.T) {
+	cmd := &cobra.Command{}
+	addRunFlags(cmd)
+	require.NoError(t, cmd.ParseFlags([]string{"--param", "seed=42", "--param", "temperature=0.1"}))
+
+	m := model.Model{Name: "granite4:3b", Provider: "local", Config: map[string]interface{}{
+		"temperature": 0.7, "seed": 1, "context_window": 8192,
+	}}
+	a := &agent.Agent{Name: "a", Parameters: map[string]string{"temperature": "0.3", "max_output_tokens": "512"}}
+
+	params, err := runParams(cmd, a)
+	require.NoError(t, err)
+	factory := &llmFactory{params: params}
+	params, err = factory.paramsOf(m)
+	require.NoError(t, err)
+	assert.Equal(t, 0.1, *params.Temperature)
+	assert.Equal(t, 42, *params.Seed)
+	assert.Equal(t, 512, params.MaxOutputTokens)
+	assert.Equal(t, 8192, params.ContextWindow)
+
+	// A fallback model starts from its own config
+	params, err = factory.paramsOf(model.Model{Name: "gpt-4o", Provider: "cloud", Config: map[string]interface{}{"top_p": 0.9}})
+	require.NoError(t, err)
+	assert.Equal(t, 0.9, *params.TopP)
+	assert.Equal(t, 0, params.ContextWindow)
+	assert.Equal(t, 0.1, *params.Temperature)
+
+	require.NoError(t, cmd.ParseFlags([]string{"--param", "temperature"}))
+	_, err = runParams(cmd, a)
+	assert.ErrorContains(t, err, `invalid parameter "temperature", expected key=value`)
+}
+
+func TestModelChain(t *testing.T) {
+	store := &model.Store{Items: map[string]model.Model{
+		"granite4:3b":   {Name: "granite4:3b", Provider: "local", Fallback: []string{"gpu-box/qwen3"}},
+		"gpu-box/qwen3": {Name: "qwen3", Provider: "gpu-box", Fallback: []string{"granite4:3b"}},
+		"gpt-oss:20b":   {Name: "gpt-oss:20b", Provider: "local"},
+		"fast":          {Name: "fast", Alias: "granite4:3b"},
+		"broken":        {Name: "broken", Provider: "local", Fallback: []string{"missing"}},
+		"empty":         {Name: "empty"},
+		"coder":         {Name: "coder", Alias: "fast"},
+		"spread":        {Name: "spread", Group: &model.Group{Members: []model.GroupMember{{Model: "coder"}, {Model: "gpt-oss:20b"}}}, Fallback: []string{"coder"}},
+		"pool":          {Name: "pool", Group: &model.Group{Members: []model.GroupMember{{Model: "spread"}}}},
+	}}
+	ids := func(chain []model.Model) []string {
+		var ids []string
+		for _, m := range chain {
+			ids = append(ids, m.ID())
+		}
+		return ids
+	}
+
+	key, m, err := store.Resolve("fast")
+	require.NoError(t, err)
+	chain, err := modelChain(store, key, m, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"local/granite4:3b", "gpu-box/qwen3"}, ids(chain))
+
+	chain, err = modelChain(store, "gpt-oss:20b", store.Items["gpt-oss:20b"], []string{"qwen3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"local/gpt-oss:20b", "gpu-box/qwen3", "local/granite4:3b"}, ids(chain))
+
+	// Aliases stand for their models, groups are part of the chain
+	chain, err = modelChain(store, "gpt-oss:20b", store.Items["gpt-oss:20b"], []string{"coder"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"local/gpt-oss:20b", "local/granite4:3b", "gpu-box/qwen3"}, ids(chain))
+	chain, err = modelChain(store, "spread", store.Items["spread"], nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spread", "local/granite4:3b", "gpu-box/qwen3"}, ids(chain))
+
+	// Members of groups need a provider
+	factory := &llmFactory{models: store}
+	_, err = factory.create(context.Background(), store.Items["pool"])
+	assert.EqualError(t, err, "group 'pool': model 'spread' has no provider")
+	_, err = factory.create(context.Background(), model.Model{Name: "odd", Group: &model.Group{Strategy: "random"}})
+	assert.EqualError(t, err, "group 'odd': unknown strategy 'random', use round-robin or weighted")
+
+	_, err = modelChain(store, "broken", store.Items["broken"], nil)
+	assert.ErrorContains(t, err, "fallback of model 'local/broken': model 'missing' not found")
+	// Models without provider which are neither alias nor group are rejected
+	_, err = modelChain(store, "empty", store.Items["empty"], nil)
+	assert.EqualError(t, err, "model 'empty' has no provider, use 'allmend model alias' or 'allmend model group' to name other models")
+	_, err = modelChain(store, "gpt-oss:20b", store.Items["gpt-oss:20b"], []string{"empty"})
+	assert.ErrorContains(t, err, "model 'empty' has no provider")
+}
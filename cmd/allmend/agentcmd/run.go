@@ -1,113 +1,341 @@
 package agentcmd
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"strings"
 
-	"github.com/SUSE/allmend/cmd/allmend/modelcmd"
-	"github.com/SUSE/allmend/cmd/allmend/providercmd"
 	"github.com/SUSE/allmend/pkg/agent"
-	"github.com/SUSE/allmend/pkg/model"
 	"github.com/SUSE/allmend/pkg/provider"
+	"github.com/SUSE/allmend/pkg/runner"
+	"github.com/SUSE/allmend/pkg/session"
+	"github.com/SUSE/allmend/pkg/tools"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 	adkagent "google.golang.org/adk/agent"
-	"google.golang.org/adk/agent/llmagent"
-	"google.golang.org/adk/cmd/launcher"
-	"google.golang.org/adk/cmd/launcher/console"
+	adksession "google.golang.org/adk/session"
+	"google.golang.org/genai"
 )
 
 var runCmd = &cobra.Command{
 	Use:   "run [agent name]",
-	Short: "Run an agent in interactive mode",
-	Args:  cobra.ExactArgs(1),
+	Short: "Run an agent in interactive mode or for a single prompt",
+	Long: `Run an agent in interactive mode.
+
+The conversation is stored as a session, --session continues the session
+with the given ID or starts a new one with it.
+
+With --prompt, or when the input is piped to stdin, the agent answers the
+prompt and exits. The answer is streamed to stdout and all diagnostics go to
+stderr. The exit code is 0 on success, 1 if the run failed, 2 if the agent
+couldn't be set up and 130 if it was interrupted.
+
+Transient errors of the model, like an unreachable server, are retried
+as configured with model_retries and model_retry_backoff in allmend.conf.
+If the model keeps failing, the fallback models of the model and those of
+the agent are tried in order.
+
+Generation parameters like temperature, top_p, top_k, max_output_tokens,
+seed, context_window, stop and thinking_budget are taken from the config
+of the model, then from the %Parameters section of the agent and last from
+--param, later ones overriding earlier ones. Fallback models and members
+of groups start from their own config.
+
+Images and text files are sent along with the prompt using --attach, e.g.
+
+  allmend agent run Describer -p "What does this diagram show?" --attach diagram.png`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeAgentNames,
+	SilenceUsage:      true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		agentName := args[0]
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 		defer stop()
 
 		// 1. Load agent
-		paths := viper.GetStringSlice("agent_paths")
-		agents, err := agent.Get(paths)
-		var targetAgent *agent.Agent
-		for _, a := range agents {
-			if a.Name == agentName {
-				targetAgent = a
-				break
-			}
+		targetAgent, err := findAgent(agentName)
+		if err != nil {
+			return setupError(err)
 		}
-		if targetAgent == nil {
-			return fmt.Errorf("Agent '%s' not found in paths: %v\n", agentName, paths)
+		vars, _ := cmd.Flags().GetStringArray("var")
+		values, err := agent.ParseVarAssignments(vars)
+		if err != nil {
+			return setupError(err)
 		}
-		// 2. Load model
-		modelName := viper.GetString("default_model")
-		if m, _ := cmd.Flags().GetString("model"); m != "" {
-			modelName = m
+		instance, instruction, err := instantiateAgent(targetAgent, values)
+		if err != nil {
+			return err
 		}
-		if modelName == "" {
-			return fmt.Errorf("Error: No model specified and no default model configured.")
+		if showPrompt, _ := cmd.Flags().GetBool("show-prompt"); showPrompt {
+			fmt.Println(instruction)
+			return nil
 		}
 
-		modelsPath, err := modelcmd.GetModelsFilePath()
+		output, _ := cmd.Flags().GetString("output")
+		if output != "text" && output != "json" {
+			return setupError(fmt.Errorf("Error: Unknown output format '%s', use text or json.\n", output))
+		}
+		prompt, oneShot, fromStdin, err := readPrompt(cmd, os.Stdin)
 		if err != nil {
-			return fmt.Errorf("Error determining models file path: %v\n", err)
+			return setupError(err)
 		}
-		modelStore, err := model.Load(modelsPath)
+		files, _ := cmd.Flags().GetStringArray("attach")
+		attachments, err := loadAttachments(files)
 		if err != nil {
-			return fmt.Errorf("Error loading models: %v\n", err)
+			return setupError(err)
 		}
 
-		m, ok := modelStore.Items[modelName]
-		if !ok {
-			return fmt.Errorf("Error: Model '%s' not found in %s\n", modelName, modelsPath)
+		// 2. Build the agent
+		diag := io.Writer(os.Stdout)
+		if oneShot {
+			diag = os.Stderr
 		}
-
-		// 3. Load provider
-		providersPath, err := providercmd.GetProvidersFilePath()
+		setup, err := setupRun(ctx, cmd, instance, diag)
 		if err != nil {
-			return fmt.Errorf("Error determining providers file path: %v\n", err)
+			return err
 		}
-		providerStore, err := provider.Load(providersPath)
+		defer setup.Close()
+		adkAgent, err := setup.newADKAgent(instance)
 		if err != nil {
-			return fmt.Errorf("Error loading providers: %v\n", err)
+			return err
 		}
 
-		p, ok := providerStore.Items[m.Provider]
-		if !ok {
-			return fmt.Errorf("Error: Provider '%s' (for model '%s') not found in %s\n", m.Provider, modelName, providersPath)
+		// 3. Open the session
+		sessionID, _ := cmd.Flags().GetString("session")
+		sessions, sessionID, err := openSession(ctx, targetAgent.Name, sessionID)
+		if err != nil {
+			return err
+		}
+		run := &session.Run{
+			Agent:       instance.Name,
+			Model:       setup.ModelID(),
+			Provider:    setup.ProviderName,
+			Instruction: instruction,
 		}
+		if instance.Meta != nil {
+			run.AgentVersion = instance.Meta.Version
+		}
+		if err := sessions.RecordRun(sessionID, run); err != nil {
+			return setupError(fmt.Errorf("Error recording run: %v\n", err))
+		}
+		if transcript, _ := cmd.Flags().GetString("transcript"); transcript != "" {
+			defer writeTranscript(transcript, sessionID)
+		}
+		conv := &conversation{Agent: adkAgent, Sessions: sessions, SessionID: sessionID, Attachments: attachments}
+
+		if oneShot {
+			if fromStdin {
+				// stdin is consumed, nobody can be asked
+				setup.Approver.Prompt = nil
+			}
+			fmt.Fprintf(os.Stderr, "Session: %s\n", sessionID)
+			return runOnce(ctx, setup, conv, prompt, output)
+		}
+
+		// 4. Converse
+		fmt.Printf("Running agent '%s' using model '%s'...\n", agentName, setup.ModelID())
+		fmt.Printf("Session: %s (resume with --session %s)\n", sessionID, sessionID)
+		in := bufio.NewReader(os.Stdin)
+		// The approval shares the input, so that no line is lost
+		if setup.Approver.Prompt != nil {
+			setup.Approver.Prompt = tools.ConsolePrompt(in, os.Stderr)
+		}
+		return conv.interact(ctx, in, os.Stdout)
+	},
+}
+
+// openSession returns the session service of the agent and the ID of the
+// session to use. The session is created if it doesn't exist yet.
+func openSession(ctx context.Context, agentName, id string) (*session.FileService, string, error) {
+	store := session.NewStore(GetSessionsDir())
+	sessions := store.Service(agentName)
+	if id != "" {
+		stored, err := store.Load(id)
+		switch {
+		case errors.Is(err, session.ErrNotFound):
+		case err != nil:
+			return nil, "", setupError(fmt.Errorf("Error loading session: %v\n", err))
+		case stored.Agent != agentName:
+			return nil, "", setupError(fmt.Errorf("Error: Session '%s' belongs to agent '%s'.\n", id, stored.Agent))
+		default:
+			return sessions, id, nil
+		}
+	}
+	created, err := sessions.Create(ctx, &adksession.CreateRequest{
+		AppName:   runner.AppName,
+		UserID:    runner.UserID,
+		SessionID: id,
+	})
+	if err != nil {
+		return nil, "", setupError(fmt.Errorf("Error creating session: %v\n", err))
+	}
+	return sessions, created.Session.ID(), nil
+}
 
-		// 4. Create ADK LLM
-		llm, err := p.CreateLLM(ctx, modelName)
+// writeTranscript exports the session id to file, as Markdown if the file
+// ends with .md and as JSON otherwise. Failures are reported on stderr.
+func writeTranscript(file, id string) {
+	sess, err := session.NewStore(GetSessionsDir()).Load(id)
+	if err == nil {
+		var f *os.File
+		if f, err = os.Create(file); err == nil {
+			err = exportSession(f, sess, transcriptFormat(file))
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing transcript: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Transcript written to %s\n", file)
+}
+
+// conversation is an agent talking in a stored session.
+type conversation struct {
+	Agent     adkagent.Agent
+	Sessions  adksession.Service
+	SessionID string
+	// Attachments are sent along with the next message.
+	Attachments []*genai.Part
+}
+
+// turn sends the message to the agent and streams the answer to stream.
+func (c *conversation) turn(ctx context.Context, message string, stream io.Writer) (*runner.Result, error) {
+	content := genai.NewContentFromText(message, genai.RoleUser)
+	content.Parts = append(content.Parts, c.Attachments...)
+	c.Attachments = nil
+	return runner.Run(ctx, runner.Config{
+		Agent:          c.Agent,
+		SessionService: c.Sessions,
+		SessionID:      c.SessionID,
+		Message:        content,
+		Stream:         stream,
+		ModelKey:       provider.ServedByKey,
+	})
+}
+
+// loadAttachments reads the files attached with --attach.
+func loadAttachments(files []string) ([]*genai.Part, error) {
+	parts := make([]*genai.Part, 0, len(files))
+	for _, file := range files {
+		part, err := runner.LoadAttachment(file)
+		if err != nil {
+			return nil, fmt.Errorf("Error attaching file: %v\n", err)
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// interact reads messages line by line from in and writes the answers to
+// out until the input ends or the context is cancelled.
+func (c *conversation) interact(ctx context.Context, in *bufio.Reader, out io.Writer) error {
+	for {
+		fmt.Fprint(out, "\nUser -> ")
+		line, err := in.ReadString('\n')
+		if strings.TrimSpace(line) != "" {
+			fmt.Fprint(out, "\nAgent -> ")
+			if _, runErr := c.turn(ctx, line, out); runErr != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				fmt.Fprintf(os.Stderr, "\nError: %v\n", runErr)
+			}
+			fmt.Fprintln(out)
+		}
+		if err == io.EOF {
+			fmt.Fprintln(out)
+			return nil
+		}
 		if err != nil {
-			return fmt.Errorf("Error creating LLM: %v\n", err)
+			return fmt.Errorf("Error reading input: %v\n", err)
 		}
+	}
+}
 
-		// 5. Create ADK Agent
-		adkAgent, err := llmagent.New(llmagent.Config{
-			Model:       llm,
-			Instruction: targetAgent.Manifest.Content,
-			Name:        targetAgent.Name,
-		})
+// readPrompt determines the prompt of a one-shot run. It is taken from the
+// --prompt flag, or from stdin if the flag is "-" or stdin isn't a terminal.
+// oneShot is false if the agent should run interactively.
+func readPrompt(cmd *cobra.Command, stdin *os.File) (prompt string, oneShot, fromStdin bool, err error) {
+	prompt, _ = cmd.Flags().GetString("prompt")
+	if prompt == "" && (cmd.Flags().Changed("prompt") || isTerminal(stdin)) {
+		if cmd.Flags().Changed("prompt") {
+			return "", false, false, fmt.Errorf("Error: The prompt is empty.\n")
+		}
+		return "", false, false, nil
+	}
+	if prompt == "" || prompt == "-" {
+		data, err := io.ReadAll(stdin)
 		if err != nil {
-			return fmt.Errorf("Error creating ADK agent: %v\n", err)
+			return "", false, false, fmt.Errorf("Error reading prompt from stdin: %v\n", err)
 		}
+		prompt, fromStdin = strings.TrimSpace(string(data)), true
+		if prompt == "" {
+			return "", false, false, fmt.Errorf("Error: The prompt read from stdin is empty.\n")
+		}
+	}
+	return prompt, true, fromStdin, nil
+}
 
-		// 6. Run launcher
-		fmt.Printf("Running agent '%s' using model '%s'...\n", agentName, modelName)
-		agentLauncher := console.NewLauncher()
-		if err := agentLauncher.Run(ctx, &launcher.Config{
-			AgentLoader: adkagent.NewSingleLoader(adkAgent),
-		}); err != nil {
-			return fmt.Errorf("Error running agent: %v\n", err)
+// oneShotResult is the JSON output of a one-shot run. The model of the
+// result is the one which served the turn.
+type oneShotResult struct {
+	Agent string `json:"agent"`
+	*runner.Result
+}
+
+// runOnce sends prompt to the agent and prints the answer in the output
+// format.
+func runOnce(ctx context.Context, setup *runSetup, conv *conversation, prompt, output string) error {
+	var stream io.Writer
+	if output == "text" {
+		stream = os.Stdout
+	}
+	res, err := conv.turn(ctx, prompt, stream)
+	if res.Model == "" {
+		// No model answered, name the first one of the chain
+		res.Model = setup.ModelID()
+	}
+
+	switch output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if encErr := enc.Encode(oneShotResult{Agent: setup.Agent.Name, Result: res}); encErr != nil && err == nil {
+			err = encErr
 		}
-		return nil
-	},
+	default:
+		if res.Text != "" && !strings.HasSuffix(res.Text, "\n") {
+			fmt.Println()
+		}
+	}
+
+	if err != nil {
+		code := ExitRunFailed
+		if errors.Is(err, context.Canceled) {
+			code = ExitInterrupted
+		}
+		return &ExitError{Code: code, Err: fmt.Errorf("Error running agent: %v\n", err)}
+	}
+	return nil
 }
 
 func init() {
-	runCmd.Flags().StringP("model", "m", "", "Model to use for the agent")
+	addRunFlags(runCmd)
+	runCmd.Flags().StringArray("var", nil, "Set a mission variable as key=value (can be repeated)")
+	runCmd.Flags().Bool("show-prompt", false, "Print the system instruction sent to the model and exit")
+	runCmd.Flags().StringP("prompt", "p", "", "Answer this prompt and exit, - reads it from stdin")
+	runCmd.Flags().StringP("output", "o", "text", "Output format of a single prompt run: text or json")
+	runCmd.Flags().StringArray("attach", nil, "Attach an image or text file to the prompt, or to the first message in interactive mode (can be repeated)")
+	runCmd.Flags().StringP("session", "s", "", "Continue the session with this ID, or start a new one with it")
+	runCmd.RegisterFlagCompletionFunc("session", completeSessionIDs)
+	runCmd.Flags().String("transcript", "", "Write the transcript of the session to this file when the run ends, as Markdown for .md files and JSON otherwise")
 	AgentCmd.AddCommand(runCmd)
 }
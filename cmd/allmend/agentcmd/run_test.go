@@ -0,0 +1,265 @@
+package agentcmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/SUSE/allmend/internal/testenv"
+	"github.com/SUSE/allmend/pkg/agent"
+	"github.com/SUSE/allmend/pkg/provider"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/adk/agent/llmagent"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+func TestAgentRunShowPrompt(t *testing.T) {
+	env := testenv.New(t)
+	defer env.RemoveAll()
+
+	agentContent := `%Meta
+Name: PromptAgent
+Description: Shows its prompt
+
+%Manifest
+Manifest content.
+
+%Mission
+Mission content.
+`
+	env.WriteFile("agents/prompt.agt", agentContent)
+
+	runCmd.Flags().Set("show-prompt", "true")
+	defer runCmd.Flags().Set("show-prompt", "false")
+
+	// Capture stdout
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	err := runCmd.RunE(runCmd, []string{"PromptAgent"})
+
+	w.Close()
+	os.Stdout = oldStdout
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	output := buf.String()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Manifest content.\n\n# Description\nShows its prompt\n\n# Mission\nMission content.\n", output)
+}
+
+func TestAgentRunVariables(t *testing.T) {
+	env := testenv.New(t)
+	defer env.RemoveAll()
+
+	agentContent := `%Meta
+Name: VarAgent
+
+%Variables
+target: ip
+port: number = 22
+
+%Mission
+Scan {{target}} on port {{port}}.
+`
+	env.WriteFile("agents/var.agt", agentContent)
+
+	runCmd.Flags().Set("show-prompt", "true")
+	defer runCmd.Flags().Set("show-prompt", "false")
+	defer runCmd.Flags().Lookup("var").Value.(pflag.SliceValue).Replace(nil)
+
+	t.Run("Missing", func(t *testing.T) {
+		err := runCmd.RunE(runCmd, []string{"VarAgent"})
+		assert.ErrorContains(t, err, "missing required variables: target")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		runCmd.Flags().Set("var", "target=nowhere")
+		defer runCmd.Flags().Lookup("var").Value.(pflag.SliceValue).Replace(nil)
+		err := runCmd.RunE(runCmd, []string{"VarAgent"})
+		assert.ErrorContains(t, err, "is not an ip address")
+	})
+
+	t.Run("Substituted", func(t *testing.T) {
+		runCmd.Flags().Set("var", "target=10.1.2.3")
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+
+		err := runCmd.RunE(runCmd, []string{"VarAgent"})
+
+		w.Close()
+		os.Stdout = oldStdout
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "# Mission\nScan 10.1.2.3 on port 22.\n", buf.String())
+	})
+}
+
+func TestReadPrompt(t *testing.T) {
+	defer func() {
+		runCmd.Flags().Set("prompt", "")
+		runCmd.Flags().Lookup("prompt").Changed = false
+	}()
+
+	pipe := func(input string) *os.File {
+		r, w, _ := os.Pipe()
+		w.WriteString(input)
+		w.Close()
+		return r
+	}
+
+	t.Run("Flag", func(t *testing.T) {
+		runCmd.Flags().Set("prompt", "Hello")
+		prompt, oneShot, fromStdin, err := readPrompt(runCmd, pipe("ignored"))
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello", prompt)
+		assert.True(t, oneShot)
+		assert.False(t, fromStdin)
+	})
+
+	t.Run("Dash", func(t *testing.T) {
+		runCmd.Flags().Set("prompt", "-")
+		prompt, oneShot, fromStdin, err := readPrompt(runCmd, pipe("From stdin\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, "From stdin", prompt)
+		assert.True(t, oneShot)
+		assert.True(t, fromStdin)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		runCmd.Flags().Set("prompt", "-")
+		_, _, _, err := readPrompt(runCmd, pipe("  \n"))
+		assert.ErrorContains(t, err, "prompt read from stdin is empty")
+	})
+}
+
+// countingLLM answers with the number of messages it got.
+type countingLLM struct{}
+
+func (countingLLM) Name() string { return "counting" }
+
+func (countingLLM) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		text := fmt.Sprintf("%d messages", len(req.Contents))
+		yield(&model.LLMResponse{Content: genai.NewContentFromText(text, genai.RoleModel)}, nil)
+	}
+}
+
+func TestConversationInteract(t *testing.T) {
+	env := testenv.New(t)
+	defer env.RemoveAll()
+	ctx := context.Background()
+
+	a, err := llmagent.New(llmagent.Config{Name: "Counter", Model: countingLLM{}})
+	require.NoError(t, err)
+	sessions, id, err := openSession(ctx, "Counter", "")
+	require.NoError(t, err)
+
+	conv := &conversation{Agent: a, Sessions: sessions, SessionID: id}
+	var out bytes.Buffer
+	require.NoError(t, conv.interact(ctx, bufio.NewReader(strings.NewReader("Hi\n\nAgain")), &out))
+	assert.Contains(t, out.String(), "Agent -> 1 messages\n")
+	assert.Contains(t, out.String(), "Agent -> 3 messages\n")
+
+	// The conversation continues in a resumed session
+	sessions, _, err = openSession(ctx, "Counter", id)
+	require.NoError(t, err)
+	conv = &conversation{Agent: a, Sessions: sessions, SessionID: id}
+	res, err := conv.turn(ctx, "Still there?", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "5 messages", res.Text)
+}
+
+// failingLLM fails every call.
+type failingLLM struct{}
+
+func (failingLLM) Name() string { return "failing" }
+
+func (failingLLM) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		yield(nil, errors.New("model not found"))
+	}
+}
+
+func TestRunOnceServedBy(t *testing.T) {
+	env := testenv.New(t)
+	defer env.RemoveAll()
+	ctx := context.Background()
+
+	fallback := &provider.Fallback{Models: []provider.Candidate{
+		{Name: "local/failing", LLM: failingLLM{}},
+		{Name: "gpu-box/counting", LLM: countingLLM{}},
+	}}
+	a, err := llmagent.New(llmagent.Config{Name: "Counter", Model: fallback})
+	require.NoError(t, err)
+	sessions, id, err := openSession(ctx, "Counter", "")
+	require.NoError(t, err)
+	setup := &runSetup{Agent: &agent.Agent{Name: "Counter"}, ModelName: "failing", ProviderName: "local"}
+
+	out, err := captureStdout(t, func() error {
+		return runOnce(ctx, setup, &conversation{Agent: a, Sessions: sessions, SessionID: id}, "Hi", "json")
+	})
+	require.NoError(t, err)
+	var res map[string]any
+	require.NoError(t, json.Unmarshal([]byte(out), &res))
+	assert.Equal(t, "Counter", res["agent"])
+	assert.Equal(t, "gpu-box/counting", res["model"])
+	assert.Equal(t, "1 messages", res["text"])
+}
+
+// partsLLM answers with the number of parts of the last message.
+type partsLLM struct{}
+
+func (partsLLM) Name() string { return "parts" }
+
+func (partsLLM) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		last := req.Contents[len(req.Contents)-1]
+		text := fmt.Sprintf("%d parts", len(last.Parts))
+		yield(&model.LLMResponse{Content: genai.NewContentFromText(text, genai.RoleModel)}, nil)
+	}
+}
+
+func TestConversationAttachments(t *testing.T) {
+	env := testenv.New(t)
+	defer env.RemoveAll()
+	ctx := context.Background()
+
+	env.WriteFile("notes.txt", "Buy milk")
+	env.WriteFile("pixel.png", "\x89PNG\r\n\x1a\n")
+	attachments, err := loadAttachments([]string{env.GetPath("notes.txt"), env.GetPath("pixel.png")})
+	require.NoError(t, err)
+	_, err = loadAttachments([]string{env.GetPath("missing.png")})
+	assert.ErrorContains(t, err, "Error attaching file")
+
+	a, err := llmagent.New(llmagent.Config{Name: "Viewer", Model: partsLLM{}})
+	require.NoError(t, err)
+	sessions, id, err := openSession(ctx, "Viewer", "")
+	require.NoError(t, err)
+
+	conv := &conversation{Agent: a, Sessions: sessions, SessionID: id, Attachments: attachments}
+	res, err := conv.turn(ctx, "What is this?", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "3 parts", res.Text)
+
+	// The files are only sent with the first message
+	res, err = conv.turn(ctx, "And now?", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "1 parts", res.Text)
+}
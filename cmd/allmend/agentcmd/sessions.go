@@ -0,0 +1,222 @@
+package agentcmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/SUSE/allmend/pkg/session"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	adksession "google.golang.org/adk/session"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:     "sessions",
+	Aliases: []string{"session"},
+	Short:   "Manage the stored conversations of agents",
+	Long: `Every run of an agent is stored as a session, which can be resumed
+with 'agent run NAME --session ID'. The sessions are kept in the directory
+configured as sessions_dir, by default "sessions" next to allmend.conf.`,
+}
+
+// GetSessionsDir determines the directory the sessions are stored in.
+func GetSessionsDir() string {
+	// 1. Check if configured explicitly in allmend.conf
+	if dir := viper.GetString("sessions_dir"); dir != "" {
+		return dir
+	}
+
+	// 2. Default: "sessions" in the same directory as allmend.conf
+	configFile := viper.ConfigFileUsed()
+	if configFile == "" {
+		// Fallback
+		return filepath.Join("config", "sessions")
+	}
+	return filepath.Join(filepath.Dir(configFile), "sessions")
+}
+
+// completeSessionIDs completes the first argument with the stored session IDs.
+func completeSessionIDs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	infos, _ := session.NewStore(GetSessionsDir()).List("")
+	ids := make([]string, 0, len(infos))
+	for _, info := range infos {
+		ids = append(ids, info.ID+"\t"+info.Agent+": "+info.Preview)
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp
+}
+
+var listSessionsCmd = &cobra.Command{
+	Use:               "list [agent name]",
+	Aliases:           []string{"ls"},
+	Short:             "List the sessions of an agent or of all agents",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeAgentNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		agentName := ""
+		if len(args) == 1 {
+			agentName = args[0]
+		}
+		format, _ := cmd.Flags().GetString("format")
+		tmpl, err := template.New("list").Parse(format)
+		if err != nil {
+			return fmt.Errorf("Error parsing template: %v\n", err)
+		}
+
+		infos, err := session.NewStore(GetSessionsDir()).List(agentName)
+		if err != nil {
+			return fmt.Errorf("Error listing sessions: %v\n", err)
+		}
+		if len(infos) == 0 {
+			fmt.Println("No sessions found.")
+			return nil
+		}
+		for _, info := range infos {
+			if err := tmpl.Execute(os.Stdout, info); err != nil {
+				fmt.Printf("Error executing template: %v\n", err)
+			}
+		}
+		return nil
+	},
+}
+
+var showSessionCmd = &cobra.Command{
+	Use:               "show [session id]",
+	Short:             "Show the conversation of a session",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSessionIDs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sess, err := session.NewStore(GetSessionsDir()).Load(args[0])
+		if err != nil {
+			return fmt.Errorf("Error loading session: %v\n", err)
+		}
+		writeConversation(os.Stdout, sess)
+		return nil
+	},
+}
+
+var deleteSessionCmd = &cobra.Command{
+	Use:               "delete [session id]",
+	Aliases:           []string{"rm"},
+	Short:             "Delete a session",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSessionIDs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := session.NewStore(GetSessionsDir()).Delete(args[0]); err != nil {
+			return fmt.Errorf("Error deleting session: %v\n", err)
+		}
+		fmt.Printf("Deleted session '%s'\n", args[0])
+		return nil
+	},
+}
+
+var exportSessionCmd = &cobra.Command{
+	Use:   "export [session id]",
+	Short: "Export the transcript of a session",
+	Long: `Export the transcript of a session with the setup of the agent, every
+message, tool call and tool result.
+
+Formats:
+  markdown  readable document
+  json      structured transcript
+  raw       the stored session with all ADK events`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSessionIDs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		file, _ := cmd.Flags().GetString("file")
+		if format == "" {
+			format = transcriptFormat(file)
+		}
+		if format != "markdown" && format != "json" && format != "raw" {
+			return fmt.Errorf("Error: Unknown format '%s', use markdown, json or raw.\n", format)
+		}
+
+		sess, err := session.NewStore(GetSessionsDir()).Load(args[0])
+		if err != nil {
+			return fmt.Errorf("Error loading session: %v\n", err)
+		}
+
+		var w io.Writer = os.Stdout
+		if file != "" {
+			f, err := os.Create(file)
+			if err != nil {
+				return fmt.Errorf("Error creating file: %v\n", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		if err := exportSession(w, sess, format); err != nil {
+			return fmt.Errorf("Error exporting session: %v\n", err)
+		}
+		return nil
+	},
+}
+
+// transcriptFormat returns the export format for a file name: markdown for
+// .md files and json otherwise.
+func transcriptFormat(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".md", ".markdown":
+		return "markdown"
+	}
+	return "json"
+}
+
+// exportSession writes sess in the format markdown, json or raw.
+func exportSession(w io.Writer, sess *session.Session, format string) error {
+	switch format {
+	case "markdown":
+		return session.NewTranscript(sess).WriteMarkdown(w)
+	case "raw":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(sess)
+	}
+	return session.NewTranscript(sess).WriteJSON(w)
+}
+
+// writeConversation prints the messages, tool calls and tool results of a
+// session.
+func writeConversation(w io.Writer, sess *session.Session) {
+	fmt.Fprintf(w, "Session %s of agent '%s', started %s\n", sess.ID, sess.Agent, sess.Created.Format("2006-01-02 15:04:05"))
+	for _, e := range sess.Events {
+		writeEvent(w, e)
+	}
+}
+
+func writeEvent(w io.Writer, e *adksession.Event) {
+	if e.ErrorCode != "" || e.ErrorMessage != "" {
+		fmt.Fprintf(w, "\n[%s] %s: ERROR %s %s\n", e.Timestamp.Format("15:04:05"), e.Author, e.ErrorCode, e.ErrorMessage)
+	}
+	if e.Content == nil {
+		return
+	}
+	for _, p := range e.Content.Parts {
+		switch {
+		case p.FunctionCall != nil:
+			args, _ := json.Marshal(p.FunctionCall.Args)
+			fmt.Fprintf(w, "\n[%s] %s calls %s %s\n", e.Timestamp.Format("15:04:05"), e.Author, p.FunctionCall.Name, args)
+		case p.FunctionResponse != nil:
+			resp, _ := json.Marshal(p.FunctionResponse.Response)
+			fmt.Fprintf(w, "\n[%s] %s returns %s\n", e.Timestamp.Format("15:04:05"), p.FunctionResponse.Name, resp)
+		case p.Text != "" && !p.Thought:
+			fmt.Fprintf(w, "\n[%s] %s:\n%s\n", e.Timestamp.Format("15:04:05"), e.Author, strings.TrimRight(p.Text, "\n"))
+		}
+	}
+}
+
+func init() {
+	listSessionsCmd.Flags().String("format", "- {{.ID}} {{.Agent}} ({{.Updated.Format \"2006-01-02 15:04\"}}, {{.EventCount}} events): {{.Preview}}\n", "Format string for listing sessions")
+	exportSessionCmd.Flags().StringP("file", "f", "", "Write the export to this file instead of stdout")
+	exportSessionCmd.Flags().String("format", "", "Format of the export: markdown, json or raw (default: markdown for .md files, json otherwise)")
+	sessionsCmd.AddCommand(listSessionsCmd, showSessionCmd, deleteSessionCmd, exportSessionCmd)
+	AgentCmd.AddCommand(sessionsCmd)
+}
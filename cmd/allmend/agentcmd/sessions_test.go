@@ -0,0 +1,116 @@
+package agentcmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/SUSE/allmend/internal/testenv"
+	"github.com/SUSE/allmend/pkg/runner"
+	"github.com/SUSE/allmend/pkg/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/adk/model"
+	adksession "google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+// captureStdout returns what f writes to stdout.
+func captureStdout(t *testing.T, f func() error) (string, error) {
+	t.Helper()
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	err := f()
+
+	w.Close()
+	os.Stdout = oldStdout
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	return buf.String(), err
+}
+
+func TestAgentSessions(t *testing.T) {
+	env := testenv.New(t)
+	defer env.RemoveAll()
+	ctx := context.Background()
+
+	sessions, id, err := openSession(ctx, "Helper", "chat-1")
+	require.NoError(t, err)
+	assert.Equal(t, "chat-1", id)
+	require.NoError(t, sessions.RecordRun(id, &session.Run{Agent: "Helper", AgentVersion: "1.0", Model: "small", Provider: "local", Instruction: "Be helpful."}))
+	got, err := sessions.Get(ctx, &adksession.GetRequest{AppName: runner.AppName, UserID: runner.UserID, SessionID: id})
+	require.NoError(t, err)
+	for _, e := range []struct{ author, text string }{{"user", "How are you?"}, {"Helper", "Fine, thanks."}} {
+		event := adksession.NewEvent("inv")
+		event.Author = e.author
+		event.LLMResponse = model.LLMResponse{Content: genai.NewContentFromText(e.text, genai.RoleUser)}
+		require.NoError(t, sessions.AppendEvent(ctx, got.Session, event))
+	}
+
+	t.Run("Resume", func(t *testing.T) {
+		_, resumed, err := openSession(ctx, "Helper", "chat-1")
+		require.NoError(t, err)
+		assert.Equal(t, "chat-1", resumed)
+
+		_, _, err = openSession(ctx, "Other", "chat-1")
+		assert.ErrorContains(t, err, "belongs to agent 'Helper'")
+
+		_, newID, err := openSession(ctx, "Other", "")
+		require.NoError(t, err)
+		assert.NotEmpty(t, newID)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		output, err := captureStdout(t, func() error {
+			return listSessionsCmd.RunE(listSessionsCmd, []string{"Helper"})
+		})
+		require.NoError(t, err)
+		assert.Contains(t, output, "- chat-1 Helper (")
+		assert.Contains(t, output, "2 events): How are you?")
+		assert.NotContains(t, output, "Other")
+	})
+
+	t.Run("Show", func(t *testing.T) {
+		output, err := captureStdout(t, func() error {
+			return showSessionCmd.RunE(showSessionCmd, []string{"chat-1"})
+		})
+		require.NoError(t, err)
+		assert.Contains(t, output, "Session chat-1 of agent 'Helper'")
+		assert.Contains(t, output, "user:\nHow are you?")
+		assert.Contains(t, output, "Helper:\nFine, thanks.")
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		defer exportSessionCmd.Flags().Set("file", "")
+		for file, expected := range map[string][]string{
+			"export.json": {`"session_id": "chat-1"`, `"model": "small"`, `"text": "Fine, thanks."`},
+			"export.md":   {"# Transcript of session chat-1", "- **Agent:** Helper (version 1.0)", "Be helpful.", "Fine, thanks."},
+		} {
+			exportSessionCmd.Flags().Set("file", env.GetPath(file))
+			require.NoError(t, exportSessionCmd.RunE(exportSessionCmd, []string{"chat-1"}))
+			exported := env.ReadFile(file)
+			for _, e := range expected {
+				assert.Contains(t, exported, e, file)
+			}
+		}
+
+		exportSessionCmd.Flags().Set("file", env.GetPath("raw.json"))
+		exportSessionCmd.Flags().Set("format", "raw")
+		defer exportSessionCmd.Flags().Set("format", "")
+		require.NoError(t, exportSessionCmd.RunE(exportSessionCmd, []string{"chat-1"}))
+		assert.Contains(t, env.ReadFile("raw.json"), `"InvocationID": "inv"`)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		_, err := captureStdout(t, func() error {
+			return deleteSessionCmd.RunE(deleteSessionCmd, []string{"chat-1"})
+		})
+		require.NoError(t, err)
+		_, err = session.NewStore(GetSessionsDir()).Load("chat-1")
+		assert.ErrorIs(t, err, session.ErrNotFound)
+	})
+}
@@ -0,0 +1,66 @@
+package agentcmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SUSE/allmend/cmd/allmend/toolcmd"
+	"github.com/SUSE/allmend/pkg/agent"
+	"github.com/SUSE/allmend/pkg/tools"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:               "verify [agent name]",
+	Short:             "Verify the signatures of the tools of an agent",
+	Long:              `Check the tools of an agent against the trusted keys listed in its %Tools section.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeAgentNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		a, err := findAgent(args[0])
+		if err != nil {
+			return err
+		}
+		if a.Tools == nil {
+			fmt.Printf("Agent '%s' uses no tools.\n", a.Name)
+			return nil
+		}
+
+		toolsPath, err := toolcmd.GetToolsFilePath()
+		if err != nil {
+			return fmt.Errorf("Error determining tools file path: %v\n", err)
+		}
+		store, err := tools.Load(toolsPath)
+		if err != nil {
+			return fmt.Errorf("Error loading tools: %v\n", err)
+		}
+
+		ctx := context.Background()
+		failed := 0
+		check := func(kind string, specs []*agent.MCPTools) {
+			for _, spec := range specs {
+				v, err := tools.Verify(ctx, store, spec)
+				switch {
+				case err != nil:
+					failed++
+					fmt.Printf("- %s (%s): FAILED: %v\n", spec.Name, kind, err)
+				case v == nil:
+					fmt.Printf("- %s (%s): no trusted keys, not verified\n", spec.Name, kind)
+				default:
+					fmt.Printf("- %s (%s): OK, %s signed by %s\n", spec.Name, kind, v.Artifact, v.Key)
+				}
+			}
+		}
+		fmt.Printf("Verifying tools of agent '%s':\n", a.Name)
+		check("required", a.Tools.Required)
+		check("recommended", a.Tools.Recommended)
+		if failed > 0 {
+			return fmt.Errorf("verification of %d tools failed", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	AgentCmd.AddCommand(verifyCmd)
+}
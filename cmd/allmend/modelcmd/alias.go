@@ -0,0 +1,157 @@
+package modelcmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/SUSE/allmend/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+var aliasModelCmd = &cobra.Command{
+	Use:   "alias NAME MODEL",
+	Short: "Add or change an alias of a model",
+	Long: `Make NAME an alias of MODEL. Aliases are accepted wherever a model
+is, by agents, --model and as default or fallback model, so agents don't
+have to name the model of a particular host, e.g.
+
+  allmend model alias coder local/qwen2.5-coder:14b
+  allmend model alias default-small coder`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: CompleteModelRefs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := loadStore()
+		if err != nil {
+			return fmt.Errorf("Error %v\n", err)
+		}
+		name, target := args[0], args[1]
+		if existing, ok := store.Items[name]; ok && existing.Alias == "" {
+			return fmt.Errorf("Error: model '%s' exists and isn't an alias\n", name)
+		}
+		if id, ok := otherModelNamed(store, name); ok {
+			return fmt.Errorf("Error: '%s' already refers to model '%s', choose another name\n", name, id)
+		}
+		_, resolved, err := store.Resolve(target)
+		if err != nil {
+			return fmt.Errorf("Error: %v\n", err)
+		}
+
+		m := store.Items[name]
+		m.Name, m.Alias = name, target
+		if cmd.Flags().Changed("description") {
+			m.Description, _ = cmd.Flags().GetString("description")
+		}
+		store.Items[name] = m
+		if _, _, err := store.Resolve(name); err != nil {
+			return fmt.Errorf("Error: %v\n", err)
+		}
+		if err := store.Save(); err != nil {
+			return fmt.Errorf("Error saving models: %v\n", err)
+		}
+		fmt.Printf("Alias '%s' refers to model '%s'.\n", name, resolved.ID())
+		return nil
+	},
+}
+
+var groupModelCmd = &cobra.Command{
+	Use:   "group NAME MODEL[=WEIGHT]...",
+	Short: "Add or change a group of models",
+	Long: `Make NAME a group spreading the turns over the given models, e.g.
+of several providers. By default the models take turns, with weights or
+--strategy weighted they are picked at random in proportion to their
+weights, 1 if not given. When the picked model fails, the retry goes to
+the next pick. Members have to be models with a provider or aliases of
+such models, e.g.
+
+  allmend model group qwen3-pool local/qwen3 gpu-box/qwen3
+  allmend model group coder-pool gpu-box/qwen2.5-coder:14b=3 local/qwen2.5-coder:14b=1`,
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: CompleteModelRefs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := loadStore()
+		if err != nil {
+			return fmt.Errorf("Error %v\n", err)
+		}
+		name := args[0]
+		if existing, ok := store.Items[name]; ok && existing.Group == nil {
+			return fmt.Errorf("Error: model '%s' exists and isn't a group\n", name)
+		}
+		if id, ok := otherModelNamed(store, name); ok {
+			return fmt.Errorf("Error: '%s' already refers to model '%s', choose another name\n", name, id)
+		}
+
+		group := &model.Group{}
+		for _, arg := range args[1:] {
+			member, err := parseGroupMember(arg)
+			if err != nil {
+				return fmt.Errorf("Error: %v\n", err)
+			}
+			_, m, err := store.Resolve(member.Model)
+			if err != nil {
+				return fmt.Errorf("Error: %v\n", err)
+			}
+			if m.Provider == "" {
+				return fmt.Errorf("Error: model '%s' has no provider and can't be member of a group\n", m.ID())
+			}
+			if member.Weight > 0 {
+				group.Strategy = model.Weighted
+			}
+			group.Members = append(group.Members, member)
+		}
+		if cmd.Flags().Changed("strategy") {
+			group.Strategy, _ = cmd.Flags().GetString("strategy")
+		}
+		switch group.Strategy {
+		case "", model.RoundRobin, model.Weighted:
+		default:
+			return fmt.Errorf("Error: unknown strategy '%s', use %s or %s\n", group.Strategy, model.RoundRobin, model.Weighted)
+		}
+
+		m := store.Items[name]
+		m.Name, m.Group = name, group
+		if cmd.Flags().Changed("description") {
+			m.Description, _ = cmd.Flags().GetString("description")
+		}
+		store.Items[name] = m
+		if err := store.Save(); err != nil {
+			return fmt.Errorf("Error saving models: %v\n", err)
+		}
+		fmt.Printf("Group '%s' spreads over %s.\n", name, group)
+		return nil
+	},
+}
+
+// otherModelNamed returns the ID of a model not stored under name which
+// name refers to by its name or ID. Using name for an alias or group would
+// make the references to that model ambiguous.
+func otherModelNamed(store *model.Store, name string) (string, bool) {
+	for k, m := range store.Items {
+		if k != name && (m.Name == name || m.ID() == name) {
+			return m.ID(), true
+		}
+	}
+	return "", false
+}
+
+// parseGroupMember parses a member given as MODEL or MODEL=WEIGHT.
+func parseGroupMember(arg string) (model.GroupMember, error) {
+	ref, weight, ok := strings.Cut(arg, "=")
+	member := model.GroupMember{Model: ref}
+	if ok {
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 1 {
+			return member, fmt.Errorf("invalid weight of member %q, expected a positive integer", arg)
+		}
+		member.Weight = w
+	}
+	return member, nil
+}
+
+func init() {
+	aliasModelCmd.Flags().String("description", "", "Description of the alias")
+	groupModelCmd.Flags().String("description", "", "Description of the group")
+	groupModelCmd.Flags().String("strategy", "", "How the models are picked, round-robin or weighted")
+	ModelCmd.AddCommand(aliasModelCmd)
+	ModelCmd.AddCommand(groupModelCmd)
+}
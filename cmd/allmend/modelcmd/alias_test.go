@@ -0,0 +1,65 @@
+package modelcmd
+
+import (
+	"testing"
+
+	"github.com/SUSE/allmend/internal/testenv"
+	"github.com/SUSE/allmend/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelAliasAndGroup(t *testing.T) {
+	env := testenv.New(t)
+	defer env.RemoveAll()
+
+	env.WriteFile("config/modells.yaml", `qwen2.5-coder:14b:
+  provider: local
+gpu-box/qwen2.5-coder:14b:
+  provider: gpu-box
+fast:
+  group:
+    members:
+      - model: local/qwen2.5-coder:14b
+`)
+	load := func() *model.Store {
+		store, err := model.Load(env.GetPath("config/modells.yaml"))
+		require.NoError(t, err)
+		return store
+	}
+
+	require.NoError(t, aliasModelCmd.RunE(aliasModelCmd, []string{"coder", "local/qwen2.5-coder:14b"}))
+	require.NoError(t, aliasModelCmd.RunE(aliasModelCmd, []string{"default-small", "coder"}))
+	err := aliasModelCmd.RunE(aliasModelCmd, []string{"fast", "coder"})
+	assert.ErrorContains(t, err, "model 'fast' exists and isn't an alias")
+	err = aliasModelCmd.RunE(aliasModelCmd, []string{"coder", "default-small"})
+	assert.ErrorContains(t, err, "alias 'coder' refers to itself")
+	// Names which already refer to a model are taken
+	err = aliasModelCmd.RunE(aliasModelCmd, []string{"local/qwen2.5-coder:14b", "coder"})
+	assert.ErrorContains(t, err, "'local/qwen2.5-coder:14b' already refers to model 'local/qwen2.5-coder:14b', choose another name")
+	err = groupModelCmd.RunE(groupModelCmd, []string{"gpu-box/qwen2.5-coder:14b", "coder"})
+	assert.ErrorContains(t, err, "model 'gpu-box/qwen2.5-coder:14b' exists and isn't a group")
+	err = groupModelCmd.RunE(groupModelCmd, []string{"local/qwen2.5-coder:14b", "coder"})
+	assert.ErrorContains(t, err, "'local/qwen2.5-coder:14b' already refers to model 'local/qwen2.5-coder:14b', choose another name")
+
+	require.NoError(t, groupModelCmd.RunE(groupModelCmd, []string{"pool", "gpu-box/qwen2.5-coder:14b=3", "coder"}))
+	err = groupModelCmd.RunE(groupModelCmd, []string{"pool", "fast"})
+	assert.ErrorContains(t, err, "model 'fast' has no provider and can't be member of a group")
+	err = groupModelCmd.RunE(groupModelCmd, []string{"pool", "coder=0"})
+	assert.ErrorContains(t, err, `invalid weight of member "coder=0", expected a positive integer`)
+
+	store := load()
+	_, m, err := store.Resolve("default-small")
+	require.NoError(t, err)
+	assert.Equal(t, "local/qwen2.5-coder:14b", m.ID())
+	assert.Equal(t, &model.Group{Strategy: model.Weighted, Members: []model.GroupMember{
+		{Model: "gpu-box/qwen2.5-coder:14b", Weight: 3},
+		{Model: "coder"},
+	}}, store.Items["pool"].Group)
+
+	assert.Equal(t, "local/qwen2.5-coder:14b", resolution(store, store.Items["default-small"]))
+	assert.Equal(t, "weighted gpu-box/qwen2.5-coder:14b:3, coder:1", resolution(store, store.Items["pool"]))
+	assert.Equal(t, "round-robin local/qwen2.5-coder:14b", resolution(store, store.Items["fast"]))
+	assert.Empty(t, resolution(store, store.Items["qwen2.5-coder:14b"]))
+	assert.Equal(t, "missing (model 'missing' not found)", resolution(store, model.Model{Name: "broken", Alias: "missing"}))
+}
@@ -0,0 +1,58 @@
+package modelcmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var defaultModelCmd = &cobra.Command{
+	Use:   "default [MODEL]",
+	Short: "Show or set the default model",
+	Long: `Show the default model or make MODEL the default. The default
+model is used by agents which don't prefer a model of their own, if no
+model is given with --model. It is stored in the models definition file.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: CompleteModelRefs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := loadStore()
+		if err != nil {
+			return fmt.Errorf("Error %v\n", err)
+		}
+
+		clearDefault, _ := cmd.Flags().GetBool("clear")
+		if clearDefault && len(args) == 1 {
+			return fmt.Errorf("Error: either give a model or --clear\n")
+		}
+		if len(args) == 0 && !clearDefault {
+			if store.DefaultModel == "" {
+				fmt.Println("No default model configured.")
+				return nil
+			}
+			fmt.Println(store.DefaultModel)
+			return nil
+		}
+
+		ref := ""
+		if len(args) == 1 {
+			ref = args[0]
+		}
+		if err := store.SetDefault(ref); err != nil {
+			return fmt.Errorf("Error: %v\n", err)
+		}
+		if err := store.Save(); err != nil {
+			return fmt.Errorf("Error saving models: %v\n", err)
+		}
+		if ref == "" {
+			fmt.Println("Default model cleared.")
+		} else {
+			fmt.Printf("Default model set to '%s'.\n", store.DefaultModel)
+		}
+		return nil
+	},
+}
+
+func init() {
+	defaultModelCmd.Flags().Bool("clear", false, "Clear the default model")
+	ModelCmd.AddCommand(defaultModelCmd)
+}
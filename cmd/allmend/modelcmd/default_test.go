@@ -0,0 +1,55 @@
+package modelcmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/SUSE/allmend/internal/testenv"
+	"github.com/SUSE/allmend/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureStdout returns what f writes to stdout.
+func captureStdout(t *testing.T, f func() error) (string, error) {
+	t.Helper()
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	err := f()
+
+	w.Close()
+	os.Stdout = oldStdout
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	return buf.String(), err
+}
+
+func TestModelDefault(t *testing.T) {
+	env := testenv.New(t)
+	defer env.RemoveAll()
+
+	env.WriteFile("config/modells.yaml", "granite4:3b:\n  provider: local\nqwen3:\n  provider: local\n")
+
+	err := defaultModelCmd.RunE(defaultModelCmd, []string{"missing"})
+	assert.ErrorContains(t, err, "model 'missing' not found")
+
+	require.NoError(t, defaultModelCmd.RunE(defaultModelCmd, []string{"qwen3"}))
+	store, err := model.Load(env.GetPath("config/modells.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "qwen3", store.DefaultModel)
+	assert.Len(t, store.Items, 2)
+
+	// Removing the default model clears the default
+	output, err := captureStdout(t, func() error {
+		return removeModelCmd.RunE(removeModelCmd, []string{"qwen3"})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Removed model 'local/qwen3'\nDefault model cleared.\n", output)
+	store, err = model.Load(env.GetPath("config/modells.yaml"))
+	require.NoError(t, err)
+	assert.Empty(t, store.DefaultModel)
+}
@@ -13,7 +13,8 @@ var listModelsCmd = &cobra.Command{
 	Use:     "list",
 	Aliases: []string{"ls"},
 	Short:   "List available models",
-	Long:    `List all AI models configured in the models definition file.`,
+	Long: `List all AI models configured in the models definition file. Aliases
+and groups are listed with what they resolve to.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		path, err := GetModelsFilePath()
 		if err != nil {
@@ -41,14 +42,38 @@ var listModelsCmd = &cobra.Command{
 		}
 
 		for _, m := range models {
-			if err := tmpl.Execute(os.Stdout, m); err != nil {
+			entry := listEntry{Model: m, Resolution: resolution(store, m)}
+			if err := tmpl.Execute(os.Stdout, entry); err != nil {
 				fmt.Printf("Error executing template: %v\n", err)
 			}
 		}
 	},
 }
 
+// listEntry is a model as passed to the list template.
+type listEntry struct {
+	model.Model
+	// Resolution tells what an alias or group resolves to.
+	Resolution string
+}
+
+// resolution describes what the alias or group m resolves to, empty for
+// other models.
+func resolution(store *model.Store, m model.Model) string {
+	switch {
+	case m.Alias != "":
+		_, target, err := store.Resolve(m.Alias)
+		if err != nil {
+			return fmt.Sprintf("%s (%v)", m.Alias, err)
+		}
+		return target.ID()
+	case m.Group != nil:
+		return m.Group.String()
+	}
+	return ""
+}
+
 func init() {
-	listModelsCmd.Flags().String("format", "- {{.Name}}: {{.Description}} ({{.Type}} via {{.Provider}})\n", "Format string for listing models")
+	listModelsCmd.Flags().String("format", "- {{.ID}}{{with .Resolution}} -> {{.}}{{end}}{{with .Description}}: {{.}}{{end}}{{with .Type}} ({{.}}){{end}}\n", "Format string for listing models")
 	ModelCmd.AddCommand(listModelsCmd)
 }
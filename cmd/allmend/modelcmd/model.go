@@ -1,8 +1,11 @@
 package modelcmd
 
 import (
+	"fmt"
 	"path/filepath"
+	"slices"
 
+	"github.com/SUSE/allmend/pkg/model"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -33,3 +36,32 @@ func GetModelsFilePath() (string, error) {
 	configDir := filepath.Dir(configFile)
 	return filepath.Join(configDir, "modells.yaml"), nil
 }
+
+// loadStore loads the models from the configured models file.
+func loadStore() (*model.Store, error) {
+	path, err := GetModelsFilePath()
+	if err != nil {
+		return nil, fmt.Errorf("determining models file path: %w", err)
+	}
+	store, err := model.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading models from %s: %w", path, err)
+	}
+	return store, nil
+}
+
+// CompleteModelRefs completes the arguments with the references of the
+// models, their names and provider-qualified IDs.
+func CompleteModelRefs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	store, err := loadStore()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var refs []string
+	for _, ref := range store.Refs() {
+		if !slices.Contains(args, ref) {
+			refs = append(refs, ref)
+		}
+	}
+	return refs, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,72 @@
+package modelcmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SUSE/allmend/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+var removeModelCmd = &cobra.Command{
+	Use:     "remove [MODEL...]",
+	Aliases: []string{"rm"},
+	Short:   "Remove models",
+	Long: `Remove models from the models definition file. Models still used by
+aliases, groups or as fallback of other models aren't removed, change or
+remove those first. Removing the default model clears the default.`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: CompleteModelRefs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := loadStore()
+		if err != nil {
+			return fmt.Errorf("Error %v\n", err)
+		}
+		var order []string
+		var removed []model.Model
+		keys := make(map[string]bool)
+		for _, ref := range args {
+			key, m, err := store.Find(ref)
+			if err != nil {
+				return fmt.Errorf("Error: %v\n", err)
+			}
+			if !keys[key] {
+				keys[key] = true
+				order = append(order, key)
+				removed = append(removed, m)
+			}
+		}
+		for _, key := range order {
+			var users []string
+			for _, r := range store.ReferencesTo(key) {
+				if !keys[r.Key] {
+					users = append(users, r.String())
+				}
+			}
+			if len(users) > 0 {
+				return fmt.Errorf("Error: model '%s' is still used by %s, change or remove them first\n", store.Items[key].ID(), strings.Join(users, ", "))
+			}
+		}
+		clearedDefault := keys[store.DefaultModel]
+		for key := range keys {
+			delete(store.Items, key)
+		}
+		if clearedDefault {
+			store.DefaultModel = ""
+		}
+		if err := store.Save(); err != nil {
+			return fmt.Errorf("Error saving models: %v\n", err)
+		}
+		for _, m := range removed {
+			fmt.Printf("Removed model '%s'\n", m.ID())
+		}
+		if clearedDefault {
+			fmt.Println("Default model cleared.")
+		}
+		return nil
+	},
+}
+
+func init() {
+	ModelCmd.AddCommand(removeModelCmd)
+}
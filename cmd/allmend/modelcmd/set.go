@@ -0,0 +1,152 @@
+package modelcmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SUSE/allmend/internal/config"
+	"github.com/SUSE/allmend/pkg/model"
+	"github.com/SUSE/allmend/pkg/provider"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var setModelCmd = &cobra.Command{
+	Use:   "set [MODEL]",
+	Short: "Change the settings of a model",
+	Long: `Change the description, type, provider, fallback models or config of a
+model. Only the given settings are changed. The config holds the
+generation parameters of the model, its values are parsed as YAML, so
+numbers keep their type, e.g.
+
+  allmend model set granite4:3b --type chat --config temperature=0.2 --unset-config top_k
+  allmend model set granite4:3b --fallback gpu-box/granite4:3b,qwen3
+
+Moving a model to another provider requires the provider to be configured,
+and isn't possible while aliases, groups or fallbacks refer to the model.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: CompleteModelRefs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := loadStore()
+		if err != nil {
+			return fmt.Errorf("Error %v\n", err)
+		}
+		key, m, err := store.Find(args[0])
+		if err != nil {
+			return fmt.Errorf("Error: %v\n", err)
+		}
+
+		flags := cmd.Flags()
+		if flags.Changed("description") {
+			m.Description, _ = flags.GetString("description")
+		}
+		if flags.Changed("type") {
+			m.Type, _ = flags.GetString("type")
+		}
+		if flags.Changed("provider") {
+			providerName, _ := flags.GetString("provider")
+			if key, err = moveToProvider(store, key, &m, providerName); err != nil {
+				return err
+			}
+		}
+		if flags.Changed("fallback") {
+			m.Fallback, _ = flags.GetStringSlice("fallback")
+			for _, ref := range m.Fallback {
+				if _, _, err := store.Resolve(ref); err != nil {
+					return fmt.Errorf("Error: fallback: %v\n", err)
+				}
+			}
+		}
+		pairs, _ := flags.GetStringArray("config")
+		for _, pair := range pairs {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok || strings.TrimSpace(key) == "" {
+				return fmt.Errorf("Error: invalid config %q, expected KEY=VALUE\n", pair)
+			}
+			if m.Config == nil {
+				m.Config = make(map[string]interface{})
+			}
+			m.Config[strings.TrimSpace(key)] = parseConfigValue(value)
+		}
+		unset, _ := flags.GetStringArray("unset-config")
+		for _, key := range unset {
+			delete(m.Config, key)
+		}
+		if len(m.Config) == 0 {
+			m.Config = nil
+		}
+		if _, err := m.Params(); err != nil {
+			return fmt.Errorf("Error: %v\n", err)
+		}
+
+		store.Items[key] = m
+		if err := store.Save(); err != nil {
+			return fmt.Errorf("Error saving models: %v\n", err)
+		}
+		fmt.Printf("Model '%s' updated.\n", m.ID())
+		return nil
+	},
+}
+
+// moveToProvider changes the provider of the model m stored under key to
+// providerName and returns the new key of the model, like Add chooses it.
+// The provider has to be configured and the model must neither exist at the
+// provider already nor be referred to by other models, whose references
+// would name the old provider.
+func moveToProvider(store *model.Store, key string, m *model.Model, providerName string) (string, error) {
+	if providerName == m.Provider {
+		return key, nil
+	}
+	if providerName != "" {
+		providers, err := provider.Load(config.ProvidersFilePath())
+		if err != nil {
+			return "", fmt.Errorf("Error loading providers: %v\n", err)
+		}
+		if _, ok := providers.Items[providerName]; !ok {
+			return "", fmt.Errorf("Error: provider '%s' not found\n", providerName)
+		}
+	}
+	if _, ok := store.KeyOf(providerName, m.Name); ok {
+		moved := *m
+		moved.Provider = providerName
+		return "", fmt.Errorf("Error: model '%s' already exists\n", moved.ID())
+	}
+	if refs := store.ReferencesTo(key); len(refs) > 0 {
+		users := make([]string, 0, len(refs))
+		for _, r := range refs {
+			users = append(users, r.String())
+		}
+		return "", fmt.Errorf("Error: model '%s' is still used by %s, change or remove them first\n", m.ID(), strings.Join(users, ", "))
+	}
+	delete(store.Items, key)
+	m.Provider = providerName
+	newKey := store.Add(*m)
+	if store.DefaultModel == key {
+		store.DefaultModel = newKey
+	}
+	return newKey, nil
+}
+
+// parseConfigValue parses a value given on the command line as YAML
+// scalar. Values that aren't valid YAML are kept as string.
+func parseConfigValue(value string) interface{} {
+	var parsed interface{}
+	if err := yaml.Unmarshal([]byte(value), &parsed); err != nil || parsed == nil {
+		return value
+	}
+	switch parsed.(type) {
+	case map[string]interface{}, []interface{}:
+		return value
+	}
+	return parsed
+}
+
+func init() {
+	setModelCmd.Flags().String("description", "", "Description of the model")
+	setModelCmd.Flags().String("type", "", "Type of the model, e.g. chat or embedding")
+	setModelCmd.Flags().String("provider", "", "Provider serving the model")
+	setModelCmd.Flags().StringSlice("fallback", nil, "Models tried in order when the model fails, comma separated, empty to remove them")
+	setModelCmd.Flags().StringArray("config", nil, "Config value as KEY=VALUE (can be repeated)")
+	setModelCmd.Flags().StringArray("unset-config", nil, "Config key to remove (can be repeated)")
+	ModelCmd.AddCommand(setModelCmd)
+}
@@ -0,0 +1,135 @@
+package modelcmd
+
+import (
+	"testing"
+
+	"github.com/SUSE/allmend/internal/testenv"
+	"github.com/SUSE/allmend/pkg/model"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resetFlags sets the flags of cmd back to their defaults, so that values
+// of earlier runs of the package-level command don't leak into the next.
+func resetFlags(cmd *cobra.Command) {
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if v, ok := f.Value.(pflag.SliceValue); ok {
+			v.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	})
+}
+
+// executeSet runs 'model set' with args and fresh flags.
+func executeSet(t *testing.T, args ...string) error {
+	t.Helper()
+	resetFlags(setModelCmd)
+	t.Cleanup(func() { resetFlags(setModelCmd) })
+	ModelCmd.SetArgs(append([]string{"set"}, args...))
+	return ModelCmd.Execute()
+}
+
+func TestModelSetAndRemove(t *testing.T) {
+	env := testenv.New(t)
+	defer env.RemoveAll()
+
+	env.WriteFile("config/modells.yaml", `granite4:3b:
+  provider: local
+  type: chat
+  config:
+    top_k: 20
+qwen3:
+  provider: local
+`)
+
+	require.NoError(t, executeSet(t, "granite4:3b", "--description", "Small granite", "--config", "temperature=0.2", "--config", "stop=END", "--unset-config", "top_k"))
+
+	store, err := model.Load(env.GetPath("config/modells.yaml"))
+	require.NoError(t, err)
+	granite := store.Items["granite4:3b"]
+	assert.Equal(t, "Small granite", granite.Description)
+	assert.Equal(t, "chat", granite.Type)
+	assert.Equal(t, "local", granite.Provider)
+	assert.Equal(t, map[string]interface{}{"temperature": 0.2, "stop": "END"}, granite.Config)
+
+	require.NoError(t, executeSet(t, "granite4:3b", "--fallback", "qwen3"))
+	store, err = model.Load(env.GetPath("config/modells.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"qwen3"}, store.Items["granite4:3b"].Fallback)
+
+	assert.ErrorContains(t, executeSet(t, "granite4:3b", "--fallback", "missing"), "fallback: model 'missing' not found")
+
+	resetFlags(setModelCmd)
+	err = setModelCmd.RunE(setModelCmd, []string{"missing"})
+	assert.ErrorContains(t, err, "model 'missing' not found")
+
+	err = removeModelCmd.RunE(removeModelCmd, []string{"qwen3", "missing"})
+	assert.ErrorContains(t, err, "model 'missing' not found")
+	// Models used as fallback aren't removed
+	err = removeModelCmd.RunE(removeModelCmd, []string{"qwen3"})
+	assert.EqualError(t, err, "Error: model 'local/qwen3' is still used by fallback of 'local/granite4:3b', change or remove them first\n")
+	require.NoError(t, executeSet(t, "granite4:3b", "--fallback", ""))
+	require.NoError(t, removeModelCmd.RunE(removeModelCmd, []string{"qwen3"}))
+
+	store, err = model.Load(env.GetPath("config/modells.yaml"))
+	require.NoError(t, err)
+	assert.NotContains(t, store.Items, "qwen3")
+	assert.Contains(t, store.Items, "granite4:3b")
+}
+
+func TestModelSetProvider(t *testing.T) {
+	env := testenv.New(t)
+	defer env.RemoveAll()
+
+	env.WriteFile("config/providers.conf", "local:\n  type: ollama\ngpu-box:\n  type: ollama\ncloud:\n  type: ollama\n")
+	env.WriteFile("config/modells.yaml", `granite4:3b:
+  provider: local
+qwen3:
+  provider: local
+local/gpt-oss:
+  name: gpt-oss
+  provider: local
+  fallback: [local/qwen3]
+gpu-box/gpt-oss:
+  name: gpt-oss
+  provider: gpu-box
+default: granite4:3b
+`)
+	load := func() *model.Store {
+		store, err := model.Load(env.GetPath("config/modells.yaml"))
+		require.NoError(t, err)
+		return store
+	}
+
+	assert.EqualError(t, executeSet(t, "granite4:3b", "--provider", "missing"), "Error: provider 'missing' not found\n")
+	assert.EqualError(t, executeSet(t, "local/gpt-oss", "--provider", "gpu-box"), "Error: model 'gpu-box/gpt-oss' already exists\n")
+	assert.EqualError(t, executeSet(t, "qwen3", "--provider", "cloud"), "Error: model 'local/qwen3' is still used by fallback of 'local/gpt-oss', change or remove them first\n")
+	assert.Equal(t, "local", load().Items["qwen3"].Provider)
+
+	// The model keeps its plain key, and stays the default
+	require.NoError(t, executeSet(t, "granite4:3b", "--provider", "cloud"))
+	store := load()
+	assert.Equal(t, "cloud", store.Items["granite4:3b"].Provider)
+	assert.Equal(t, "granite4:3b", store.DefaultModel)
+
+	// A model moving away from a shared name gets the key Add would choose
+	require.NoError(t, executeSet(t, "local/gpt-oss", "--provider", "cloud", "--fallback", ""))
+	store = load()
+	assert.NotContains(t, store.Items, "local/gpt-oss")
+	key, m, err := store.Find("cloud/gpt-oss")
+	require.NoError(t, err)
+	assert.Equal(t, "cloud/gpt-oss", key)
+	assert.Empty(t, m.Fallback)
+}
+
+func TestParseConfigValue(t *testing.T) {
+	assert.Equal(t, 42, parseConfigValue("42"))
+	assert.Equal(t, true, parseConfigValue("true"))
+	assert.Equal(t, "granite", parseConfigValue("granite"))
+	assert.Equal(t, "a: b", parseConfigValue("a: b"))
+	assert.Equal(t, "", parseConfigValue(""))
+}
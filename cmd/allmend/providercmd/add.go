@@ -3,8 +3,8 @@ package providercmd
 import (
 	"context"
 	"fmt"
+	"strings"
 
-	"github.com/SUSE/allmend/internal/config"
 	"github.com/SUSE/allmend/pkg/provider"
 	"github.com/spf13/cobra"
 )
@@ -15,58 +15,80 @@ var addCmd = &cobra.Command{
 	Long:  `Add a new provider configuration.`,
 }
 
-// Ollama variables
-var (
-	ollamaEndpoint string
-)
-
-var addOllamaCmd = &cobra.Command{
-	Use:   "ollama [NAME]",
-	Short: "Add an Ollama provider",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		name := args[0]
-		config := map[string]any{
-			"endpoint": ollamaEndpoint,
+// AddTypeCommands adds a subcommand to 'provider add' for every registered
+// provider type that has none yet. The built-in types are added on init,
+// binaries registering types of their own call it before executing.
+func AddTypeCommands() {
+	for _, f := range provider.Types() {
+		if cmd, _, err := addCmd.Find([]string{f.Type}); err == nil && cmd != addCmd {
+			continue
 		}
-		skipSync, _ := cmd.Flags().GetBool("no-sync")
-		addProvider(name, "ollama", config, skipSync)
-	},
+		addCmd.AddCommand(newAddTypeCmd(f))
+	}
 }
 
-// Google / Gemini variables
-var (
-	googleAPIKey    string
-	googleProjectID string
-	googleLocation  string
-	googleBackend   string
-)
-
-var addGoogleCmd = &cobra.Command{
-	Use:     "google [NAME]",
-	Aliases: []string{"gemini"},
-	Short:   "Add a Google/Gemini provider",
-	Args:    cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		name := args[0]
-
-		apiKey := googleAPIKey
-		if apiKey == "" {
-			apiKey = config.GetEnvOrFile("GEMINI_API_KEY")
-			if apiKey != "" {
-				fmt.Println("Using API Key from environment or .env file.")
+// newAddTypeCmd creates the command adding a provider of type f with a flag
+// for every field.
+func newAddTypeCmd(f provider.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     f.Type + " [NAME]",
+		Aliases: f.Aliases,
+		Short:   "Add " + f.Description,
+		Long:    f.Help,
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			name := args[0]
+
+			cfg := map[string]any{}
+			for _, field := range f.Fields {
+				if field.Map {
+					pairs, _ := cmd.Flags().GetStringArray(field.FlagName())
+					values := map[string]any{}
+					for _, pair := range pairs {
+						key, value, ok := strings.Cut(pair, "=")
+						if !ok || key == "" {
+							fmt.Printf("Error: Invalid %s '%s', expected KEY=VALUE.\n", strings.ToLower(field.Name), pair)
+							return
+						}
+						values[key] = value
+					}
+					if len(values) > 0 {
+						cfg[field.Key] = values
+					}
+					continue
+				}
+
+				if value, _ := cmd.Flags().GetString(field.FlagName()); value != "" {
+					cfg[field.Key] = value
+				}
 			}
-		}
+			for _, field := range f.Fields {
+				if field.Map || cfg[field.Key] != nil {
+					continue
+				}
+				if value := f.EnvValue(cfg, field); value != "" {
+					fmt.Printf("Using %s from environment or .env file.\n", field.Name)
+					cfg[field.Key] = value
+				}
+			}
+			if err := f.Validate(cfg); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+
+			skipSync, _ := cmd.Flags().GetBool("no-sync")
+			addProvider(name, f.Type, cfg, skipSync)
+		},
+	}
 
-		config := map[string]any{
-			"api_key":    apiKey,
-			"project_id": googleProjectID,
-			"location":   googleLocation,
-			"backend":    googleBackend,
+	for _, field := range f.Fields {
+		if field.Map {
+			cmd.Flags().StringArray(field.FlagName(), nil, field.Usage)
+		} else {
+			cmd.Flags().String(field.FlagName(), field.Default, field.Usage)
 		}
-		skipSync, _ := cmd.Flags().GetBool("no-sync")
-		addProvider(name, "google", config, skipSync)
-	},
+	}
+	return cmd
 }
 
 // Helper function to add a provider
@@ -119,15 +141,5 @@ func init() {
 	ProviderCmd.AddCommand(addCmd)
 
 	addCmd.PersistentFlags().Bool("no-sync", false, "Do not automatically sync models from provider")
-
-	// Ollama flags
-	addOllamaCmd.Flags().StringVar(&ollamaEndpoint, "endpoint", "http://localhost:11434", "Ollama API endpoint")
-	addCmd.AddCommand(addOllamaCmd)
-
-	// Google flags
-	addGoogleCmd.Flags().StringVar(&googleAPIKey, "api-key", "", "Google API Key (optional, defaults to GEMINI_API_KEY env var or .env file)")
-	addGoogleCmd.Flags().StringVar(&googleProjectID, "project-id", "", "Google Cloud Project ID")
-	addGoogleCmd.Flags().StringVar(&googleLocation, "location", "us-central1", "Google Cloud Location")
-	addGoogleCmd.Flags().StringVar(&googleBackend, "backend", "gemini", "Backend type (gemini or vertex)")
-	addCmd.AddCommand(addGoogleCmd)
+	AddTypeCommands()
 }
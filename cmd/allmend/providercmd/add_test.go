@@ -2,13 +2,18 @@ package providercmd
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/SUSE/allmend/internal/testenv"
 	"github.com/SUSE/allmend/pkg/provider"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -27,9 +32,19 @@ func captureOutput(f func()) string {
 	return buf.String()
 }
 
+// addTypeCmd returns the 'provider add' subcommand of a provider type.
+func addTypeCmd(t *testing.T, typeName string) *cobra.Command {
+	t.Helper()
+	cmd, _, err := addCmd.Find([]string{typeName})
+	require.NoError(t, err)
+	require.NotEqual(t, addCmd, cmd, "no add command for %s", typeName)
+	return cmd
+}
+
 func TestAddOllama(t *testing.T) {
 	env := testenv.New(t)
 	defer env.RemoveAll()
+	addOllamaCmd := addTypeCmd(t, "ollama")
 
 	// Reset flags
 	addOllamaCmd.Flags().Set("endpoint", "http://localhost:11434")
@@ -74,6 +89,7 @@ func TestAddOllama(t *testing.T) {
 func TestAddGoogle(t *testing.T) {
 	env := testenv.New(t)
 	defer env.RemoveAll()
+	addGoogleCmd := addTypeCmd(t, "google")
 
 	resetFlags := func() {
 		addGoogleCmd.Flags().Set("api-key", "")
@@ -147,4 +163,156 @@ func TestAddGoogle(t *testing.T) {
 		require.True(t, ok)
 		assert.Equal(t, "file-key", p.Config["api_key"])
 	})
+
+	t.Run("VertexProject", func(t *testing.T) {
+		resetFlags()
+		os.Setenv("GEMINI_API_KEY", "env-key")
+		defer os.Unsetenv("GEMINI_API_KEY")
+		addGoogleCmd.Flags().Set("backend", "vertex")
+		addGoogleCmd.Flags().Set("project-id", "my-project")
+
+		output := captureOutput(func() {
+			addGoogleCmd.Run(addGoogleCmd, []string{"vertex-project"})
+		})
+
+		assert.NotContains(t, output, "Using API Key from environment or .env file.")
+		assert.Contains(t, output, "Provider 'vertex-project' added successfully.")
+
+		store, err := provider.Load(filepath.Join(env.BaseDir, "config", "providers.conf"))
+		require.NoError(t, err)
+		p, ok := store.Items["vertex-project"]
+		require.True(t, ok)
+		assert.Equal(t, "my-project", p.Config["project_id"])
+		assert.Nil(t, p.Config["api_key"])
+
+		addGoogleCmd.Flags().Set("api-key", "secret-key")
+		output = captureOutput(func() {
+			addGoogleCmd.Run(addGoogleCmd, []string{"vertex-both"})
+		})
+		assert.Contains(t, output, "Error: google provider with vertex backend takes either api_key or project_id, remove one of them")
+	})
+}
+
+func TestAddOpenAI(t *testing.T) {
+	env := testenv.New(t)
+	defer env.RemoveAll()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" || r.Header.Get("Authorization") != "Bearer secret-key" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"data": [{"id": "qwen2.5-coder"}]}`)
+	}))
+	defer srv.Close()
+
+	addOpenAICmd := addTypeCmd(t, "openai")
+	addOpenAICmd.Flags().Set("base-url", srv.URL+"/v1")
+	addOpenAICmd.Flags().Set("api-key", "secret-key")
+	addOpenAICmd.Flags().Set("header", "X-Team=allmend")
+	defer func() {
+		addOpenAICmd.Flags().Set("base-url", "https://api.openai.com/v1")
+		addOpenAICmd.Flags().Set("api-key", "")
+		addOpenAICmd.Flags().Lookup("header").Value.(pflag.SliceValue).Replace(nil)
+	}()
+
+	output := captureOutput(func() {
+		addOpenAICmd.Run(addOpenAICmd, []string{"vllm"})
+	})
+
+	assert.Contains(t, output, "Provider 'vllm' added successfully.")
+	assert.Contains(t, output, "Added 1 models from provider 'vllm'.")
+
+	store, err := provider.Load(filepath.Join(env.BaseDir, "config", "providers.conf"))
+	require.NoError(t, err)
+	p, ok := store.Items["vllm"]
+	require.True(t, ok)
+	assert.Equal(t, "openai", p.Type)
+	assert.Equal(t, srv.URL+"/v1", p.Config["base_url"])
+	assert.Equal(t, "secret-key", p.Config["api_key"])
+	assert.Equal(t, map[string]any{"X-Team": "allmend"}, p.Config["headers"])
+}
+
+func TestAddAnthropic(t *testing.T) {
+	env := testenv.New(t)
+	defer env.RemoveAll()
+
+	os.Setenv("ANTHROPIC_API_KEY", "env-key")
+	defer os.Unsetenv("ANTHROPIC_API_KEY")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" || r.Header.Get("X-Api-Key") != "env-key" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"data": [{"id": "claude-a"}, {"id": "claude-b"}], "has_more": false}`)
+	}))
+	defer srv.Close()
+	addAnthropicCmd := addTypeCmd(t, "anthropic")
+	addAnthropicCmd.Flags().Set("base-url", srv.URL)
+	defer addAnthropicCmd.Flags().Set("base-url", "https://api.anthropic.com")
+
+	output := captureOutput(func() {
+		addAnthropicCmd.Run(addAnthropicCmd, []string{"claude"})
+	})
+
+	assert.Contains(t, output, "Using API Key from environment or .env file.")
+	assert.Contains(t, output, "Provider 'claude' added successfully.")
+	assert.Contains(t, output, "Added 2 models from provider 'claude'.")
+
+	store, err := provider.Load(filepath.Join(env.BaseDir, "config", "providers.conf"))
+	require.NoError(t, err)
+	p, ok := store.Items["claude"]
+	require.True(t, ok)
+	assert.Equal(t, "anthropic", p.Type)
+	assert.Equal(t, srv.URL, p.Config["base_url"])
+	assert.Equal(t, "env-key", p.Config["api_key"])
+}
+
+func TestAddTypeCommands(t *testing.T) {
+	env := testenv.New(t)
+	defer env.RemoveAll()
+
+	ollama, _ := provider.Lookup("ollama")
+	provider.Register(provider.Factory{
+		Type:          "custom",
+		Description:   "a custom provider",
+		Fields:        []provider.Field{{Key: "tenant_id", Name: "Tenant", Required: true}},
+		NewLLM:        ollama.NewLLM,
+		NewConnection: ollama.NewConnection,
+	})
+	t.Cleanup(func() {
+		provider.Unregister("custom")
+		for _, cmd := range addCmd.Commands() {
+			if cmd.Name() == "custom" {
+				addCmd.RemoveCommand(cmd)
+			}
+		}
+	})
+	AddTypeCommands()
+	AddTypeCommands()
+
+	var count int
+	for _, cmd := range addCmd.Commands() {
+		if cmd.Name() == "custom" {
+			count++
+		}
+	}
+	assert.Equal(t, 1, count)
+
+	customCmd := addTypeCmd(t, "custom")
+	assert.Equal(t, "Add a custom provider", customCmd.Short)
+	output := captureOutput(func() {
+		customCmd.Run(customCmd, []string{"mine"})
+	})
+	assert.Contains(t, output, "Error: custom provider requires tenant_id")
+
+	customCmd.Flags().Set("tenant-id", "t-1")
+	output = captureOutput(func() {
+		customCmd.Run(customCmd, []string{"mine"})
+	})
+	assert.Contains(t, output, "Provider 'mine' added successfully.")
+
+	store, err := provider.Load(filepath.Join(env.BaseDir, "config", "providers.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"tenant_id": "t-1"}, store.Items["mine"].Config)
 }
@@ -0,0 +1,291 @@
+package providercmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/SUSE/allmend/cmd/allmend/modelcmd"
+	"github.com/SUSE/allmend/pkg/model"
+	"github.com/SUSE/allmend/pkg/provider"
+	"github.com/spf13/cobra"
+	adkmodel "google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check [NAME...]",
+	Short: "Check that providers are reachable and accept the credentials",
+	Long: `Connect to the given providers, or all of them, list their models and
+report the latency. With --generate, or a model given with --model, a
+tiny generation is run as well. By default the first chat model of the
+provider in the global model list is used for it.
+
+Failures are reported with the step which failed, the kind of problem,
+like auth, tls, endpoint or model, and a hint how to fix it. With
+--output json the results are printed as JSON for monitoring. The command
+fails if any provider fails the check.`,
+	Args:              cobra.ArbitraryArgs,
+	ValidArgsFunction: completeProviderNames,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		if output != "text" && output != "json" {
+			return fmt.Errorf("Error: Unknown output format '%s', use text or json.\n", output)
+		}
+		opts := checkOptions{}
+		opts.Model, _ = cmd.Flags().GetString("model")
+		opts.Generate, _ = cmd.Flags().GetBool("generate")
+		opts.Generate = opts.Generate || opts.Model != ""
+		opts.Timeout, _ = cmd.Flags().GetDuration("timeout")
+
+		path, err := GetProvidersFilePath()
+		if err != nil {
+			return fmt.Errorf("Error determining providers file path: %v\n", err)
+		}
+		store, err := provider.Load(path)
+		if err != nil {
+			return fmt.Errorf("Error loading providers from %s: %v\n", path, err)
+		}
+		providers := store.List()
+		if len(args) > 0 {
+			providers = providers[:0]
+			for _, name := range args {
+				p, ok := store.Items[name]
+				if !ok {
+					return fmt.Errorf("Error: provider '%s' not found\n", name)
+				}
+				providers = append(providers, p)
+			}
+		}
+		if len(providers) == 0 {
+			fmt.Println("No providers configured.")
+			return nil
+		}
+		if opts.Generate {
+			// The global model list tells which models chat
+			if modelsPath, err := modelcmd.GetModelsFilePath(); err == nil {
+				opts.Models, _ = model.Load(modelsPath)
+			}
+		}
+
+		ctx := cmd.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		var results []checkResult
+		failed := 0
+		for _, p := range providers {
+			res := checkProvider(ctx, p, opts)
+			if !res.OK {
+				failed++
+			}
+			if output == "text" {
+				res.print(os.Stdout)
+			}
+			results = append(results, res)
+		}
+		if output == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(results); err != nil {
+				return fmt.Errorf("Error: %v\n", err)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("Error: %d of %d providers failed the check\n", failed, len(results))
+		}
+		return nil
+	},
+}
+
+// checkOptions are the settings of a provider check.
+type checkOptions struct {
+	// Generate runs a tiny generation with Model, or the first chat model
+	// of the provider in Models, or the first model it lists.
+	Generate bool
+	Model    string
+	Models   *model.Store
+	// Timeout limits the check of one provider.
+	Timeout time.Duration
+}
+
+// Steps of a provider check.
+const (
+	stepConnect  = "connect"
+	stepModels   = "models"
+	stepGenerate = "generate"
+)
+
+// checkResult is the outcome of the check of a provider.
+type checkResult struct {
+	Provider string `json:"provider"`
+	Type     string `json:"type"`
+	OK       bool   `json:"ok"`
+	// LatencyMS is the time listing the models took.
+	LatencyMS int64 `json:"latency_ms"`
+	Models    int   `json:"models"`
+	// Model is the model of the generation, if any.
+	Model        string `json:"model,omitempty"`
+	GenerationMS int64  `json:"generation_ms,omitempty"`
+	// Step is the step which failed, Problem the kind of failure found by
+	// provider.Diagnose or "config" and "model".
+	Step    string `json:"step,omitempty"`
+	Problem string `json:"problem,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// checkProvider connects to the provider p, lists its models and runs a
+// tiny generation if requested.
+func checkProvider(ctx context.Context, p provider.Provider, opts checkOptions) checkResult {
+	res := checkResult{Provider: p.Name, Type: p.Type}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	conn, err := p.GetConnection(ctx)
+	if err != nil {
+		res.fail(stepConnect, err)
+		if res.Problem == "" {
+			res.Problem, res.Hint = "config", "check the configuration of the provider in providers.conf"
+		}
+		return res
+	}
+
+	start := time.Now()
+	names, err := conn.GetModells(ctx)
+	res.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		res.fail(stepModels, err)
+		return res
+	}
+	res.Models = len(names)
+	if !opts.Generate {
+		res.OK = true
+		return res
+	}
+
+	res.Model = opts.Model
+	if res.Model == "" {
+		res.Model = generationModel(p.Name, names, opts.Models)
+	}
+	switch {
+	case res.Model == "":
+		res.Step, res.Problem = stepGenerate, "model"
+		res.Error = "the provider offers no models"
+		res.Hint = "pull or enable a model at the provider"
+		return res
+	case !slices.Contains(names, res.Model):
+		res.Step, res.Problem = stepGenerate, "model"
+		res.Error = fmt.Sprintf("model '%s' isn't offered by the provider", res.Model)
+		res.Hint = fmt.Sprintf("see 'allmend provider model list %s' for the models it offers", p.Name)
+		return res
+	}
+
+	start = time.Now()
+	if err := generate(ctx, p, res.Model); err != nil {
+		res.fail(stepGenerate, err)
+		return res
+	}
+	res.GenerationMS = time.Since(start).Milliseconds()
+	res.OK = true
+	return res
+}
+
+// generationModel returns the model to check the generation of the provider
+// with: its first chat model in the global model list which it still
+// offers, else the first model it lists.
+func generationModel(providerName string, names []string, models *model.Store) string {
+	if models != nil {
+		for _, m := range models.List() {
+			if m.Provider == providerName && (m.Type == "chat" || m.Type == "") && slices.Contains(names, m.Name) {
+				return m.Name
+			}
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+// generate asks the model modelName of the provider p for a short answer.
+func generate(ctx context.Context, p provider.Provider, modelName string) error {
+	llm, err := p.CreateLLM(ctx, modelName)
+	if err != nil {
+		return err
+	}
+	req := &adkmodel.LLMRequest{
+		Model:    modelName,
+		Contents: []*genai.Content{genai.NewContentFromText("Reply with OK.", genai.RoleUser)},
+		Config:   &genai.GenerateContentConfig{MaxOutputTokens: 16},
+	}
+	for _, err := range llm.GenerateContent(ctx, req, false) {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fail records the failure of step with err.
+func (r *checkResult) fail(step string, err error) {
+	r.Step = step
+	r.Error = err.Error()
+	r.Problem, r.Hint = provider.Diagnose(err)
+}
+
+func (r checkResult) print(w io.Writer) {
+	if r.OK {
+		fmt.Fprintf(w, "%s (%s): OK, %d models listed in %dms", r.Provider, r.Type, r.Models, r.LatencyMS)
+		if r.Model != "" {
+			fmt.Fprintf(w, ", '%s' answered in %dms", r.Model, r.GenerationMS)
+		}
+		fmt.Fprintln(w)
+		return
+	}
+	steps := map[string]string{
+		stepConnect:  "connecting",
+		stepModels:   "listing models",
+		stepGenerate: "generating",
+	}
+	fmt.Fprintf(w, "%s (%s): FAILED %s: %s\n", r.Provider, r.Type, steps[r.Step], r.Error)
+	if r.Hint != "" {
+		fmt.Fprintf(w, "  Hint: %s\n", r.Hint)
+	}
+}
+
+// completeProviderNames completes the arguments with the names of the
+// configured providers.
+func completeProviderNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	path, err := GetProvidersFilePath()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	store, err := provider.Load(path)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, p := range store.List() {
+		if !slices.Contains(args, p.Name) {
+			names = append(names, p.Name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	checkCmd.Flags().Bool("generate", false, "Run a tiny generation as well")
+	checkCmd.Flags().String("model", "", "Model of the provider for the generation, implies --generate")
+	checkCmd.Flags().Duration("timeout", 30*time.Second, "Time limit of the check of each provider")
+	checkCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+	ProviderCmd.AddCommand(checkCmd)
+}
@@ -0,0 +1,117 @@
+package providercmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SUSE/allmend/internal/testenv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderCheck(t *testing.T) {
+	env := testenv.New(t)
+	defer env.RemoveAll()
+
+	var chatModel string
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			fmt.Fprint(w, `{"models": [{"name": "nomic-embed-text"}, {"name": "granite4:3b"}]}`)
+		case "/api/chat":
+			var req map[string]any
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			chatModel, _ = req["model"].(string)
+			fmt.Fprint(w, `{"model": "granite4:3b", "message": {"role": "assistant", "content": "OK"}, "done": true}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ollama.Close()
+	openai := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error": {"message": "Incorrect API key provided"}}`)
+	}))
+	defer openai.Close()
+
+	env.WriteFile("config/providers.conf", fmt.Sprintf(`local:
+  type: ollama
+  config:
+    endpoint: %s
+cloud:
+  type: openai
+  config:
+    base_url: %s
+    api_key: wrong
+`, ollama.URL, openai.URL))
+	env.WriteFile("config/modells.yaml", "nomic-embed-text:\n  provider: local\n  type: embedding\ngranite4:3b:\n  provider: local\n  type: chat\n")
+
+	t.Run("Text", func(t *testing.T) {
+		var err error
+		output := captureOutput(func() {
+			err = checkCmd.RunE(checkCmd, []string{"local"})
+		})
+		require.NoError(t, err)
+		assert.Regexp(t, `^local \(ollama\): OK, 2 models listed in \d+ms\n$`, output)
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		checkCmd.Flags().Set("output", "json")
+		checkCmd.Flags().Set("generate", "true")
+		defer checkCmd.Flags().Set("output", "text")
+		defer checkCmd.Flags().Set("generate", "false")
+
+		var err error
+		output := captureOutput(func() {
+			err = checkCmd.RunE(checkCmd, nil)
+		})
+		assert.EqualError(t, err, "Error: 1 of 2 providers failed the check\n")
+
+		var results []checkResult
+		require.NoError(t, json.Unmarshal([]byte(output), &results))
+		require.Len(t, results, 2)
+		cloud, local := results[0], results[1]
+		assert.False(t, cloud.OK)
+		assert.Equal(t, stepModels, cloud.Step)
+		assert.Equal(t, "auth", cloud.Problem)
+		assert.Contains(t, cloud.Error, "Incorrect API key provided")
+		assert.Contains(t, cloud.Hint, "check the API key")
+
+		assert.True(t, local.OK)
+		assert.Equal(t, 2, local.Models)
+		// The chat model of the global model list is used, not the
+		// embedding model listed first
+		assert.Equal(t, "granite4:3b", local.Model)
+		assert.Equal(t, "granite4:3b", chatModel)
+	})
+
+	t.Run("ModelMissing", func(t *testing.T) {
+		checkCmd.Flags().Set("model", "qwen3")
+		defer checkCmd.Flags().Set("model", "")
+
+		var err error
+		output := captureOutput(func() {
+			err = checkCmd.RunE(checkCmd, []string{"local"})
+		})
+		assert.Error(t, err)
+		assert.Contains(t, output, "local (ollama): FAILED generating: model 'qwen3' isn't offered by the provider\n")
+		assert.Contains(t, output, "  Hint: see 'allmend provider model list local' for the models it offers\n")
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		env.WriteFile("config/providers.conf", "down:\n  type: ollama\n  config:\n    endpoint: http://127.0.0.1:1\n")
+		var err error
+		output := captureOutput(func() {
+			err = checkCmd.RunE(checkCmd, []string{"down"})
+		})
+		assert.Error(t, err)
+		assert.Contains(t, output, "down (ollama): FAILED listing models: ")
+		assert.Contains(t, output, "  Hint: the endpoint can't be reached")
+
+		err = checkCmd.RunE(checkCmd, []string{"missing"})
+		assert.EqualError(t, err, "Error: provider 'missing' not found\n")
+	})
+}
@@ -3,6 +3,11 @@ package providercmd
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/SUSE/allmend/cmd/allmend/modelcmd"
 	"github.com/SUSE/allmend/pkg/model"
@@ -43,7 +48,11 @@ var listCmd = &cobra.Command{
 var addModelCmd = &cobra.Command{
 	Use:   "add [PROVIDER] [MODEL_NAME]",
 	Short: "Add a model from a provider to the global model list",
-	Args:  cobra.RangeArgs(1, 2),
+	Long: `Add the model MODEL_NAME of PROVIDER, or all its models, to the global
+model list. If another provider already offers a model of the same name,
+the model is added under its provider-qualified name, like
+gpu-box/granite4:3b.`,
+	Args: cobra.RangeArgs(1, 2),
 	Run: func(cmd *cobra.Command, args []string) {
 		providerName := args[0]
 		ctx := context.Background()
@@ -51,11 +60,11 @@ var addModelCmd = &cobra.Command{
 		// If a specific model is requested
 		if len(args) == 2 {
 			modelName := args[1]
-			err := addModelToGlobalStore(providerName, modelName)
+			err := addModelToGlobalStore(ctx, providerName, modelName)
 			if err != nil {
 				fmt.Printf("Error adding model: %v\n", err)
 			} else {
-				fmt.Printf("Model '%s' added successfully.\n", modelName)
+				fmt.Printf("Model '%s/%s' added successfully.\n", providerName, modelName)
 			}
 			return
 		}
@@ -74,15 +83,49 @@ var addModelCmd = &cobra.Command{
 	},
 }
 
+var syncModelCmd = &cobra.Command{
+	Use:   "sync [PROVIDER]",
+	Short: "Sync the global model list with the models of a provider",
+	Long: `Add the models of a provider missing in the global model list and
+refresh the metadata of the ones already there. With --prune the models
+the provider no longer offers are removed, unless aliases, groups, other
+models or the default model setting still use them. The changes are listed with + for added,
+~ for updated and - for removed models, ! marks the models kept.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		providerName := args[0]
+		prune, _ := cmd.Flags().GetBool("prune")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		changes, err := syncModels(context.Background(), providerName, prune, dryRun)
+		if err != nil {
+			fmt.Printf("Error syncing models: %v\n", err)
+			return
+		}
+		if changes.empty() {
+			fmt.Println("Models are up to date.")
+			return
+		}
+		changes.print(os.Stdout)
+		if dryRun {
+			fmt.Println("Dry run, no changes were saved.")
+		}
+	},
+}
+
 func init() {
+	syncModelCmd.Flags().Bool("prune", false, "Remove models the provider no longer offers")
+	syncModelCmd.Flags().Bool("dry-run", false, "Only show what would change")
+
 	ProviderCmd.AddCommand(ModelCmd)
 	ModelCmd.AddCommand(listCmd)
 	ModelCmd.AddCommand(addModelCmd)
+	ModelCmd.AddCommand(syncModelCmd)
 }
 
 // Helper functions
 
-func getProviderModels(ctx context.Context, providerName string) ([]string, error) {
+func getProviderConnection(ctx context.Context, providerName string) (provider.ProviderConnection, error) {
 	path, err := GetProvidersFilePath()
 	if err != nil {
 		return nil, fmt.Errorf("determining providers file path: %w", err)
@@ -102,6 +145,14 @@ func getProviderModels(ctx context.Context, providerName string) ([]string, erro
 	if err != nil {
 		return nil, fmt.Errorf("connecting to provider '%s': %w", providerName, err)
 	}
+	return conn, nil
+}
+
+func getProviderModels(ctx context.Context, providerName string) ([]string, error) {
+	conn, err := getProviderConnection(ctx, providerName)
+	if err != nil {
+		return nil, err
+	}
 
 	models, err := conn.GetModells(ctx)
 	if err != nil {
@@ -111,7 +162,27 @@ func getProviderModels(ctx context.Context, providerName string) ([]string, erro
 	return models, nil
 }
 
-func addModelToGlobalStore(providerName, modelName string) error {
+// describeProviderModels returns the models of the provider together with
+// the metadata the provider reports, with the provider and type filled in.
+func describeProviderModels(ctx context.Context, providerName string) ([]model.Model, error) {
+	conn, err := getProviderConnection(ctx, providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	models, err := conn.DescribeModells(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching models from provider '%s': %w", providerName, err)
+	}
+
+	for i := range models {
+		models[i].Provider = providerName
+		models[i].Type = models[i].DefaultType()
+	}
+	return models, nil
+}
+
+func addModelToGlobalStore(ctx context.Context, providerName, modelName string) error {
 	modelsPath, err := modelcmd.GetModelsFilePath()
 	if err != nil {
 		return fmt.Errorf("determining models file path: %w", err)
@@ -122,60 +193,165 @@ func addModelToGlobalStore(providerName, modelName string) error {
 		return fmt.Errorf("loading models from %s: %w", modelsPath, err)
 	}
 
-	// Check if model already exists (by name)
-	// We use the modelName as the key and the Name field
-	if _, exists := modelStore.Items[modelName]; exists {
-		// Already exists, maybe update? For now, skip or error.
-		// User might want to overwrite? Let's assume skip/error for "add".
-		return fmt.Errorf("model '%s' already exists", modelName)
+	if _, exists := modelStore.KeyOf(providerName, modelName); exists {
+		return fmt.Errorf("model '%s/%s' already exists", providerName, modelName)
+	}
+
+	available, err := describeProviderModels(ctx, providerName)
+	if err != nil {
+		return err
 	}
 
+	// Models the provider doesn't list, e.g. ones that are pulled on first
+	// use, are still added, but without metadata.
 	newModel := model.Model{
 		Name:     modelName,
 		Provider: providerName,
-		// Type and Description could be fetched if the provider API supported it,
-		// but GetModells only returns strings currently.
-		Type: "chat", // Defaulting to chat? Or unknown?
+		Type:     "chat",
+	}
+	for _, m := range available {
+		if m.Name == modelName {
+			newModel = m
+			break
+		}
 	}
 
-	modelStore.Items[modelName] = newModel
+	modelStore.Add(newModel)
 	return modelStore.Save()
 }
 
+// modelChanges are the changes syncing the models of a provider makes to
+// the global model list.
+type modelChanges struct {
+	Added   []string
+	Updated []string
+	Removed []string
+	// Kept are the models which aren't offered anymore but still used by
+	// other models, with the models using them.
+	Kept []string
+}
+
+func (c modelChanges) empty() bool {
+	return len(c.Added) == 0 && len(c.Updated) == 0 && len(c.Removed) == 0 && len(c.Kept) == 0
+}
+
+// print writes the changes as a diff, one model per line.
+func (c modelChanges) print(w io.Writer) {
+	for _, name := range c.Added {
+		fmt.Fprintf(w, "+ %s\n", name)
+	}
+	for _, name := range c.Updated {
+		fmt.Fprintf(w, "~ %s\n", name)
+	}
+	for _, name := range c.Removed {
+		fmt.Fprintf(w, "- %s\n", name)
+	}
+	for _, kept := range c.Kept {
+		fmt.Fprintf(w, "! %s\n", kept)
+	}
+}
+
+// syncProviderModels adds the models of the provider missing in the global
+// model list and refreshes the metadata of the ones already there. It
+// returns the number of added models.
 func syncProviderModels(ctx context.Context, providerName string) (int, error) {
-	availableModels, err := getProviderModels(ctx, providerName)
+	changes, err := syncModels(ctx, providerName, false, false)
 	if err != nil {
 		return 0, err
 	}
+	return len(changes.Added), nil
+}
+
+// syncModels syncs the global model list with the models of the provider.
+// With prune the models of the provider it no longer offers are removed,
+// with dryRun the changes are only returned, not saved.
+func syncModels(ctx context.Context, providerName string, prune, dryRun bool) (modelChanges, error) {
+	var changes modelChanges
+	availableModels, err := describeProviderModels(ctx, providerName)
+	if err != nil {
+		return changes, err
+	}
 
 	modelsPath, err := modelcmd.GetModelsFilePath()
 	if err != nil {
-		return 0, fmt.Errorf("determining models file path: %w", err)
+		return changes, fmt.Errorf("determining models file path: %w", err)
 	}
 
 	modelStore, err := model.Load(modelsPath)
 	if err != nil {
-		return 0, fmt.Errorf("loading models from %s: %w", modelsPath, err)
+		return changes, fmt.Errorf("loading models from %s: %w", modelsPath, err)
 	}
 
-	addedCount := 0
-	for _, mName := range availableModels {
-		if _, exists := modelStore.Items[mName]; !exists {
-			newModel := model.Model{
-				Name:     mName,
-				Provider: providerName,
-				Type:     "chat", // Default
+	offered := make(map[string]bool)
+	for _, m := range availableModels {
+		offered[m.Name] = true
+		key, exists := modelStore.KeyOf(providerName, m.Name)
+		if !exists {
+			// Models of the same name from other providers are kept,
+			// the new one is added with its provider-qualified ID then.
+			modelStore.Add(m)
+			changes.Added = append(changes.Added, m.ID())
+			continue
+		}
+		// Keep the user's description, type and config, only the reported
+		// metadata is refreshed.
+		if existing := modelStore.Items[key]; !reflect.DeepEqual(existing.Info, m.Info) {
+			existing.Info = m.Info
+			modelStore.Items[key] = existing
+			changes.Updated = append(changes.Updated, m.ID())
+		}
+	}
+	if prune {
+		gone := make(map[string]bool)
+		for key, m := range modelStore.Items {
+			if m.Provider == providerName && !offered[m.Name] {
+				gone[key] = true
 			}
-			modelStore.Items[mName] = newModel
-			addedCount++
+		}
+		// Models still used by aliases, groups, as fallback or as default
+		// model are kept, they would fail at run time otherwise. Keeping a
+		// model keeps the models it uses in turn.
+		usedBy := func(key string) []string {
+			var users []string
+			if key == modelStore.DefaultModel {
+				users = append(users, "the default model")
+			}
+			for _, r := range modelStore.ReferencesTo(key) {
+				if !gone[r.Key] {
+					users = append(users, r.String())
+				}
+			}
+			return users
+		}
+		var kept []string
+		for changed := true; changed; {
+			changed = false
+			for key := range gone {
+				if len(usedBy(key)) > 0 {
+					delete(gone, key)
+					kept = append(kept, key)
+					changed = true
+				}
+			}
+		}
+		for _, key := range kept {
+			changes.Kept = append(changes.Kept, fmt.Sprintf("%s kept, still used by %s", modelStore.Items[key].ID(), strings.Join(usedBy(key), ", ")))
+		}
+		for key := range gone {
+			changes.Removed = append(changes.Removed, modelStore.Items[key].ID())
+			delete(modelStore.Items, key)
 		}
 	}
+	slices.Sort(changes.Added)
+	slices.Sort(changes.Updated)
+	slices.Sort(changes.Removed)
+	slices.Sort(changes.Kept)
 
-	if addedCount > 0 {
+	if !dryRun && !changes.empty() {
 		if err := modelStore.Save(); err != nil {
-			return 0, fmt.Errorf("saving models: %w", err)
+			return changes, fmt.Errorf("saving models: %w", err)
 		}
 	}
 
-	return addedCount, nil
+	return changes, nil
 }
@@ -1,10 +1,17 @@
 package providercmd
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/SUSE/allmend/internal/testenv"
+	"github.com/SUSE/allmend/pkg/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestModelList(t *testing.T) {
@@ -50,6 +57,141 @@ func TestModelAdd(t *testing.T) {
 
 		assert.Contains(t, output, "provider 'non-existent' not found")
 	})
+
+	t.Run("SyncStoresMetadata", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/tags":
+				fmt.Fprint(w, `{"models": [{"name": "granite4:3b", "details": {"family": "granite", "parameter_size": "3.4B"}}, {"name": "nomic-embed-text"}]}`)
+			case "/api/show":
+				var req map[string]string
+				json.NewDecoder(r.Body).Decode(&req)
+				if req["model"] == "nomic-embed-text" {
+					fmt.Fprint(w, `{"capabilities": ["embedding"]}`)
+					return
+				}
+				fmt.Fprint(w, `{"capabilities": ["completion", "tools"], "model_info": {"general.architecture": "granite", "granite.context_length": 131072}}`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer srv.Close()
+		env.WriteFile("config/providers.conf", "local:\n  type: ollama\n  config:\n    endpoint: "+srv.URL+"\n")
+		env.WriteFile("config/modells.yaml", "granite4:3b:\n  name: granite4:3b\n  description: my granite\n  provider: local\n")
+
+		output := captureOutput(func() {
+			addModelCmd.Run(addModelCmd, []string{"local"})
+		})
+		assert.Contains(t, output, "Successfully added 1 models.")
+
+		store, err := model.Load(env.GetPath("config/modells.yaml"))
+		require.NoError(t, err)
+		granite := store.Items["granite4:3b"]
+		assert.Equal(t, "my granite", granite.Description)
+		assert.Equal(t, "granite", granite.Family)
+		assert.Equal(t, 131072, granite.ContextLength)
+		assert.True(t, granite.Can(model.CapabilityTools))
+		embed := store.Items["nomic-embed-text"]
+		assert.Equal(t, "embedding", embed.Type)
+		assert.Equal(t, "local", embed.Provider)
+	})
+
+	t.Run("SyncPrune", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/tags":
+				fmt.Fprint(w, `{"models": [{"name": "granite4:3b"}]}`)
+			case "/api/show":
+				fmt.Fprint(w, `{"capabilities": ["completion"]}`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer srv.Close()
+		env.WriteFile("config/providers.conf", "local:\n  type: ollama\n  config:\n    endpoint: "+srv.URL+"\n")
+		env.WriteFile("config/modells.yaml", "old:\n  provider: local\nremote:\n  provider: other\ngranite4:3b:\n  provider: local\n")
+
+		syncModelCmd.Flags().Set("prune", "true")
+		syncModelCmd.Flags().Set("dry-run", "true")
+		output := captureOutput(func() {
+			syncModelCmd.Run(syncModelCmd, []string{"local"})
+		})
+		assert.Equal(t, "~ local/granite4:3b\n- local/old\nDry run, no changes were saved.\n", output)
+		store, err := model.Load(env.GetPath("config/modells.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, store.Items, "old")
+
+		syncModelCmd.Flags().Set("dry-run", "false")
+		defer syncModelCmd.Flags().Set("prune", "false")
+		output = captureOutput(func() {
+			syncModelCmd.Run(syncModelCmd, []string{"local"})
+		})
+		assert.Equal(t, "~ local/granite4:3b\n- local/old\n", output)
+		store, err = model.Load(env.GetPath("config/modells.yaml"))
+		require.NoError(t, err)
+		assert.NotContains(t, store.Items, "old")
+		assert.Contains(t, store.Items, "remote")
+		assert.True(t, store.Items["granite4:3b"].Can(model.CapabilityCompletion))
+
+		output = captureOutput(func() {
+			syncModelCmd.Run(syncModelCmd, []string{"local"})
+		})
+		assert.Equal(t, "Models are up to date.\n", output)
+
+		// Models still used by other models are kept
+		env.WriteFile("config/modells.yaml", "old:\n  provider: local\nolder:\n  provider: local\n  fallback: [old]\ncoder:\n  alias: local/older\ngranite4:3b:\n  provider: local\n")
+		output = captureOutput(func() {
+			syncModelCmd.Run(syncModelCmd, []string{"local"})
+		})
+		assert.Equal(t, "~ local/granite4:3b\n! local/old kept, still used by fallback of 'local/older'\n! local/older kept, still used by alias 'coder'\n", output)
+		store, err = model.Load(env.GetPath("config/modells.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, store.Items, "old")
+		assert.Contains(t, store.Items, "older")
+
+		// The default model is kept as well
+		env.WriteFile("config/modells.yaml", "old:\n  provider: local\ngranite4:3b:\n  provider: local\ndefault: old\n")
+		output = captureOutput(func() {
+			syncModelCmd.Run(syncModelCmd, []string{"local"})
+		})
+		assert.Equal(t, "~ local/granite4:3b\n! local/old kept, still used by the default model\n", output)
+		store, err = model.Load(env.GetPath("config/modells.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, store.Items, "old")
+		assert.Equal(t, "old", store.DefaultModel)
+	})
+
+	t.Run("SyncSameModelFromTwoHosts", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/tags":
+				fmt.Fprint(w, `{"models": [{"name": "granite4:3b"}]}`)
+			case "/api/show":
+				fmt.Fprint(w, `{"capabilities": ["completion"]}`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer srv.Close()
+		env.WriteFile("config/providers.conf", "local:\n  type: ollama\n  config:\n    endpoint: "+srv.URL+"\ngpu-box:\n  type: ollama\n  config:\n    endpoint: "+srv.URL+"\n")
+		env.WriteFile("config/modells.yaml", "")
+
+		for _, name := range []string{"local", "gpu-box", "gpu-box"} {
+			_, err := syncModels(context.Background(), name, false, false)
+			require.NoError(t, err)
+		}
+
+		store, err := model.Load(env.GetPath("config/modells.yaml"))
+		require.NoError(t, err)
+		assert.Len(t, store.Items, 2)
+		assert.Equal(t, "local", store.Items["granite4:3b"].Provider)
+		assert.Equal(t, "gpu-box", store.Items["gpu-box/granite4:3b"].Provider)
+
+		output := captureOutput(func() {
+			addModelCmd.Run(addModelCmd, []string{"gpu-box", "granite4:3b"})
+		})
+		assert.Contains(t, output, "model 'gpu-box/granite4:3b' already exists")
+	})
 }
 
 // captureOutput captures stdout/stderr. 
@@ -3,12 +3,11 @@ package providercmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"text/template"
 
+	"github.com/SUSE/allmend/internal/config"
 	"github.com/SUSE/allmend/pkg/provider"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var ProviderCmd = &cobra.Command{
@@ -17,22 +16,10 @@ var ProviderCmd = &cobra.Command{
 	Long:  `List and manage available model providers.`,
 }
 
-// GetProvidersFilePath determines the path to the providers configuration file.
+// GetProvidersFilePath determines the path to the providers configuration
+// file, see config.ProvidersFilePath.
 func GetProvidersFilePath() (string, error) {
-	// 1. Check if configured explicitly in allmend.conf
-	if path := viper.GetString("providers_file"); path != "" {
-		return path, nil
-	}
-
-	// 2. Default: Same directory as allmend.conf, named "providers.conf"
-	configFile := viper.ConfigFileUsed()
-	if configFile == "" {
-		// Fallback
-		return filepath.Join("config", "providers.conf"), nil
-	}
-
-	configDir := filepath.Dir(configFile)
-	return filepath.Join(configDir, "providers.conf"), nil
+	return config.ProvidersFilePath(), nil
 }
 
 var listProvidersCmd = &cobra.Command{
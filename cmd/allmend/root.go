@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"github.com/SUSE/allmend/cmd/allmend/agentcmd"
 	"github.com/SUSE/allmend/cmd/allmend/modelcmd"
 	"github.com/SUSE/allmend/cmd/allmend/providercmd"
+	"github.com/SUSE/allmend/cmd/allmend/toolcmd"
 	"github.com/SUSE/allmend/internal/config"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -25,8 +27,14 @@ var (
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() {
+	// Types registered by packages initialized after providercmd
+	providercmd.AddTypeCommands()
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
+		var exitErr interface{ ExitCode() int }
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
 		os.Exit(1)
 	}
 }
@@ -39,6 +47,7 @@ func init() {
 	rootCmd.AddCommand(agentcmd.AgentCmd)
 	rootCmd.AddCommand(modelcmd.ModelCmd)
 	rootCmd.AddCommand(providercmd.ProviderCmd)
+	rootCmd.AddCommand(toolcmd.ToolCmd)
 }
 
 // initConfig reads in config file and ENV variables if set.
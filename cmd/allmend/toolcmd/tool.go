@@ -0,0 +1,83 @@
+package toolcmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/SUSE/allmend/pkg/tools"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var ToolCmd = &cobra.Command{
+	Use:   "tool",
+	Short: "Manage MCP tools",
+	Long:  `List the MCP servers in the tools registry which agents can use as tools.`,
+}
+
+// GetToolsFilePath determines the path to the tools registry file.
+func GetToolsFilePath() (string, error) {
+	// 1. Check if configured explicitly in allmend.conf
+	if path := viper.GetString("tools_file"); path != "" {
+		return path, nil
+	}
+
+	// 2. Default: Same directory as allmend.conf, named "tools.conf"
+	configFile := viper.ConfigFileUsed()
+	if configFile == "" {
+		// Fallback
+		return filepath.Join("config", "tools.conf"), nil
+	}
+
+	configDir := filepath.Dir(configFile)
+	return filepath.Join(configDir, "tools.conf"), nil
+}
+
+var listToolsCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List configured MCP servers",
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := GetToolsFilePath()
+		if err != nil {
+			fmt.Printf("Error determining tools file path: %v\n", err)
+			return
+		}
+
+		store, err := tools.Load(path)
+		if err != nil {
+			fmt.Printf("Error loading tools from %s: %v\n", path, err)
+			return
+		}
+
+		servers := store.List()
+		if len(servers) == 0 {
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				fmt.Printf("No tools configured (file %s does not exist).\n", path)
+				return
+			}
+			fmt.Println("No tools configured.")
+			return
+		}
+
+		format, _ := cmd.Flags().GetString("format")
+		tmpl, err := template.New("list").Parse(format)
+		if err != nil {
+			fmt.Printf("Error parsing template: %v\n", err)
+			return
+		}
+
+		for _, s := range servers {
+			if err := tmpl.Execute(os.Stdout, s); err != nil {
+				fmt.Printf("Error executing template: %v\n", err)
+			}
+		}
+	},
+}
+
+func init() {
+	listToolsCmd.Flags().String("format", "- {{.Name}}: {{.Type}} {{if .URL}}{{.URL}}{{else}}{{.Command}}{{end}}\n", "Format string for listing tools")
+	ToolCmd.AddCommand(listToolsCmd)
+}
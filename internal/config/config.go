@@ -3,7 +3,10 @@ package config
 import (
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
+
+	"github.com/spf13/viper"
 )
 
 // ConfigLocations returns default configuration locations.
@@ -18,6 +21,19 @@ func ConfigLocations(names ...string) []string {
 	return paths
 }
 
+// ProvidersFilePath returns the path of the providers file, as configured
+// with providers_file in allmend.conf or providers.conf next to it.
+func ProvidersFilePath() string {
+	if path := viper.GetString("providers_file"); path != "" {
+		return path
+	}
+	configFile := viper.ConfigFileUsed()
+	if configFile == "" {
+		return filepath.Join("config", "providers.conf")
+	}
+	return filepath.Join(filepath.Dir(configFile), "providers.conf")
+}
+
 // GetEnvOrFile checks environment variable first, then .env file in the current directory.
 func GetEnvOrFile(key string) string {
 	if v := os.Getenv(key); v != "" {
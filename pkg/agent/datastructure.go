@@ -8,12 +8,20 @@ type Agent struct {
 	Name string `json:"name" yaml:"name"`
 	// description of what the agent does
 	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// preferred model of the agent, overrides the default model
+	Model string `json:"model,omitempty" yaml:"model,omitempty"`
+	// models tried in order when the model fails
+	Fallback []string `json:"fallback,omitempty" yaml:"fallback,omitempty"`
 	// The principel manifest of how the agent acts
 	Manifest *AgentManifest `json:"manifest" yaml:"manifest" spec:"manifest"`
 	// concrete mission of the agent
 	Mission *AgentMission `json:"mission" yaml:"mission"`
 	// needed and recommended tools
 	Tools *AgentTools `json:"tools,omitempty" yaml:"tools,omitempty"`
+	// variables which can be used in the manifest and mission
+	Variables *VariableList `json:"variables,omitempty" yaml:"variables,omitempty"`
+	// generation parameters, overriding the ones of the model
+	Parameters map[string]string `json:"parameters,omitempty" yaml:"parameters,omitempty"`
 	// metdata of the agent
 	Meta *AgentMeta `json:"meta,omitempty" yaml:"meta,omitempty"`
 }
@@ -43,14 +51,17 @@ type MCPTools struct {
 
 type VariableList struct {
 	// List all the variables which can be used for the mission
-	List map[string]Variable
+	List map[string]Variable `yaml:",inline"`
 }
 
 type Variable struct {
-	// variable which can be used inside the mission, like the target of mission
-	Value string
+	// default value of the variable which can be used inside the mission,
+	// like the target of mission. A variable without a default is required.
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
 	// define the type of variable, like string, number ip address
-	Type string
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+	// allowed values of an enum variable
+	Values []string `json:"values,omitempty" yaml:"values,omitempty"`
 }
 
 type AgentMission struct {
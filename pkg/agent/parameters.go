@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SUSE/allmend/pkg/model"
+)
+
+// parseParametersLines parses the %Parameters section. Every line sets a
+// generation parameter as "key: value", overriding the parameter of the
+// model. Lines starting with '#' are comments.
+//
+//	%Parameters
+//	temperature: 0.2
+//	max_output_tokens: 2048
+func parseParametersLines(content string, agent *Agent) error {
+	for n, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("parameters line %d: expected 'key: value', got %q", n+1, line)
+		}
+		value = strings.TrimSpace(value)
+		if _, err := model.ParseParams(map[string]interface{}{key: value}); err != nil {
+			return fmt.Errorf("parameters line %d: %w", n+1, err)
+		}
+		if agent.Parameters == nil {
+			agent.Parameters = make(map[string]string)
+		}
+		agent.Parameters[key] = value
+	}
+	return nil
+}
+
+// Params returns the generation parameters of the agent.
+func (a *Agent) Params() (model.Params, error) {
+	values := make(map[string]interface{}, len(a.Parameters))
+	for k, v := range a.Parameters {
+		values[k] = v
+	}
+	p, err := model.ParseParams(values)
+	if err != nil {
+		return model.Params{}, fmt.Errorf("agent '%s': %w", a.Name, err)
+	}
+	return p, nil
+}
+
+// parameterKeys returns the sorted keys of the parameters of the agent.
+func (a *Agent) parameterKeys() []string {
+	keys := make([]string, 0, len(a.Parameters))
+	for k := range a.Parameters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -3,6 +3,7 @@ package agent
 import (
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 
 	"github.com/alecthomas/participle/v2"
@@ -69,10 +70,24 @@ func ParseAgent(r io.Reader) (*Agent, error) {
 		case "Description":
 			agent.Description = content
 		case "Tools":
-			// Placeholder
+			if err := parseToolsLines(content, agent); err != nil {
+				return nil, err
+			}
+		case "Variables":
+			if err := parseVariablesLines(content, agent); err != nil {
+				return nil, err
+			}
+		case "Parameters":
+			if err := parseParametersLines(content, agent); err != nil {
+				return nil, err
+			}
 		}
 	}
 
+	if len(agent.Tools.Required) == 0 && len(agent.Tools.Recommended) == 0 {
+		agent.Tools = nil
+	}
+
 	return agent, nil
 }
 
@@ -91,6 +106,14 @@ func parseMetaLines(content string, agent *Agent) {
 			agent.Name = val
 		case "description":
 			agent.Description = val
+		case "model":
+			agent.Model = val
+		case "fallback":
+			for _, ref := range strings.Split(val, ",") {
+				if ref = strings.TrimSpace(ref); ref != "" {
+					agent.Fallback = append(agent.Fallback, ref)
+				}
+			}
 		case "author":
 			agent.Meta.Author = val
 		case "version":
@@ -98,3 +121,71 @@ func parseMetaLines(content string, agent *Agent) {
 		}
 	}
 }
+
+// parseToolsLines parses the %Tools section. Every tool starts with a
+// "Required:" or "Recommended:" line naming the tool, the following
+// "Version:", "ReadOnly:" and "Key:" lines apply to the last named tool.
+// Key may be given multiple times, a signature by any of the keys is
+// accepted. For stdio servers the signature covers the executable only, not
+// scripts or files passed as its arguments. Lines starting with '#' are
+// comments.
+//
+//	%Tools
+//	Required: filesystem
+//	  Version: >=1.2.0, <2
+//	  ReadOnly: true
+//	  Key: ed25519:6b1f...
+//	Recommended: websearch
+func parseToolsLines(content string, agent *Agent) error {
+	var current *MCPTools
+	for n, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("tools line %d: expected 'Key: value', got %q", n+1, line)
+		}
+		key := strings.TrimSpace(strings.ToLower(parts[0]))
+		val := strings.TrimSpace(parts[1])
+
+		switch key {
+		case "required", "recommended":
+			if val == "" {
+				return fmt.Errorf("tools line %d: missing tool name", n+1)
+			}
+			current = &MCPTools{Name: val}
+			if key == "required" {
+				agent.Tools.Required = append(agent.Tools.Required, current)
+			} else {
+				agent.Tools.Recommended = append(agent.Tools.Recommended, current)
+			}
+			continue
+		}
+
+		if current == nil {
+			return fmt.Errorf("tools line %d: %q before any Required or Recommended tool", n+1, parts[0])
+		}
+		switch key {
+		case "version":
+			if _, err := ParseConstraint(val); err != nil {
+				return fmt.Errorf("tools line %d: %w", n+1, err)
+			}
+			current.Version = val
+		case "readonly", "read_only", "read-only":
+			ro, err := strconv.ParseBool(val)
+			if err != nil {
+				return fmt.Errorf("tools line %d: invalid ReadOnly value %q", n+1, val)
+			}
+			current.ReadOnly = ro
+		case "key", "keys":
+			for _, k := range strings.Fields(val) {
+				current.Keys = append(current.Keys, k)
+			}
+		default:
+			return fmt.Errorf("tools line %d: unknown tool attribute %q", n+1, parts[0])
+		}
+	}
+	return nil
+}
@@ -1,13 +1,19 @@
 package agent
 
 import (
+	"bytes"
+	"encoding/json"
 	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
 )
 
 const exampleAgent = `%Meta
 Name: TestAgent
 Version: 1.0.0
+Model: granite4:3b
 %Manifest
 This is a test manifest.
 It has multiple lines.
@@ -32,6 +38,9 @@ func TestParseAgent(t *testing.T) {
 	if agent.Meta.Version != "1.0.0" {
 		t.Errorf("Expected Version '1.0.0', got '%s'", agent.Meta.Version)
 	}
+	if agent.Model != "granite4:3b" {
+		t.Errorf("Expected Model 'granite4:3b', got '%s'", agent.Model)
+	}
 
 	expectedManifest := `This is a test manifest.
 It has multiple lines.
@@ -48,3 +57,116 @@ And an empty line.`
 		t.Errorf("Mission content mismatch. Got: %q", agent.Mission.Content)
 	}
 }
+
+const toolsAgent = `%Meta
+Name: ToolAgent
+Version: 1.0.0
+Model: qwen3
+Fallback: gpu-box/qwen3, granite4:3b
+%Mission
+Use the tools.
+%Tools
+# filesystem access is mandatory
+Required: filesystem
+  Version: >=1.2.0, <2
+  ReadOnly: true
+  Key: ed25519:AAAA
+  Key: ed25519:BBBB
+Required: shell
+Recommended: websearch
+  Version: ^2.1
+%Parameters
+# deterministic answers
+temperature: 0
+max_output_tokens: 1024
+`
+
+func TestParseAgentTools(t *testing.T) {
+	agent, err := ParseAgent(strings.NewReader(toolsAgent))
+	if err != nil {
+		t.Fatalf("ParseAgent failed: %v", err)
+	}
+	if agent.Tools == nil {
+		t.Fatal("Expected tools to be parsed")
+	}
+	if len(agent.Tools.Required) != 2 || len(agent.Tools.Recommended) != 1 {
+		t.Fatalf("Expected 2 required and 1 recommended tool, got %d and %d",
+			len(agent.Tools.Required), len(agent.Tools.Recommended))
+	}
+	fs := agent.Tools.Required[0]
+	if fs.Name != "filesystem" || fs.Version != ">=1.2.0, <2" || !fs.ReadOnly {
+		t.Errorf("Unexpected filesystem tool: %+v", fs)
+	}
+	if len(fs.Keys) != 2 || fs.Keys[1] != "ed25519:BBBB" {
+		t.Errorf("Unexpected keys: %v", fs.Keys)
+	}
+	if sh := agent.Tools.Required[1]; sh.Name != "shell" || sh.ReadOnly || sh.Version != "" {
+		t.Errorf("Unexpected shell tool: %+v", sh)
+	}
+	if ws := agent.Tools.Recommended[0]; ws.Name != "websearch" || ws.Version != "^2.1" {
+		t.Errorf("Unexpected websearch tool: %+v", ws)
+	}
+	assert.Equal(t, []string{"gpu-box/qwen3", "granite4:3b"}, agent.Fallback)
+	assert.Equal(t, map[string]string{"temperature": "0", "max_output_tokens": "1024"}, agent.Parameters)
+	params, err := agent.Params()
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, *params.Temperature)
+	assert.Equal(t, 1024, params.MaxOutputTokens)
+
+	_, err = ParseAgent(strings.NewReader("%Meta\nName: Bad\n%Parameters\ntemperature: hot\n"))
+	assert.ErrorContains(t, err, "parameters line 1: invalid value 'hot' of parameter 'temperature'")
+}
+
+func TestParseAgentToolsErrors(t *testing.T) {
+	for name, tools := range map[string]string{
+		"AttributeBeforeTool": "Version: 1.0\n",
+		"BadVersion":          "Required: fs\nVersion: >=one\n",
+		"BadReadOnly":         "Required: fs\nReadOnly: maybe\n",
+		"UnknownAttribute":    "Required: fs\nColour: blue\n",
+		"MissingName":         "Required:\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseAgent(strings.NewReader("%Meta\nName: Broken\n%Tools\n" + tools))
+			if err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestWriteAgentRoundTrip(t *testing.T) {
+	orig, err := ParseAgent(strings.NewReader(toolsAgent))
+	if err != nil {
+		t.Fatalf("ParseAgent failed: %v", err)
+	}
+
+	var agt bytes.Buffer
+	if err := WriteAgent(&agt, orig); err != nil {
+		t.Fatalf("WriteAgent failed: %v", err)
+	}
+	fromAgt, err := ParseAgent(&agt)
+	if err != nil {
+		t.Fatalf("ParseAgent of written agent failed: %v", err)
+	}
+	assert.Equal(t, orig, fromAgt)
+
+	jsonBytes, err := json.Marshal(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON Agent
+	if err := json.Unmarshal(jsonBytes, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, orig, &fromJSON)
+
+	yamlBytes, err := yaml.Marshal(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromYAML Agent
+	if err := yaml.Unmarshal(yamlBytes, &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, orig, &fromYAML)
+}
@@ -0,0 +1,32 @@
+package agent
+
+import "strings"
+
+// SystemInstruction composes the system instruction which is sent to the
+// model when the agent is run. The parts are joined in this order, empty
+// parts are skipped:
+//
+//  1. the Manifest, verbatim, as it defines the general behavior
+//  2. the Description under a "# Description" heading, describing the role
+//  3. the Mission under a "# Mission" heading, as the concrete task comes last
+//
+// The {{name}} placeholders of the variables are substituted in the parts
+// by WithVariables, so it is called on the agent returned by it. No further
+// template substitution is done on the result.
+func (a *Agent) SystemInstruction() string {
+	var parts []string
+	if a.Manifest != nil {
+		if c := strings.TrimSpace(a.Manifest.Content); c != "" {
+			parts = append(parts, c)
+		}
+	}
+	if d := strings.TrimSpace(a.Description); d != "" {
+		parts = append(parts, "# Description\n"+d)
+	}
+	if a.Mission != nil {
+		if c := strings.TrimSpace(a.Mission.Content); c != "" {
+			parts = append(parts, "# Mission\n"+c)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
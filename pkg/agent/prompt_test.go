@@ -0,0 +1,23 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemInstruction(t *testing.T) {
+	a := &Agent{
+		Name:        "Prompted",
+		Description: "Checks things.",
+		Manifest:    &AgentManifest{Content: "Be honest.\n"},
+		Mission:     &AgentMission{Content: "\nCheck the {target}.\n"},
+	}
+	assert.Equal(t, "Be honest.\n\n# Description\nChecks things.\n\n# Mission\nCheck the {target}.", a.SystemInstruction())
+
+	a.Description = ""
+	a.Manifest = nil
+	assert.Equal(t, "# Mission\nCheck the {target}.", a.SystemInstruction())
+
+	assert.Equal(t, "", (&Agent{}).SystemInstruction())
+}
@@ -0,0 +1,249 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Supported variable types.
+const (
+	VarString = "string"
+	VarNumber = "number"
+	VarIP     = "ip"
+	VarPath   = "path"
+	VarEnum   = "enum"
+)
+
+var (
+	variableName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.-]*$`)
+	placeholder  = regexp.MustCompile(`{{\s*([^{}\s]*)\s*}}`)
+)
+
+// MarshalJSON writes the variables as a plain object keyed by name.
+func (l VariableList) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.List)
+}
+
+// UnmarshalJSON reads the variables from a plain object keyed by name.
+func (l *VariableList) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &l.List)
+}
+
+// Names returns the sorted variable names.
+func (l *VariableList) Names() []string {
+	if l == nil {
+		return nil
+	}
+	names := make([]string, 0, len(l.List))
+	for n := range l.List {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// normalizeType maps the accepted spellings of a type to its canonical name.
+func normalizeType(t string) (string, error) {
+	switch strings.ToLower(strings.Join(strings.Fields(t), " ")) {
+	case "", "string", "text":
+		return VarString, nil
+	case "number", "int", "integer", "float":
+		return VarNumber, nil
+	case "ip", "ip address", "ip_address", "ipaddress", "address":
+		return VarIP, nil
+	case "path", "file":
+		return VarPath, nil
+	case "enum":
+		return VarEnum, nil
+	default:
+		return "", fmt.Errorf("unknown variable type %q", t)
+	}
+}
+
+// Check validates value against the type of the variable.
+func (v Variable) Check(value string) error {
+	typ, err := normalizeType(v.Type)
+	if err != nil {
+		return err
+	}
+	switch typ {
+	case VarNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+	case VarIP:
+		if net.ParseIP(value) == nil {
+			return fmt.Errorf("%q is not an ip address", value)
+		}
+	case VarPath:
+		if value == "" || strings.ContainsRune(value, 0) {
+			return fmt.Errorf("%q is not a valid path", value)
+		}
+	case VarEnum:
+		for _, allowed := range v.Values {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", value, strings.Join(v.Values, ", "))
+	}
+	return nil
+}
+
+// Resolve merges the given values with the defaults of the variables and
+// validates the result. It fails if a value is given for an undeclared
+// variable, a variable without default is missing or a value doesn't
+// match the type of its variable.
+func (l *VariableList) Resolve(values map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string)
+	var declared map[string]Variable
+	if l != nil {
+		declared = l.List
+	}
+	for name := range values {
+		if _, ok := declared[name]; !ok {
+			return nil, fmt.Errorf("unknown variable '%s'", name)
+		}
+	}
+	var missing []string
+	for _, name := range l.Names() {
+		v := declared[name]
+		val, ok := values[name]
+		if !ok {
+			if v.Value == "" {
+				missing = append(missing, name)
+				continue
+			}
+			val = v.Value
+		}
+		if err := v.Check(val); err != nil {
+			return nil, fmt.Errorf("variable '%s': %w", name, err)
+		}
+		resolved[name] = val
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required variables: %s", strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
+
+// Substitute replaces the {{name}} placeholders of the variables in values
+// with their values. Other text in double braces, like {{}} or the
+// placeholders of a template the agent talks about, is left untouched.
+func Substitute(text string, values map[string]string) string {
+	return placeholder.ReplaceAllStringFunc(text, func(m string) string {
+		if val, ok := values[placeholder.FindStringSubmatch(m)[1]]; ok {
+			return val
+		}
+		return m
+	})
+}
+
+// WithVariables returns a copy of the agent where the {{name}} placeholders
+// of its variables in the manifest, mission and description are replaced.
+// The values are resolved against the declared variables with Resolve.
+func (a *Agent) WithVariables(values map[string]string) (*Agent, error) {
+	resolved, err := a.Variables.Resolve(values)
+	if err != nil {
+		return nil, err
+	}
+	out := *a
+	out.Description = Substitute(a.Description, resolved)
+	if a.Manifest != nil {
+		m := *a.Manifest
+		m.Content = Substitute(m.Content, resolved)
+		out.Manifest = &m
+	}
+	if a.Mission != nil {
+		m := *a.Mission
+		m.Content = Substitute(m.Content, resolved)
+		out.Mission = &m
+	}
+	return &out, nil
+}
+
+// ParseVarAssignments parses "key=value" strings, like given on the command line.
+func ParseVarAssignments(assignments []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, a := range assignments {
+		parts := strings.SplitN(a, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid variable assignment %q, expected key=value", a)
+		}
+		values[strings.TrimSpace(parts[0])] = parts[1]
+	}
+	return values, nil
+}
+
+// parseVariablesLines parses the %Variables section. Every line declares
+// one variable as "name: type" with an optional "= default". Enum
+// variables list their allowed values as "enum(a|b|c)". Lines starting
+// with '#' are comments.
+//
+//	%Variables
+//	target: ip
+//	port: number = 22
+//	mode: enum(scan|audit) = scan
+func parseVariablesLines(content string, agent *Agent) error {
+	for n, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || !variableName.MatchString(name) {
+			return fmt.Errorf("variables line %d: expected 'name: type [= default]', got %q", n+1, line)
+		}
+		var v Variable
+		typ, def, hasDefault := strings.Cut(parts[1], "=")
+		typ = strings.TrimSpace(typ)
+		if open := strings.Index(typ, "("); open >= 0 && strings.HasSuffix(typ, ")") {
+			for _, val := range strings.Split(typ[open+1:len(typ)-1], "|") {
+				if val = strings.TrimSpace(val); val != "" {
+					v.Values = append(v.Values, val)
+				}
+			}
+			typ = strings.TrimSpace(typ[:open])
+		}
+		norm, err := normalizeType(typ)
+		if err != nil {
+			return fmt.Errorf("variables line %d: %w", n+1, err)
+		}
+		if norm == VarEnum && len(v.Values) == 0 {
+			return fmt.Errorf("variables line %d: enum '%s' has no values", n+1, name)
+		}
+		v.Type = norm
+		if hasDefault {
+			v.Value = strings.TrimSpace(def)
+			if err := v.Check(v.Value); err != nil {
+				return fmt.Errorf("variables line %d: default of '%s': %w", n+1, name, err)
+			}
+		}
+		if agent.Variables == nil {
+			agent.Variables = &VariableList{List: make(map[string]Variable)}
+		}
+		agent.Variables.List[name] = v
+	}
+	return nil
+}
+
+// formatVariable returns the .agt declaration of a variable.
+func formatVariable(name string, v Variable) string {
+	typ := v.Type
+	if typ == "" {
+		typ = VarString
+	}
+	if len(v.Values) > 0 {
+		typ += "(" + strings.Join(v.Values, "|") + ")"
+	}
+	if v.Value != "" {
+		return fmt.Sprintf("%s: %s = %s", name, typ, v.Value)
+	}
+	return fmt.Sprintf("%s: %s", name, typ)
+}
@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const variablesAgent = `%Meta
+Name: ScanAgent
+%Variables
+# the host to scan
+target: ip address
+port: number = 22
+logdir: path = /var/log
+mode: enum(scan|audit) = scan
+note: string
+%Mission
+{{mode}} {{ target }}:{{port}} and write to {{logdir}}. {{note}}
+`
+
+func TestParseVariables(t *testing.T) {
+	a, err := ParseAgent(strings.NewReader(variablesAgent))
+	require.NoError(t, err)
+	require.NotNil(t, a.Variables)
+
+	assert.Equal(t, []string{"logdir", "mode", "note", "port", "target"}, a.Variables.Names())
+	assert.Equal(t, Variable{Type: VarIP}, a.Variables.List["target"])
+	assert.Equal(t, Variable{Type: VarNumber, Value: "22"}, a.Variables.List["port"])
+	assert.Equal(t, Variable{Type: VarEnum, Value: "scan", Values: []string{"scan", "audit"}}, a.Variables.List["mode"])
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteAgent(&buf, a))
+	again, err := ParseAgent(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, a.Variables, again.Variables)
+
+	jsonBytes, err := json.Marshal(a)
+	require.NoError(t, err)
+	assert.Contains(t, string(jsonBytes), `"variables":{"logdir":{"value":"/var/log","type":"path"}`)
+	var fromJSON Agent
+	require.NoError(t, json.Unmarshal(jsonBytes, &fromJSON))
+	assert.Equal(t, a.Variables, fromJSON.Variables)
+
+	yamlBytes, err := yaml.Marshal(a)
+	require.NoError(t, err)
+	var fromYAML Agent
+	require.NoError(t, yaml.Unmarshal(yamlBytes, &fromYAML))
+	assert.Equal(t, a.Variables, fromYAML.Variables)
+}
+
+func TestParseVariablesErrors(t *testing.T) {
+	for name, vars := range map[string]string{
+		"UnknownType":  "x: colour\n",
+		"BadDefault":   "x: number = many\n",
+		"EmptyEnum":    "x: enum\n",
+		"BadName":      "1x: string\n",
+		"NoType":       "x\n",
+		"EnumDefault":  "x: enum(a|b) = c\n",
+		"IPDefault":    "x: ip = 300.1.1.1\n",
+		"PathWithNull": "x: path = a\x00b\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseAgent(strings.NewReader("%Meta\nName: Broken\n%Variables\n" + vars))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestWithVariables(t *testing.T) {
+	a, err := ParseAgent(strings.NewReader(variablesAgent))
+	require.NoError(t, err)
+
+	t.Run("Defaults", func(t *testing.T) {
+		out, err := a.WithVariables(map[string]string{"target": "10.0.0.1", "note": "Be quick."})
+		require.NoError(t, err)
+		assert.Equal(t, "scan 10.0.0.1:22 and write to /var/log. Be quick.", out.Mission.Content)
+		// the original is left untouched
+		assert.Contains(t, a.Mission.Content, "{{ target }}")
+	})
+
+	t.Run("Override", func(t *testing.T) {
+		out, err := a.WithVariables(map[string]string{"target": "::1", "port": "8080", "mode": "audit", "note": ""})
+		require.NoError(t, err)
+		assert.Equal(t, "audit ::1:8080 and write to /var/log. ", out.Mission.Content)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := a.WithVariables(nil)
+		assert.EqualError(t, err, "missing required variables: note, target")
+	})
+
+	t.Run("WrongType", func(t *testing.T) {
+		_, err := a.WithVariables(map[string]string{"target": "example.com", "note": ""})
+		assert.ErrorContains(t, err, "variable 'target'")
+		_, err = a.WithVariables(map[string]string{"target": "::1", "note": "", "mode": "attack"})
+		assert.ErrorContains(t, err, "variable 'mode'")
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := a.WithVariables(map[string]string{"target": "::1", "note": "", "colour": "red"})
+		assert.EqualError(t, err, "unknown variable 'colour'")
+	})
+
+	t.Run("Undeclared", func(t *testing.T) {
+		b := *a
+		b.Mission = &AgentMission{Content: "Scan {{target}}, fill in {{ host }} and {{}} in {{template.name}}."}
+		out, err := b.WithVariables(map[string]string{"target": "::1", "note": ""})
+		require.NoError(t, err)
+		assert.Equal(t, "Scan ::1, fill in {{ host }} and {{}} in {{template.name}}.", out.Mission.Content)
+	})
+
+	t.Run("NoVariables", func(t *testing.T) {
+		b := &Agent{Description: "Writes {{ .Values }}", Mission: &AgentMission{Content: "Render {{name}} and {{}}"}}
+		out, err := b.WithVariables(nil)
+		require.NoError(t, err)
+		assert.Equal(t, "Writes {{ .Values }}", out.Description)
+		assert.Equal(t, "Render {{name}} and {{}}", out.Mission.Content)
+	})
+}
+
+func TestParseVarAssignments(t *testing.T) {
+	values, err := ParseVarAssignments([]string{"a=1", "b=x=y", "c="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "b": "x=y", "c": ""}, values)
+
+	_, err = ParseVarAssignments([]string{"novalue"})
+	assert.Error(t, err)
+}
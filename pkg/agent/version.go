@@ -0,0 +1,180 @@
+package agent
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version. Missing minor or patch
+// components are treated as zero.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+}
+
+// ParseVersion parses a semantic version like "1.2.3", "v1.2" or "2.0.0-rc1".
+// Build metadata after a '+' is ignored.
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	str := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(str, '+'); i >= 0 {
+		str = str[:i]
+	}
+	if i := strings.IndexByte(str, '-'); i >= 0 {
+		v.Prerelease = str[i+1:]
+		str = str[:i]
+	}
+	if str == "" {
+		return v, fmt.Errorf("invalid version %q", s)
+	}
+	parts := strings.Split(str, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid version %q", s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1 if v is lower, equal or higher than o.
+// A version with a prerelease is lower than the same version without one.
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	switch {
+	case v.Prerelease == o.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case o.Prerelease == "":
+		return -1
+	case v.Prerelease < o.Prerelease:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+type comparator struct {
+	op      string
+	version Version
+	// number of components given, needed for ^ and ~
+	parts int
+}
+
+// Constraint is a set of version comparators which all have to match,
+// e.g. ">=1.2.0, <2". Supported operators are =, !=, >, >=, <, <=,
+// ^ (same major version) and ~ (same minor version). A version
+// without an operator must match exactly.
+type Constraint struct {
+	comparators []comparator
+}
+
+// ParseConstraint parses a comma or space separated version constraint.
+// An empty string or "*" yields a constraint matching every version.
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{}
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if f == "*" {
+			continue
+		}
+		op := ""
+		for _, candidate := range []string{">=", "<=", "!=", "==", ">", "<", "=", "^", "~"} {
+			if strings.HasPrefix(f, candidate) {
+				op = candidate
+				break
+			}
+		}
+		verStr := strings.TrimPrefix(f, op)
+		// allow a space between operator and version: ">= 1.2"
+		if verStr == "" && i+1 < len(fields) {
+			i++
+			verStr = fields[i]
+		}
+		v, err := ParseVersion(verStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version constraint %q: %w", s, err)
+		}
+		if op == "" || op == "==" {
+			op = "="
+		}
+		c.comparators = append(c.comparators, comparator{
+			op:      op,
+			version: v,
+			parts:   strings.Count(strings.SplitN(strings.TrimPrefix(verStr, "v"), "-", 2)[0], ".") + 1,
+		})
+	}
+	return c, nil
+}
+
+// Check reports whether the version satisfies all comparators of the constraint.
+func (c *Constraint) Check(v Version) bool {
+	for _, cmp := range c.comparators {
+		r := v.Compare(cmp.version)
+		var ok bool
+		switch cmp.op {
+		case "=":
+			ok = r == 0
+		case "!=":
+			ok = r != 0
+		case ">":
+			ok = r > 0
+		case ">=":
+			ok = r >= 0
+		case "<":
+			ok = r < 0
+		case "<=":
+			ok = r <= 0
+		case "^":
+			ok = r >= 0 && v.Major == cmp.version.Major
+			if cmp.version.Major == 0 && cmp.parts > 1 {
+				ok = ok && v.Minor == cmp.version.Minor
+			}
+		case "~":
+			ok = r >= 0 && v.Major == cmp.version.Major
+			if cmp.parts > 1 {
+				ok = ok && v.Minor == cmp.version.Minor
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckString parses version and checks it against the constraint.
+func (c *Constraint) CheckString(version string) (bool, error) {
+	v, err := ParseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	return c.Check(v), nil
+}
@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstraintCheck(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"", "0.0.1", true},
+		{"*", "3.1.4", true},
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.4", false},
+		{">=1.2.0, <2", "1.9.0", true},
+		{">=1.2.0, <2", "2.0.0", false},
+		{">= 1.2", "v1.2.0", true},
+		{">1.2", "1.2.0", false},
+		{"!=1.0.0", "1.0.0", false},
+		{"^1.2", "1.9.9", true},
+		{"^1.2", "2.0.0", false},
+		{"^1.2", "1.1.0", false},
+		{"^0.3", "0.4.0", false},
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{">=1.0.0", "1.0.0-rc1", false},
+	}
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if !assert.NoError(t, err, tt.constraint) {
+			continue
+		}
+		got, err := c.CheckString(tt.version)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got, "%q against %q", tt.version, tt.constraint)
+	}
+}
+
+func TestParseConstraintInvalid(t *testing.T) {
+	for _, s := range []string{">=", "1.x", "a.b.c", "1.2.3.4"} {
+		_, err := ParseConstraint(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -16,6 +16,12 @@ func WriteAgent(w io.Writer, agent *Agent) error {
 	if agent.Description != "" {
 		fmt.Fprintf(w, "Description: %s\n", agent.Description)
 	}
+	if agent.Model != "" {
+		fmt.Fprintf(w, "Model: %s\n", agent.Model)
+	}
+	if len(agent.Fallback) > 0 {
+		fmt.Fprintf(w, "Fallback: %s\n", strings.Join(agent.Fallback, ", "))
+	}
 	if agent.Meta != nil {
 		if agent.Meta.Author != "" {
 			fmt.Fprintf(w, "Author: %s\n", agent.Meta.Author)
@@ -40,5 +46,49 @@ func WriteAgent(w io.Writer, agent *Agent) error {
 		fmt.Fprintln(w)
 	}
 
+	// Write Variables section
+	if agent.Variables != nil && len(agent.Variables.List) > 0 {
+		fmt.Fprintln(w, "%Variables")
+		for _, name := range agent.Variables.Names() {
+			fmt.Fprintln(w, formatVariable(name, agent.Variables.List[name]))
+		}
+		fmt.Fprintln(w)
+	}
+
+	// Write Parameters section
+	if len(agent.Parameters) > 0 {
+		fmt.Fprintln(w, "%Parameters")
+		for _, key := range agent.parameterKeys() {
+			fmt.Fprintf(w, "%s: %s\n", key, agent.Parameters[key])
+		}
+		fmt.Fprintln(w)
+	}
+
+	// Write Tools section
+	if agent.Tools != nil && (len(agent.Tools.Required) > 0 || len(agent.Tools.Recommended) > 0) {
+		io.WriteString(w, "%Tools\n")
+		writeTools(w, "Required", agent.Tools.Required)
+		writeTools(w, "Recommended", agent.Tools.Recommended)
+		fmt.Fprintln(w)
+	}
+
 	return nil
 }
+
+func writeTools(w io.Writer, kind string, tools []*MCPTools) {
+	for _, t := range tools {
+		if t == nil {
+			continue
+		}
+		fmt.Fprintf(w, "%s: %s\n", kind, t.Name)
+		if t.Version != "" {
+			fmt.Fprintf(w, "  Version: %s\n", t.Version)
+		}
+		if t.ReadOnly {
+			fmt.Fprintln(w, "  ReadOnly: true")
+		}
+		for _, k := range t.Keys {
+			fmt.Fprintf(w, "  Key: %s\n", k)
+		}
+	}
+}
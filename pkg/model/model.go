@@ -1,17 +1,130 @@
 package model
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Capabilities of models as reported by the providers.
+const (
+	CapabilityCompletion = "completion"
+	CapabilityTools      = "tools"
+	CapabilityVision     = "vision"
+	CapabilityEmbedding  = "embedding"
+	CapabilityThinking   = "thinking"
+)
+
 type Model struct {
 	Name        string                 `yaml:"name"`
 	Description string                 `yaml:"description,omitempty"`
 	Type        string                 `yaml:"type,omitempty"`
 	Provider    string                 `yaml:"provider"`
 	Config      map[string]interface{} `yaml:"config,omitempty"`
+	// Fallback are the references of the models tried in order when this
+	// one fails.
+	Fallback []string `yaml:"fallback,omitempty"`
+	// Alias is the reference of the model this entry stands for, with all
+	// its settings.
+	Alias string `yaml:"alias,omitempty"`
+	// Group spreads the turns over several models.
+	Group *Group `yaml:"group,omitempty"`
+	Info  `yaml:",inline"`
+}
+
+// Strategies of groups.
+const (
+	RoundRobin = "round-robin"
+	Weighted   = "weighted"
+)
+
+// Group is a model entry spreading the turns over its members, in turn or
+// at random by weight.
+type Group struct {
+	// Strategy is RoundRobin, the default, or Weighted.
+	Strategy string        `yaml:"strategy,omitempty"`
+	Members  []GroupMember `yaml:"members"`
+}
+
+// GroupMember is a model of a group.
+type GroupMember struct {
+	// Model is the reference of the model.
+	Model string `yaml:"model"`
+	// Weight is the share of the turns of a weighted group, 1 if unset.
+	Weight int `yaml:"weight,omitempty"`
+}
+
+// String describes the group, like "weighted local/qwen3:3, gpu-box/qwen3:1".
+func (g *Group) String() string {
+	strategy := g.Strategy
+	if strategy == "" {
+		strategy = RoundRobin
+	}
+	members := make([]string, 0, len(g.Members))
+	for _, m := range g.Members {
+		if strategy == Weighted {
+			members = append(members, fmt.Sprintf("%s:%d", m.Model, m.weight()))
+		} else {
+			members = append(members, m.Model)
+		}
+	}
+	return strategy + " " + strings.Join(members, ", ")
+}
+
+func (m GroupMember) weight() int {
+	if m.Weight <= 0 {
+		return 1
+	}
+	return m.Weight
+}
+
+// ID returns the provider-qualified name of the model, like
+// "local/granite4:3b".
+func (m Model) ID() string {
+	if m.Provider == "" {
+		return m.Name
+	}
+	return m.Provider + "/" + m.Name
+}
+
+// Info is the metadata a provider reports about a model. Values the
+// provider doesn't know are left empty.
+type Info struct {
+	Family        string `yaml:"family,omitempty"`
+	ParameterSize string `yaml:"parameter_size,omitempty"`
+	Quantization  string `yaml:"quantization,omitempty"`
+	// ContextLength is the size of the context window in tokens.
+	ContextLength    int      `yaml:"context_length,omitempty"`
+	InputTokenLimit  int      `yaml:"input_token_limit,omitempty"`
+	OutputTokenLimit int      `yaml:"output_token_limit,omitempty"`
+	Capabilities     []string `yaml:"capabilities,omitempty"`
+}
+
+// Can reports whether the model has the capability.
+func (i Info) Can(capability string) bool {
+	return slices.Contains(i.Capabilities, capability)
+}
+
+// DefaultType returns the type of a model with the info, "embedding" for
+// embedding models and "chat" otherwise.
+func (i Info) DefaultType() string {
+	if i.Can(CapabilityEmbedding) && !i.Can(CapabilityCompletion) {
+		return "embedding"
+	}
+	return "chat"
 }
 
 // Store represents a collection of models.
 type Store struct {
 	Items map[string]Model `yaml:",inline"`
 	// Path is the file path where the models are stored.
-	Path         string `yaml:"-"`
-	DefaultModel string `yaml:"default"`
+	Path string `yaml:"-"`
+	// DefaultModel is the name of the model used when neither the command
+	// line nor the agent names one. It is stored as "default" key in the
+	// models file.
+	DefaultModel string `yaml:"-"`
 }
+
+// defaultKey is the key of the default model in the models file, so it
+// can't be used as name of a model together with a default model.
+const defaultKey = "default"
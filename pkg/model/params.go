@@ -0,0 +1,217 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// Params are the generation parameters of a model. They are read from the
+// config of the model, the %Parameters of an agent and the --param flags of
+// 'agent run', in this order, later ones overriding earlier ones. Unset
+// parameters are left to the provider.
+type Params struct {
+	Temperature     *float64
+	TopP            *float64
+	TopK            *int
+	MaxOutputTokens int
+	Seed            *int
+	// ContextWindow is the size of the context in tokens, for providers
+	// where the client chooses it, like Ollama.
+	ContextWindow int
+	Stop          []string
+	// ThinkingBudget is the number of tokens the model may think, 0
+	// disables thinking.
+	ThinkingBudget *int
+}
+
+// paramParsers parse the values of the parameter keys into the params.
+var paramParsers = map[string]func(p *Params, v interface{}) error{
+	"temperature": func(p *Params, v interface{}) error {
+		f, err := toFloat(v)
+		p.Temperature = &f
+		return err
+	},
+	"top_p": func(p *Params, v interface{}) error {
+		f, err := toFloat(v)
+		p.TopP = &f
+		return err
+	},
+	"top_k": func(p *Params, v interface{}) error {
+		i, err := toInt(v)
+		p.TopK = &i
+		return err
+	},
+	"max_output_tokens": func(p *Params, v interface{}) error {
+		var err error
+		p.MaxOutputTokens, err = toInt(v)
+		return err
+	},
+	"seed": func(p *Params, v interface{}) error {
+		i, err := toInt(v)
+		p.Seed = &i
+		return err
+	},
+	"context_window": func(p *Params, v interface{}) error {
+		var err error
+		p.ContextWindow, err = toInt(v)
+		return err
+	},
+	"stop": func(p *Params, v interface{}) error {
+		switch s := v.(type) {
+		case string:
+			p.Stop = []string{s}
+		case []string:
+			p.Stop = s
+		case []interface{}:
+			p.Stop = nil
+			for _, e := range s {
+				p.Stop = append(p.Stop, fmt.Sprint(e))
+			}
+		default:
+			return fmt.Errorf("expected a string or a list of strings")
+		}
+		return nil
+	},
+	"thinking_budget": func(p *Params, v interface{}) error {
+		i, err := toInt(v)
+		p.ThinkingBudget = &i
+		return err
+	},
+}
+
+// ParamKeys returns the sorted keys of the generation parameters.
+func ParamKeys() []string {
+	keys := make([]string, 0, len(paramParsers))
+	for k := range paramParsers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ParseParams reads the generation parameters from values, as found in the
+// config of a model. String values are converted, so parameters given on
+// the command line can be parsed as well.
+func ParseParams(values map[string]interface{}) (Params, error) {
+	var p Params
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parse, ok := paramParsers[k]
+		if !ok {
+			return Params{}, fmt.Errorf("unknown parameter '%s', use one of %s", k, strings.Join(ParamKeys(), ", "))
+		}
+		if err := parse(&p, values[k]); err != nil {
+			return Params{}, fmt.Errorf("invalid value '%v' of parameter '%s': %w", values[k], k, err)
+		}
+	}
+	return p, nil
+}
+
+// Params returns the generation parameters in the config of the model.
+func (m Model) Params() (Params, error) {
+	p, err := ParseParams(m.Config)
+	if err != nil {
+		return Params{}, fmt.Errorf("model '%s': %w", m.ID(), err)
+	}
+	return p, nil
+}
+
+// Merge returns p with the parameters set in over replacing its own.
+func (p Params) Merge(over Params) Params {
+	if over.Temperature != nil {
+		p.Temperature = over.Temperature
+	}
+	if over.TopP != nil {
+		p.TopP = over.TopP
+	}
+	if over.TopK != nil {
+		p.TopK = over.TopK
+	}
+	if over.MaxOutputTokens != 0 {
+		p.MaxOutputTokens = over.MaxOutputTokens
+	}
+	if over.Seed != nil {
+		p.Seed = over.Seed
+	}
+	if over.ContextWindow != 0 {
+		p.ContextWindow = over.ContextWindow
+	}
+	if over.Stop != nil {
+		p.Stop = over.Stop
+	}
+	if over.ThinkingBudget != nil {
+		p.ThinkingBudget = over.ThinkingBudget
+	}
+	return p
+}
+
+// GenerateContentConfig returns the parameters as config of the requests,
+// nil if none of them is set. The context window isn't part of it.
+func (p Params) GenerateContentConfig() *genai.GenerateContentConfig {
+	if p.Temperature == nil && p.TopP == nil && p.TopK == nil && p.MaxOutputTokens == 0 &&
+		p.Seed == nil && len(p.Stop) == 0 && p.ThinkingBudget == nil {
+		return nil
+	}
+	cfg := &genai.GenerateContentConfig{
+		MaxOutputTokens: int32(p.MaxOutputTokens),
+		StopSequences:   p.Stop,
+	}
+	if p.Temperature != nil {
+		cfg.Temperature = genai.Ptr(float32(*p.Temperature))
+	}
+	if p.TopP != nil {
+		cfg.TopP = genai.Ptr(float32(*p.TopP))
+	}
+	if p.TopK != nil {
+		cfg.TopK = genai.Ptr(float32(*p.TopK))
+	}
+	if p.Seed != nil {
+		cfg.Seed = genai.Ptr(int32(*p.Seed))
+	}
+	if p.ThinkingBudget != nil {
+		cfg.ThinkingConfig = &genai.ThinkingConfig{
+			ThinkingBudget:  genai.Ptr(int32(*p.ThinkingBudget)),
+			IncludeThoughts: *p.ThinkingBudget != 0,
+		}
+	}
+	return cfg
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case int:
+		return float64(n), nil
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(n), 64); err == nil {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("expected a number")
+}
+
+func toInt(v interface{}) (int, error) {
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case float64:
+		if n == math.Trunc(n) {
+			return int(n), nil
+		}
+	case string:
+		if i, err := strconv.Atoi(strings.TrimSpace(n)); err == nil {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("expected an integer")
+}
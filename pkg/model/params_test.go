@@ -0,0 +1,63 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+func TestParseParams(t *testing.T) {
+	p, err := ParseParams(map[string]interface{}{
+		"temperature":       0.2,
+		"top_k":             40,
+		"max_output_tokens": "2048",
+		"stop":              []interface{}{"END", "STOP"},
+		"thinking_budget":   0,
+		"context_window":    32768,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0.2, *p.Temperature)
+	assert.Equal(t, 40, *p.TopK)
+	assert.Equal(t, 2048, p.MaxOutputTokens)
+	assert.Equal(t, []string{"END", "STOP"}, p.Stop)
+	assert.Equal(t, 0, *p.ThinkingBudget)
+	assert.Equal(t, 32768, p.ContextWindow)
+	assert.Nil(t, p.TopP)
+
+	_, err = ParseParams(map[string]interface{}{"temprature": 1})
+	assert.ErrorContains(t, err, "unknown parameter 'temprature', use one of context_window, max_output_tokens, seed")
+	_, err = ParseParams(map[string]interface{}{"top_k": 0.5})
+	assert.ErrorContains(t, err, "invalid value '0.5' of parameter 'top_k': expected an integer")
+	_, err = ParseParams(map[string]interface{}{"temperature": "hot"})
+	assert.ErrorContains(t, err, "expected a number")
+
+	_, err = Model{Name: "granite4:3b", Provider: "local", Config: map[string]interface{}{"foo": 1}}.Params()
+	assert.ErrorContains(t, err, "model 'local/granite4:3b': unknown parameter 'foo'")
+}
+
+func TestParamsMerge(t *testing.T) {
+	model, _ := ParseParams(map[string]interface{}{"temperature": 0.7, "seed": 1, "stop": "END"})
+	agent, _ := ParseParams(map[string]interface{}{"temperature": 0.2, "max_output_tokens": 512})
+	flags, _ := ParseParams(map[string]interface{}{"seed": "42"})
+
+	p := model.Merge(agent).Merge(flags)
+	assert.Equal(t, 0.2, *p.Temperature)
+	assert.Equal(t, 42, *p.Seed)
+	assert.Equal(t, 512, p.MaxOutputTokens)
+	assert.Equal(t, []string{"END"}, p.Stop)
+
+	assert.Equal(t, &genai.GenerateContentConfig{
+		Temperature:     genai.Ptr[float32](0.2),
+		Seed:            genai.Ptr[int32](42),
+		MaxOutputTokens: 512,
+		StopSequences:   []string{"END"},
+	}, p.GenerateContentConfig())
+
+	assert.Nil(t, Params{ContextWindow: 4096}.GenerateContentConfig())
+	budget := 1024
+	cfg := Params{ThinkingBudget: &budget}.GenerateContentConfig()
+	assert.Equal(t, int32(1024), *cfg.ThinkingConfig.ThinkingBudget)
+	assert.True(t, cfg.ThinkingConfig.IncludeThoughts)
+}
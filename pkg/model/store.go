@@ -2,8 +2,11 @@ package model
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"sort"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -25,14 +28,31 @@ func Load(path string) (*Store, error) {
 	}
 	defer f.Close()
 
-	if err := yaml.NewDecoder(f).Decode(&store.Items); err != nil {
+	var nodes map[string]yaml.Node
+	if err := yaml.NewDecoder(f).Decode(&nodes); err != nil && err != io.EOF {
 		return nil, fmt.Errorf("failed to decode models from %s: %w", path, err)
 	}
+	for k, node := range nodes {
+		// The default model is stored as plain name next to the models
+		if k == defaultKey && node.Kind == yaml.ScalarNode {
+			store.DefaultModel = node.Value
+			continue
+		}
+		var m Model
+		if err := node.Decode(&m); err != nil {
+			return nil, fmt.Errorf("failed to decode model %s from %s: %w", k, path, err)
+		}
+		store.Items[k] = m
+	}
 
-	// Ensure the name field is set to the key if it's empty
+	// Ensure the name field is set to the key if it's empty, without the
+	// provider of qualified keys
 	for k, v := range store.Items {
 		if v.Name == "" {
 			v.Name = k
+			if v.Provider != "" {
+				v.Name = strings.TrimPrefix(k, v.Provider+"/")
+			}
 			store.Items[k] = v
 		}
 	}
@@ -46,20 +66,191 @@ func (s *Store) Save() error {
 		return fmt.Errorf("no path specified for model store")
 	}
 
+	if _, ok := s.Items[defaultKey]; ok && s.DefaultModel != "" {
+		return fmt.Errorf("the model name '%s' is reserved for the default model", defaultKey)
+	}
+
 	f, err := os.Create(s.Path)
 	if err != nil {
 		return fmt.Errorf("failed to create models file %s: %w", s.Path, err)
 	}
 	defer f.Close()
 
+	doc := make(map[string]interface{}, len(s.Items)+1)
+	for k, m := range s.Items {
+		doc[k] = m
+	}
+	if s.DefaultModel != "" {
+		doc[defaultKey] = s.DefaultModel
+	}
+
 	enc := yaml.NewEncoder(f)
 	enc.SetIndent(2)
-	if err := enc.Encode(s.Items); err != nil {
+	if err := enc.Encode(doc); err != nil {
 		return fmt.Errorf("failed to encode models to %s: %w", s.Path, err)
 	}
 	return nil
 }
 
+// Find returns the key and the model ref refers to. A reference is the
+// provider-qualified ID of a model like "local/granite4:3b", or its key or
+// plain name if only one model has this name.
+func (s *Store) Find(ref string) (string, Model, error) {
+	var keys []string
+	for k, m := range s.Items {
+		if m.ID() == ref {
+			return k, m, nil
+		}
+		if k == ref || m.Name == ref {
+			keys = append(keys, k)
+		}
+	}
+	switch len(keys) {
+	case 0:
+		return "", Model{}, fmt.Errorf("model '%s' not found", ref)
+	case 1:
+		return keys[0], s.Items[keys[0]], nil
+	}
+	ids := make([]string, 0, len(keys))
+	for _, k := range keys {
+		ids = append(ids, s.Items[k].ID())
+	}
+	sort.Strings(ids)
+	return "", Model{}, fmt.Errorf("model name '%s' is ambiguous, use one of %s", ref, strings.Join(ids, ", "))
+}
+
+// Resolve returns the key and the model ref refers to like Find, following
+// aliases to the model they stand for.
+func (s *Store) Resolve(ref string) (string, Model, error) {
+	key, m, err := s.Find(ref)
+	seen := make(map[string]bool)
+	for err == nil && m.Alias != "" {
+		if seen[key] {
+			return "", Model{}, fmt.Errorf("alias '%s' refers to itself", m.ID())
+		}
+		seen[key] = true
+		alias := m.ID()
+		if key, m, err = s.Find(m.Alias); err != nil {
+			err = fmt.Errorf("alias '%s': %w", alias, err)
+		}
+	}
+	if err != nil {
+		return "", Model{}, err
+	}
+	return key, m, nil
+}
+
+// Reference is the use of a model by another model of the store.
+type Reference struct {
+	// Key and ID of the referring model.
+	Key string
+	ID  string
+	// Kind is "alias", "fallback" or "group".
+	Kind string
+}
+
+// String describes the reference, like "alias 'coder'".
+func (r Reference) String() string {
+	if r.Kind == "fallback" {
+		return fmt.Sprintf("fallback of '%s'", r.ID)
+	}
+	return fmt.Sprintf("%s '%s'", r.Kind, r.ID)
+}
+
+// ReferencesTo returns the references of the other models to the model
+// stored under key, sorted by the referring model. Removing the model would
+// leave them dangling.
+func (s *Store) ReferencesTo(key string) []Reference {
+	refersTo := func(ref string) bool {
+		k, _, err := s.Find(ref)
+		return err == nil && k == key
+	}
+	var refs []Reference
+	for k, m := range s.Items {
+		if k == key {
+			continue
+		}
+		if m.Alias != "" && refersTo(m.Alias) {
+			refs = append(refs, Reference{Key: k, ID: m.ID(), Kind: "alias"})
+		}
+		if slices.ContainsFunc(m.Fallback, refersTo) {
+			refs = append(refs, Reference{Key: k, ID: m.ID(), Kind: "fallback"})
+		}
+		if m.Group != nil && slices.ContainsFunc(m.Group.Members, func(g GroupMember) bool { return refersTo(g.Model) }) {
+			refs = append(refs, Reference{Key: k, ID: m.ID(), Kind: "group"})
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].ID != refs[j].ID {
+			return refs[i].ID < refs[j].ID
+		}
+		return refs[i].Kind < refs[j].Kind
+	})
+	return refs
+}
+
+// KeyOf returns the key of the model name of the provider, or false if the
+// provider's model isn't in the store.
+func (s *Store) KeyOf(provider, name string) (string, bool) {
+	for k, m := range s.Items {
+		if m.Provider == provider && m.Name == name {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// Add adds the model m and returns its key. The key is the name of the
+// model, or its provider-qualified ID if another model has this name.
+func (s *Store) Add(m Model) string {
+	key := m.Name
+	for k, other := range s.Items {
+		if k == key || other.Name == m.Name {
+			key = m.ID()
+			break
+		}
+	}
+	if key == defaultKey {
+		key = m.ID()
+	}
+	s.Items[key] = m
+	return key
+}
+
+// Refs returns the sorted references of all models which Find accepts
+// without ambiguity.
+func (s *Store) Refs() []string {
+	seen := make(map[string]bool)
+	var refs []string
+	for k, m := range s.Items {
+		for _, ref := range []string{k, m.Name, m.ID()} {
+			if seen[ref] {
+				continue
+			}
+			seen[ref] = true
+			if _, _, err := s.Find(ref); err == nil {
+				refs = append(refs, ref)
+			}
+		}
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// SetDefault makes the model ref refers to the default model. An empty
+// ref clears the default.
+func (s *Store) SetDefault(ref string) error {
+	if ref != "" {
+		key, _, err := s.Find(ref)
+		if err != nil {
+			return err
+		}
+		ref = key
+	}
+	s.DefaultModel = ref
+	return nil
+}
+
 // List returns a sorted slice of models.
 func (s *Store) List() []Model {
 	keys := make([]string, 0, len(s.Items))
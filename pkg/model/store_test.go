@@ -0,0 +1,152 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "modells.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`default: granite4:3b
+granite4:3b:
+  provider: local
+  context_length: 131072
+  capabilities: [completion, tools]
+`), 0644))
+
+	store, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "granite4:3b", store.DefaultModel)
+	require.Len(t, store.Items, 1)
+	granite := store.Items["granite4:3b"]
+	assert.Equal(t, "granite4:3b", granite.Name)
+	assert.Equal(t, 131072, granite.ContextLength)
+	assert.True(t, granite.Can(CapabilityTools))
+
+	assert.ErrorContains(t, store.SetDefault("missing"), "model 'missing' not found")
+
+	require.NoError(t, store.SetDefault(""))
+	require.NoError(t, store.Save())
+	store, err = Load(path)
+	require.NoError(t, err)
+	assert.Empty(t, store.DefaultModel)
+	assert.Len(t, store.Items, 1)
+
+	store.Items["default"] = Model{Name: "default"}
+	require.NoError(t, store.SetDefault("granite4:3b"))
+	assert.ErrorContains(t, store.Save(), "reserved for the default model")
+}
+
+func TestLoadEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "modells.yaml")
+	require.NoError(t, os.WriteFile(path, nil, 0644))
+	store, err := Load(path)
+	require.NoError(t, err)
+	assert.Empty(t, store.Items)
+}
+
+func TestStoreFind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "modells.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`granite4:3b:
+  provider: local
+gpu-box/granite4:3b:
+  provider: gpu-box
+qwen3:
+  provider: gpu-box
+fast:
+  name: gpt-oss:20b
+  provider: local
+`), 0644))
+	store, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "granite4:3b", store.Items["gpu-box/granite4:3b"].Name)
+
+	key, m, err := store.Find("gpu-box/granite4:3b")
+	require.NoError(t, err)
+	assert.Equal(t, "gpu-box/granite4:3b", key)
+	assert.Equal(t, "gpu-box", m.Provider)
+
+	key, _, err = store.Find("local/granite4:3b")
+	require.NoError(t, err)
+	assert.Equal(t, "granite4:3b", key)
+
+	_, _, err = store.Find("granite4:3b")
+	assert.ErrorContains(t, err, "model name 'granite4:3b' is ambiguous, use one of gpu-box/granite4:3b, local/granite4:3b")
+
+	key, m, err = store.Find("qwen3")
+	require.NoError(t, err)
+	assert.Equal(t, "gpu-box/qwen3", m.ID())
+
+	key, m, err = store.Find("fast")
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-oss:20b", m.Name)
+	_, _, err = store.Find("gpt-oss:20b")
+	assert.NoError(t, err)
+
+	_, _, err = store.Find("local/qwen3")
+	assert.ErrorContains(t, err, "model 'local/qwen3' not found")
+
+	assert.Equal(t, []string{"fast", "gpt-oss:20b", "gpu-box/granite4:3b", "gpu-box/qwen3", "local/gpt-oss:20b", "local/granite4:3b", "qwen3"}, store.Refs())
+
+	key, ok := store.KeyOf("gpu-box", "granite4:3b")
+	assert.True(t, ok)
+	assert.Equal(t, "gpu-box/granite4:3b", key)
+	assert.Equal(t, "gpu-box/gpt-oss:20b", store.Add(Model{Name: "gpt-oss:20b", Provider: "gpu-box"}))
+	assert.Equal(t, "llama3", store.Add(Model{Name: "llama3", Provider: "gpu-box"}))
+}
+
+func TestStoreResolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "modells.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`qwen2.5-coder:14b:
+  provider: local
+coder:
+  alias: local/qwen2.5-coder:14b
+default-small:
+  alias: coder
+spread:
+  group:
+    strategy: weighted
+    members:
+      - model: local/qwen2.5-coder:14b
+        weight: 3
+      - model: coder
+loop:
+  alias: loop
+dangling:
+  alias: missing
+`), 0644))
+	store, err := Load(path)
+	require.NoError(t, err)
+
+	key, m, err := store.Resolve("default-small")
+	require.NoError(t, err)
+	assert.Equal(t, "qwen2.5-coder:14b", key)
+	assert.Equal(t, "local/qwen2.5-coder:14b", m.ID())
+
+	// Find returns the alias itself
+	_, m, err = store.Find("coder")
+	require.NoError(t, err)
+	assert.Equal(t, "local/qwen2.5-coder:14b", m.Alias)
+
+	_, m, err = store.Resolve("spread")
+	require.NoError(t, err)
+	assert.Equal(t, "weighted local/qwen2.5-coder:14b:3, coder:1", m.Group.String())
+	assert.Equal(t, "round-robin a, b", (&Group{Members: []GroupMember{{Model: "a"}, {Model: "b"}}}).String())
+
+	assert.Equal(t, []Reference{
+		{Key: "coder", ID: "coder", Kind: "alias"},
+		{Key: "spread", ID: "spread", Kind: "group"},
+	}, store.ReferencesTo("qwen2.5-coder:14b"))
+	assert.Equal(t, "fallback of 'local/granite4:3b'", Reference{ID: "local/granite4:3b", Kind: "fallback"}.String())
+	assert.Equal(t, "alias 'coder'", store.ReferencesTo("qwen2.5-coder:14b")[0].String())
+	assert.Empty(t, store.ReferencesTo("spread"))
+
+	_, _, err = store.Resolve("loop")
+	assert.EqualError(t, err, "alias 'loop' refers to itself")
+	_, _, err = store.Resolve("dangling")
+	assert.EqualError(t, err, "alias 'dangling': model 'missing' not found")
+}
@@ -0,0 +1,616 @@
+// Package anthropic implements a provider for the Messages API of Anthropic.
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	allmendmodel "github.com/SUSE/allmend/pkg/model"
+	"github.com/SUSE/allmend/pkg/provider/internal/genaiutil"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+const (
+	// DefaultBaseURL is the API of Anthropic.
+	DefaultBaseURL = "https://api.anthropic.com"
+	// DefaultVersion is sent as anthropic-version header.
+	DefaultVersion = "2023-06-01"
+	// DefaultMaxTokens is used if the request doesn't limit the output, as
+	// the Messages API requires a limit.
+	DefaultMaxTokens = 4096
+)
+
+// Config configures the connection to the API.
+type Config struct {
+	// BaseURL of the API without the version, like https://api.anthropic.com
+	BaseURL string
+	APIKey  string
+	// Version of the API, DefaultVersion if empty.
+	Version string
+	// Client is used for the requests, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// Provider implements the model.LLM interface for the Messages API.
+type Provider struct {
+	config Config
+	model  string
+}
+
+// New creates a new Anthropic provider.
+func New(config Config, modelName string) (*Provider, error) {
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultBaseURL
+	}
+	if !strings.HasPrefix(config.BaseURL, "http://") && !strings.HasPrefix(config.BaseURL, "https://") {
+		return nil, fmt.Errorf("invalid anthropic base url: %s", config.BaseURL)
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.Version == "" {
+		config.Version = DefaultVersion
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	return &Provider{config: config, model: modelName}, nil
+}
+
+// Name returns the name of the model.
+func (p *Provider) Name() string {
+	return p.model
+}
+
+// GenerateContent generates content from the model. When streaming, the
+// text is yielded in partial responses followed by a complete response
+// with the whole text, the tool calls and the usage.
+func (p *Provider) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		body, err := p.messagesRequest(req, stream)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		resp, err := p.post(ctx, "/v1/messages", body)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		if !stream {
+			var msg messagesResponse
+			if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+				yield(nil, fmt.Errorf("failed to decode anthropic response: %w", err))
+				return
+			}
+			yield(newResponse(msg.Content, msg.StopReason, msg.Usage), nil)
+			return
+		}
+
+		var (
+			blocks     = make(map[int]*contentBlock)
+			inputs     = make(map[int]*strings.Builder)
+			stopReason string
+			usage      messagesUsage
+		)
+		err = readEvents(resp.Body, func(e *streamEvent) bool {
+			switch e.Type {
+			case "message_start":
+				if e.Message != nil {
+					usage.InputTokens = e.Message.Usage.InputTokens
+					usage.OutputTokens = e.Message.Usage.OutputTokens
+				}
+			case "content_block_start":
+				if e.ContentBlock == nil {
+					return true
+				}
+				block := *e.ContentBlock
+				blocks[e.Index] = &block
+				if block.Type == "tool_use" {
+					// The input is streamed as JSON deltas
+					block.Input = nil
+					inputs[e.Index] = &strings.Builder{}
+				}
+				if block.Text != "" {
+					return yieldText(yield, block.Text, false)
+				}
+			case "content_block_delta":
+				block, ok := blocks[e.Index]
+				if !ok || e.Delta == nil {
+					return true
+				}
+				switch e.Delta.Type {
+				case "text_delta":
+					block.Text += e.Delta.Text
+					return yieldText(yield, e.Delta.Text, false)
+				case "thinking_delta":
+					block.Thinking += e.Delta.Thinking
+					return yieldText(yield, e.Delta.Thinking, true)
+				case "signature_delta":
+					block.Signature += e.Delta.Signature
+				case "input_json_delta":
+					if input, ok := inputs[e.Index]; ok {
+						input.WriteString(e.Delta.PartialJSON)
+					}
+				}
+			case "message_delta":
+				if e.Delta != nil && e.Delta.StopReason != "" {
+					stopReason = e.Delta.StopReason
+				}
+				if e.Usage != nil {
+					usage.OutputTokens = e.Usage.OutputTokens
+				}
+			}
+			return true
+		})
+		if err != nil {
+			if err != errStopped {
+				yield(nil, err)
+			}
+			return
+		}
+
+		indexes := make([]int, 0, len(blocks))
+		for i := range blocks {
+			indexes = append(indexes, i)
+		}
+		sort.Ints(indexes)
+		content := make([]contentBlock, 0, len(indexes))
+		for _, i := range indexes {
+			block := blocks[i]
+			if input, ok := inputs[i]; ok && input.Len() > 0 {
+				block.Input = json.RawMessage(input.String())
+			}
+			content = append(content, *block)
+		}
+		yield(newResponse(content, stopReason, usage), nil)
+	}
+}
+
+func yieldText(yield func(*model.LLMResponse, error) bool, text string, thought bool) bool {
+	if text == "" {
+		return true
+	}
+	part := genai.NewPartFromText(text)
+	part.Thought = thought
+	return yield(&model.LLMResponse{
+		Content: &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{part}},
+		Partial: true,
+	}, nil)
+}
+
+// GetModells returns a list of available models.
+func (p *Provider) GetModells(ctx context.Context) ([]string, error) {
+	models, err := p.DescribeModells(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(models))
+	for _, m := range models {
+		names = append(names, m.Name)
+	}
+	return names, nil
+}
+
+// DescribeModells returns the available models with their display name as
+// description.
+func (p *Provider) DescribeModells(ctx context.Context) ([]allmendmodel.Model, error) {
+	var models []allmendmodel.Model
+	afterID := ""
+	for {
+		query := url.Values{"limit": {"1000"}}
+		if afterID != "" {
+			query.Set("after_id", afterID)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.BaseURL+"/v1/models?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := p.do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list anthropic models: %w", err)
+		}
+		var page struct {
+			Data []struct {
+				ID          string `json:"id"`
+				DisplayName string `json:"display_name"`
+			} `json:"data"`
+			HasMore bool   `json:"has_more"`
+			LastID  string `json:"last_id"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode anthropic models: %w", err)
+		}
+		for _, m := range page.Data {
+			models = append(models, allmendmodel.Model{Name: m.ID, Description: m.DisplayName})
+		}
+		if !page.HasMore || page.LastID == "" {
+			return models, nil
+		}
+		afterID = page.LastID
+	}
+}
+
+func (p *Provider) post(ctx context.Context, path string, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode anthropic request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.BaseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return p.do(req)
+}
+
+// do sends the request with the API key and version and turns error
+// responses into errors.
+func (p *Provider) do(req *http.Request) (*http.Response, error) {
+	if p.config.APIKey != "" {
+		req.Header.Set("X-Api-Key", p.config.APIKey)
+	}
+	req.Header.Set("Anthropic-Version", p.config.Version)
+	resp, err := p.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		var apiErr errorResponse
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+			msg = apiErr.Error.Message
+		}
+		return nil, &genaiutil.StatusError{API: "anthropic", Status: resp.Status, StatusCode: resp.StatusCode, Message: msg}
+	}
+	return resp, nil
+}
+
+// Messages API
+
+type messagesRequest struct {
+	Model         string     `json:"model"`
+	MaxTokens     int32      `json:"max_tokens"`
+	System        string     `json:"system,omitempty"`
+	Messages      []*message `json:"messages"`
+	Tools         []*tool    `json:"tools,omitempty"`
+	Stream        bool       `json:"stream,omitempty"`
+	Temperature   *float32   `json:"temperature,omitempty"`
+	TopP          *float32   `json:"top_p,omitempty"`
+	TopK          *float32   `json:"top_k,omitempty"`
+	StopSequences []string   `json:"stop_sequences,omitempty"`
+	Thinking      *thinking  `json:"thinking,omitempty"`
+}
+
+type thinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int32  `json:"budget_tokens,omitempty"`
+}
+
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+type contentBlock struct {
+	Type string `json:"type"`
+	// text
+	Text string `json:"text,omitempty"`
+	// thinking, the signature has to be sent back with it
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	// redacted_thinking
+	Data string `json:"data,omitempty"`
+	// image
+	Source *imageSource `json:"source,omitempty"`
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+}
+
+type imageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type messagesResponse struct {
+	Content    []contentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      messagesUsage  `json:"usage"`
+}
+
+type messagesUsage struct {
+	InputTokens  int32 `json:"input_tokens"`
+	OutputTokens int32 `json:"output_tokens"`
+}
+
+type streamEvent struct {
+	Type         string            `json:"type"`
+	Index        int               `json:"index"`
+	Message      *messagesResponse `json:"message"`
+	ContentBlock *contentBlock     `json:"content_block"`
+	Delta        *struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		Signature   string `json:"signature"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *messagesUsage `json:"usage"`
+	Error *apiError      `json:"error"`
+}
+
+type errorResponse struct {
+	Error apiError `json:"error"`
+}
+
+type apiError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// messagesRequest translates the ADK request.
+func (p *Provider) messagesRequest(req *model.LLMRequest, stream bool) (*messagesRequest, error) {
+	modelName := p.model
+	if req.Model != "" {
+		modelName = req.Model
+	}
+	out := &messagesRequest{
+		Model:     modelName,
+		MaxTokens: DefaultMaxTokens,
+		System:    genaiutil.SystemText(req.Config),
+		Stream:    stream,
+	}
+
+	for _, content := range req.Contents {
+		msg, err := convertContent(content)
+		if err != nil {
+			return nil, err
+		}
+		if msg == nil {
+			continue
+		}
+		// The roles have to alternate, tool results follow as user messages
+		if n := len(out.Messages); n > 0 && out.Messages[n-1].Role == msg.Role {
+			out.Messages[n-1].Content = append(out.Messages[n-1].Content, msg.Content...)
+			continue
+		}
+		out.Messages = append(out.Messages, msg)
+	}
+
+	for _, decl := range genaiutil.FunctionDeclarations(req.Config) {
+		out.Tools = append(out.Tools, &tool{
+			Name:        decl.Name,
+			Description: decl.Description,
+			InputSchema: genaiutil.ParametersSchema(decl),
+		})
+	}
+
+	if cfg := req.Config; cfg != nil {
+		if cfg.MaxOutputTokens > 0 {
+			out.MaxTokens = cfg.MaxOutputTokens
+		}
+		out.Temperature = cfg.Temperature
+		out.TopP = cfg.TopP
+		out.TopK = cfg.TopK
+		out.StopSequences = cfg.StopSequences
+		if tc := cfg.ThinkingConfig; tc != nil && tc.ThinkingBudget != nil && *tc.ThinkingBudget > 0 {
+			// The API rejects them, better tell which parameter is wrong
+			if out.Temperature != nil || out.TopK != nil {
+				return nil, fmt.Errorf("anthropic: temperature and top_k can't be set while thinking is enabled")
+			}
+			out.Thinking = &thinking{Type: "enabled", BudgetTokens: *tc.ThinkingBudget}
+			// The budget is part of the output tokens
+			if out.MaxTokens <= *tc.ThinkingBudget {
+				out.MaxTokens = *tc.ThinkingBudget + DefaultMaxTokens
+			}
+		}
+	}
+	return out, nil
+}
+
+// convertContent translates a genai content into a message, nil if it has
+// nothing to send.
+func convertContent(content *genai.Content) (*message, error) {
+	role := "user"
+	if content.Role == genai.RoleModel {
+		role = "assistant"
+	}
+
+	msg := &message{Role: role}
+	for _, part := range content.Parts {
+		switch {
+		case part.Thought:
+			// Thinking is sent back with its signature, as the API requires
+			// before tool results. Thoughts of other models have none.
+			if block, ok := thinkingBlock(part); ok {
+				msg.Content = append(msg.Content, block)
+			}
+		case part.FunctionCall != nil:
+			args := part.FunctionCall.Args
+			if args == nil {
+				args = map[string]any{}
+			}
+			input, err := json.Marshal(args)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode arguments of %s: %w", part.FunctionCall.Name, err)
+			}
+			msg.Content = append(msg.Content, contentBlock{
+				Type:  "tool_use",
+				ID:    part.FunctionCall.ID,
+				Name:  part.FunctionCall.Name,
+				Input: input,
+			})
+		case part.FunctionResponse != nil:
+			_, isError := part.FunctionResponse.Response["error"]
+			msg.Content = append(msg.Content, contentBlock{
+				Type:      "tool_result",
+				ToolUseID: part.FunctionResponse.ID,
+				Content:   genaiutil.ResponseText(part.FunctionResponse),
+				IsError:   isError,
+			})
+		case part.InlineData != nil:
+			if !strings.HasPrefix(part.InlineData.MIMEType, "image/") {
+				return nil, fmt.Errorf("unsupported attachment type %s", part.InlineData.MIMEType)
+			}
+			msg.Content = append(msg.Content, contentBlock{Type: "image", Source: &imageSource{
+				Type:      "base64",
+				MediaType: part.InlineData.MIMEType,
+				Data:      base64.StdEncoding.EncodeToString(part.InlineData.Data),
+			}})
+		case part.Text != "":
+			msg.Content = append(msg.Content, contentBlock{Type: "text", Text: part.Text})
+		}
+	}
+	if len(msg.Content) == 0 {
+		return nil, nil
+	}
+	return msg, nil
+}
+
+// The thought signatures of Anthropic's thinking are tagged, so that only
+// they are sent back, and not the signatures of other providers which
+// served earlier turns. Redacted thinking has no text and keeps its data
+// in the signature.
+const (
+	signaturePrefix = "anthropic:"
+	redactedPrefix  = "anthropic:redacted:"
+)
+
+// thoughtPart returns the part of a thinking or redacted_thinking block.
+func thoughtPart(b contentBlock) *genai.Part {
+	if b.Type == "redacted_thinking" {
+		return &genai.Part{Thought: true, ThoughtSignature: []byte(redactedPrefix + b.Data)}
+	}
+	part := &genai.Part{Text: b.Thinking, Thought: true}
+	if b.Signature != "" {
+		part.ThoughtSignature = []byte(signaturePrefix + b.Signature)
+	}
+	return part
+}
+
+// thinkingBlock returns the block of a thought part, false if the part has
+// no signature of Anthropic.
+func thinkingBlock(part *genai.Part) (contentBlock, bool) {
+	signature := string(part.ThoughtSignature)
+	if data, ok := strings.CutPrefix(signature, redactedPrefix); ok {
+		return contentBlock{Type: "redacted_thinking", Data: data}, true
+	}
+	signature, ok := strings.CutPrefix(signature, signaturePrefix)
+	if !ok || signature == "" {
+		return contentBlock{}, false
+	}
+	return contentBlock{Type: "thinking", Thinking: part.Text, Signature: signature}, true
+}
+
+// newResponse creates the complete ADK response of a message.
+func newResponse(blocks []contentBlock, stopReason string, usage messagesUsage) *model.LLMResponse {
+	content := &genai.Content{Role: genai.RoleModel}
+	for _, b := range blocks {
+		switch b.Type {
+		case "text":
+			if b.Text != "" {
+				content.Parts = append(content.Parts, genai.NewPartFromText(b.Text))
+			}
+		case "thinking", "redacted_thinking":
+			if b.Thinking != "" || b.Signature != "" || b.Data != "" {
+				content.Parts = append(content.Parts, thoughtPart(b))
+			}
+		case "tool_use":
+			args := map[string]any{}
+			if len(b.Input) > 0 {
+				if err := json.Unmarshal(b.Input, &args); err != nil {
+					args = map[string]any{"_raw_arguments": string(b.Input)}
+				}
+			}
+			content.Parts = append(content.Parts, &genai.Part{FunctionCall: &genai.FunctionCall{
+				ID:   b.ID,
+				Name: b.Name,
+				Args: args,
+			}})
+		}
+	}
+	resp := &model.LLMResponse{
+		Content:      content,
+		TurnComplete: true,
+		FinishReason: finishReasons[stopReason],
+		UsageMetadata: &genai.GenerateContentResponseUsageMetadata{
+			PromptTokenCount:     usage.InputTokens,
+			CandidatesTokenCount: usage.OutputTokens,
+			TotalTokenCount:      usage.InputTokens + usage.OutputTokens,
+		},
+	}
+	if resp.FinishReason == "" {
+		resp.FinishReason = genai.FinishReasonStop
+	}
+	return resp
+}
+
+var finishReasons = map[string]genai.FinishReason{
+	"end_turn":      genai.FinishReasonStop,
+	"stop_sequence": genai.FinishReasonStop,
+	"tool_use":      genai.FinishReasonStop,
+	"pause_turn":    genai.FinishReasonStop,
+	"max_tokens":    genai.FinishReasonMaxTokens,
+	"refusal":       genai.FinishReasonSafety,
+}
+
+var errStopped = fmt.Errorf("yield stopped")
+
+// readEvents reads the server-sent events of a streamed message and calls
+// handle for every event until the message stops or handle returns false.
+func readEvents(r io.Reader, handle func(*streamEvent) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var event streamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return fmt.Errorf("failed to decode anthropic stream: %w", err)
+		}
+		switch event.Type {
+		case "error":
+			if event.Error != nil {
+				return fmt.Errorf("anthropic api error: %s", event.Error.Message)
+			}
+			return fmt.Errorf("anthropic api error")
+		case "message_stop":
+			return nil
+		}
+		if !handle(&event) {
+			return errStopped
+		}
+	}
+	return scanner.Err()
+}
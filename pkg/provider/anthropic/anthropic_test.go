@@ -0,0 +1,296 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// testServer is a stand-in for the Messages API. It records the last
+// request and answers with handler.
+func testServer(t *testing.T, handler func(w http.ResponseWriter, req map[string]any)) (*httptest.Server, *map[string]any, *http.Header) {
+	t.Helper()
+	var last map[string]any
+	var headers http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		switch r.URL.Path {
+		case "/v1/models":
+			if r.URL.Query().Get("after_id") == "" {
+				fmt.Fprint(w, `{"data": [{"id": "claude-a", "type": "model"}], "has_more": true, "last_id": "claude-a"}`)
+			} else {
+				fmt.Fprint(w, `{"data": [{"id": "claude-b", "type": "model"}], "has_more": false, "last_id": "claude-b"}`)
+			}
+		case "/v1/messages":
+			last = nil
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&last))
+			handler(w, last)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &last, &headers
+}
+
+func collect(t *testing.T, p *Provider, req *model.LLMRequest, stream bool) ([]*model.LLMResponse, error) {
+	t.Helper()
+	var responses []*model.LLMResponse
+	for resp, err := range p.GenerateContent(context.Background(), req, stream) {
+		if err != nil {
+			return responses, err
+		}
+		responses = append(responses, resp)
+	}
+	return responses, nil
+}
+
+func TestGenerateContent(t *testing.T) {
+	srv, last, headers := testServer(t, func(w http.ResponseWriter, req map[string]any) {
+		fmt.Fprint(w, `{
+			"type": "message",
+			"role": "assistant",
+			"content": [
+				{"type": "text", "text": "Let me look that up."},
+				{"type": "tool_use", "id": "toolu_2", "name": "lookup", "input": {"host": "example.org"}}
+			],
+			"stop_reason": "tool_use",
+			"usage": {"input_tokens": 20, "output_tokens": 8}
+		}`)
+	})
+	p, err := New(Config{BaseURL: srv.URL + "/", APIKey: "secret"}, "claude-a")
+	require.NoError(t, err)
+
+	req := &model.LLMRequest{
+		Contents: []*genai.Content{
+			genai.NewContentFromText("Where is example.com?", genai.RoleUser),
+			{Role: genai.RoleModel, Parts: []*genai.Part{
+				{Text: "hmm", Thought: true},
+				{FunctionCall: &genai.FunctionCall{ID: "toolu_1", Name: "lookup", Args: map[string]any{"host": "example.com"}}},
+			}},
+			{Role: genai.RoleUser, Parts: []*genai.Part{
+				{FunctionResponse: &genai.FunctionResponse{ID: "toolu_1", Name: "lookup", Response: map[string]any{"error": "timeout"}}},
+			}},
+			genai.NewContentFromText("And example.org?", genai.RoleUser),
+		},
+		Config: &genai.GenerateContentConfig{
+			SystemInstruction: genai.NewContentFromText("You are a network helper.", genai.RoleUser),
+			Tools: []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{{
+				Name:                 "lookup",
+				Description:          "Resolves a host name",
+				ParametersJsonSchema: map[string]any{"type": "object", "properties": map[string]any{"host": map[string]any{"type": "string"}}},
+			}}}},
+		},
+	}
+	responses, err := collect(t, p, req, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "secret", headers.Get("X-Api-Key"))
+	assert.Equal(t, DefaultVersion, headers.Get("Anthropic-Version"))
+
+	sent := *last
+	assert.Equal(t, "claude-a", sent["model"])
+	assert.Equal(t, float64(DefaultMaxTokens), sent["max_tokens"])
+	assert.Equal(t, "You are a network helper.", sent["system"])
+	assert.Equal(t, []any{
+		map[string]any{"role": "user", "content": []any{
+			map[string]any{"type": "text", "text": "Where is example.com?"},
+		}},
+		map[string]any{"role": "assistant", "content": []any{
+			map[string]any{"type": "tool_use", "id": "toolu_1", "name": "lookup", "input": map[string]any{"host": "example.com"}},
+		}},
+		map[string]any{"role": "user", "content": []any{
+			map[string]any{"type": "tool_result", "tool_use_id": "toolu_1", "content": `{"error":"timeout"}`, "is_error": true},
+			map[string]any{"type": "text", "text": "And example.org?"},
+		}},
+	}, sent["messages"])
+	assert.Equal(t, []any{map[string]any{
+		"name":         "lookup",
+		"description":  "Resolves a host name",
+		"input_schema": map[string]any{"type": "object", "properties": map[string]any{"host": map[string]any{"type": "string"}}},
+	}}, sent["tools"])
+
+	require.Len(t, responses, 1)
+	resp := responses[0]
+	assert.True(t, resp.TurnComplete)
+	assert.Equal(t, genai.FinishReasonStop, resp.FinishReason)
+	require.Len(t, resp.Content.Parts, 2)
+	assert.Equal(t, "Let me look that up.", resp.Content.Parts[0].Text)
+	assert.Equal(t, &genai.FunctionCall{ID: "toolu_2", Name: "lookup", Args: map[string]any{"host": "example.org"}}, resp.Content.Parts[1].FunctionCall)
+	assert.Equal(t, int32(28), resp.UsageMetadata.TotalTokenCount)
+}
+
+func TestThinkingBudget(t *testing.T) {
+	srv, last, _ := testServer(t, func(w http.ResponseWriter, req map[string]any) {
+		fmt.Fprint(w, `{"type": "message", "role": "assistant", "content": [{"type": "text", "text": "42"}], "stop_reason": "end_turn"}`)
+	})
+	p, err := New(Config{BaseURL: srv.URL}, "claude-a")
+	require.NoError(t, err)
+
+	req := &model.LLMRequest{
+		Contents: []*genai.Content{genai.NewContentFromText("What is the answer?", genai.RoleUser)},
+		Config: &genai.GenerateContentConfig{
+			MaxOutputTokens: 1024,
+			ThinkingConfig:  &genai.ThinkingConfig{ThinkingBudget: genai.Ptr[int32](2048)},
+		},
+	}
+	_, err = collect(t, p, req, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{"type": "enabled", "budget_tokens": float64(2048)}, (*last)["thinking"])
+	assert.Equal(t, float64(2048+DefaultMaxTokens), (*last)["max_tokens"])
+}
+
+func TestThinkingWithTools(t *testing.T) {
+	srv, last, _ := testServer(t, func(w http.ResponseWriter, req map[string]any) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, data := range []string{
+			`{"type": "message_start", "message": {"content": [], "usage": {"input_tokens": 10}}}`,
+			`{"type": "content_block_start", "index": 0, "content_block": {"type": "thinking", "thinking": ""}}`,
+			`{"type": "content_block_delta", "index": 0, "delta": {"type": "thinking_delta", "thinking": "Look it up."}}`,
+			`{"type": "content_block_delta", "index": 0, "delta": {"type": "signature_delta", "signature": "c2lnbmF0dXJl"}}`,
+			`{"type": "content_block_start", "index": 1, "content_block": {"type": "redacted_thinking", "data": "ZW5jcnlwdGVk"}}`,
+			// Deltas for the input of blocks which aren't tool calls are ignored
+			`{"type": "content_block_delta", "index": 1, "delta": {"type": "input_json_delta", "partial_json": "{}"}}`,
+			`{"type": "content_block_start", "index": 2, "content_block": {"type": "tool_use", "id": "toolu_1", "name": "lookup", "input": {}}}`,
+			`{"type": "content_block_delta", "index": 2, "delta": {"type": "input_json_delta", "partial_json": "{\"host\": \"example.com\"}"}}`,
+			`{"type": "message_delta", "delta": {"stop_reason": "tool_use"}, "usage": {"output_tokens": 30}}`,
+			`{"type": "message_stop"}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+	})
+	p, err := New(Config{BaseURL: srv.URL}, "claude-a")
+	require.NoError(t, err)
+
+	config := &genai.GenerateContentConfig{
+		ThinkingConfig: &genai.ThinkingConfig{ThinkingBudget: genai.Ptr[int32](1024)},
+		Tools: []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{{
+			Name:                 "lookup",
+			ParametersJsonSchema: map[string]any{"type": "object"},
+		}}}},
+	}
+	contents := []*genai.Content{genai.NewContentFromText("Where is example.com?", genai.RoleUser)}
+	responses, err := collect(t, p, &model.LLMRequest{Contents: contents, Config: config}, true)
+	require.NoError(t, err)
+	final := responses[len(responses)-1]
+	require.Len(t, final.Content.Parts, 3)
+	// The schema of the tool is completed in a copy
+	assert.Equal(t, map[string]any{"type": "object"}, config.Tools[0].FunctionDeclarations[0].ParametersJsonSchema)
+
+	// The thinking goes back with its signature before the tool result,
+	// thoughts of other providers are dropped
+	gemini := &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{
+		{Text: "Think harder.", Thought: true, ThoughtSignature: []byte("gemini signature")},
+		genai.NewPartFromText("Let me look."),
+	}}
+	contents = append(contents, gemini, genai.NewContentFromText("Go on.", genai.RoleUser))
+	contents = append(contents, final.Content, &genai.Content{Role: genai.RoleUser, Parts: []*genai.Part{
+		{FunctionResponse: &genai.FunctionResponse{ID: "toolu_1", Name: "lookup", Response: map[string]any{"output": "93.184.216.34"}}},
+	}})
+	_, err = collect(t, p, &model.LLMRequest{Contents: contents, Config: config}, true)
+	require.NoError(t, err)
+	messages := (*last)["messages"].([]any)
+	require.Len(t, messages, 5)
+	assert.Equal(t, []any{
+		map[string]any{"type": "text", "text": "Let me look."},
+	}, messages[1].(map[string]any)["content"])
+	assert.Equal(t, []any{
+		map[string]any{"type": "thinking", "thinking": "Look it up.", "signature": "c2lnbmF0dXJl"},
+		map[string]any{"type": "redacted_thinking", "data": "ZW5jcnlwdGVk"},
+		map[string]any{"type": "tool_use", "id": "toolu_1", "name": "lookup", "input": map[string]any{"host": "example.com"}},
+	}, messages[3].(map[string]any)["content"])
+
+	// Temperature and top_k are rejected while thinking
+	config.Temperature = genai.Ptr[float32](0.5)
+	_, err = collect(t, p, &model.LLMRequest{Contents: contents, Config: config}, false)
+	assert.EqualError(t, err, "anthropic: temperature and top_k can't be set while thinking is enabled")
+}
+
+func TestGenerateContentStream(t *testing.T) {
+	srv, last, _ := testServer(t, func(w http.ResponseWriter, req map[string]any) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range []struct{ event, data string }{
+			{"message_start", `{"type": "message_start", "message": {"content": [], "usage": {"input_tokens": 10, "output_tokens": 1}}}`},
+			{"content_block_start", `{"type": "content_block_start", "index": 0, "content_block": {"type": "text", "text": ""}}`},
+			{"ping", `{"type": "ping"}`},
+			{"content_block_delta", `{"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": "Hel"}}`},
+			{"content_block_delta", `{"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": "lo"}}`},
+			{"content_block_stop", `{"type": "content_block_stop", "index": 0}`},
+			{"content_block_start", `{"type": "content_block_start", "index": 1, "content_block": {"type": "tool_use", "id": "toolu_1", "name": "greet", "input": {}}}`},
+			{"content_block_delta", `{"type": "content_block_delta", "index": 1, "delta": {"type": "input_json_delta", "partial_json": "{\"na"}}`},
+			{"content_block_delta", `{"type": "content_block_delta", "index": 1, "delta": {"type": "input_json_delta", "partial_json": "me\": \"Bob\"}"}}`},
+			{"content_block_stop", `{"type": "content_block_stop", "index": 1}`},
+			{"message_delta", `{"type": "message_delta", "delta": {"stop_reason": "max_tokens"}, "usage": {"output_tokens": 15}}`},
+			{"message_stop", `{"type": "message_stop"}`},
+		} {
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.event, e.data)
+		}
+	})
+	p, err := New(Config{BaseURL: srv.URL}, "claude-b")
+	require.NoError(t, err)
+
+	maxTokens := int32(15)
+	responses, err := collect(t, p, &model.LLMRequest{
+		Contents: []*genai.Content{genai.NewContentFromText("Hi", genai.RoleUser)},
+		Config:   &genai.GenerateContentConfig{MaxOutputTokens: maxTokens},
+	}, true)
+	require.NoError(t, err)
+
+	assert.Equal(t, true, (*last)["stream"])
+	assert.Equal(t, float64(15), (*last)["max_tokens"])
+	require.Len(t, responses, 3)
+	assert.True(t, responses[0].Partial)
+	assert.Equal(t, "Hel", responses[0].Content.Parts[0].Text)
+	assert.Equal(t, "lo", responses[1].Content.Parts[0].Text)
+
+	final := responses[2]
+	assert.False(t, final.Partial)
+	assert.Equal(t, genai.FinishReasonMaxTokens, final.FinishReason)
+	require.Len(t, final.Content.Parts, 2)
+	assert.Equal(t, "Hello", final.Content.Parts[0].Text)
+	assert.Equal(t, &genai.FunctionCall{ID: "toolu_1", Name: "greet", Args: map[string]any{"name": "Bob"}}, final.Content.Parts[1].FunctionCall)
+	assert.Equal(t, int32(10), final.UsageMetadata.PromptTokenCount)
+	assert.Equal(t, int32(15), final.UsageMetadata.CandidatesTokenCount)
+}
+
+func TestGenerateContentError(t *testing.T) {
+	srv, _, _ := testServer(t, func(w http.ResponseWriter, req map[string]any) {
+		if req["stream"] == true {
+			fmt.Fprint(w, "event: error\ndata: {\"type\": \"error\", \"error\": {\"type\": \"overloaded_error\", \"message\": \"Overloaded\"}}\n\n")
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"type": "error", "error": {"type": "authentication_error", "message": "invalid x-api-key"}}`)
+	})
+	p, err := New(Config{BaseURL: srv.URL}, "claude-a")
+	require.NoError(t, err)
+	req := &model.LLMRequest{Contents: []*genai.Content{genai.NewContentFromText("Hi", genai.RoleUser)}}
+
+	_, err = collect(t, p, req, false)
+	assert.ErrorContains(t, err, "401 Unauthorized: invalid x-api-key")
+
+	_, err = collect(t, p, req, true)
+	assert.ErrorContains(t, err, "anthropic api error: Overloaded")
+
+	_, err = New(Config{BaseURL: "api.anthropic.com"}, "claude-a")
+	assert.ErrorContains(t, err, "invalid anthropic base url")
+}
+
+func TestGetModells(t *testing.T) {
+	srv, _, _ := testServer(t, nil)
+	p, err := New(Config{BaseURL: srv.URL}, "")
+	require.NoError(t, err)
+
+	models, err := p.GetModells(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"claude-a", "claude-b"}, models)
+}
@@ -0,0 +1,146 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/adk/model"
+	adkgemini "google.golang.org/adk/model/gemini"
+	"google.golang.org/genai"
+
+	"github.com/SUSE/allmend/pkg/provider/anthropic"
+	"github.com/SUSE/allmend/pkg/provider/gemini"
+	"github.com/SUSE/allmend/pkg/provider/ollama"
+	"github.com/SUSE/allmend/pkg/provider/openai"
+)
+
+// The provider types built into allmend.
+func init() {
+	Register(Factory{
+		Type:        "ollama",
+		Description: "an Ollama provider",
+		Fields: []Field{
+			{Key: "endpoint", Name: "Endpoint", Usage: "Ollama API endpoint", Default: "http://localhost:11434"},
+		},
+		NewLLM: func(ctx context.Context, cfg Values, modelName string) (model.LLM, error) {
+			return ollama.New(cfg.String("endpoint"), modelName)
+		},
+		NewConnection: func(ctx context.Context, cfg Values) (ProviderConnection, error) {
+			return ollama.New(cfg.String("endpoint"), "")
+		},
+	})
+
+	Register(Factory{
+		Type:        "google",
+		Aliases:     []string{"gemini"},
+		Description: "a Google/Gemini provider",
+		Help: `Add a provider for the Gemini API or Vertex AI. The Gemini API is used
+with an API key. Vertex AI is used with a project and location, or with an
+API key in express mode, but not with both, e.g.
+
+  allmend provider add google vertex --backend vertex --project-id my-project`,
+		Fields: []Field{
+			{Key: "api_key", Name: "API Key", Usage: "Google API Key (optional, defaults to GEMINI_API_KEY env var or .env file)", Env: "GEMINI_API_KEY"},
+			{Key: "project_id", Name: "Project ID", Usage: "Google Cloud Project ID"},
+			{Key: "location", Name: "Location", Usage: "Google Cloud Location", Default: "us-central1"},
+			{Key: "backend", Name: "Backend", Usage: "Backend type (gemini or vertex)", Default: "gemini", Choices: []string{"gemini", "vertex"}},
+		},
+		NewLLM: func(ctx context.Context, cfg Values, modelName string) (model.LLM, error) {
+			return adkgemini.NewModel(ctx, modelName, googleClientConfig(cfg))
+		},
+		NewConnection: func(ctx context.Context, cfg Values) (ProviderConnection, error) {
+			return gemini.New(ctx, googleClientConfig(cfg))
+		},
+		Check: checkGoogle,
+	})
+
+	Register(Factory{
+		Type:        "openai",
+		Description: "an OpenAI compatible provider",
+		Help: `Add a provider speaking the OpenAI chat completions API. Besides OpenAI
+itself this covers vLLM, llama.cpp server, LocalAI and LiteLLM, e.g.
+
+  allmend provider add openai local-vllm --base-url http://localhost:8000/v1`,
+		Fields: []Field{
+			{Key: "base_url", Name: "Base URL", Usage: "Base URL of the OpenAI compatible API", Default: openai.DefaultBaseURL},
+			{Key: "api_key", Name: "API Key", Usage: "API Key (optional, defaults to OPENAI_API_KEY env var or .env file)", Env: "OPENAI_API_KEY"},
+			{Key: "organization", Name: "Organization", Usage: "OpenAI organization ID"},
+			{Key: "headers", Flag: "header", Name: "Header", Usage: "Extra HTTP header sent with every request as KEY=VALUE (can be repeated)", Map: true},
+		},
+		NewLLM: func(ctx context.Context, cfg Values, modelName string) (model.LLM, error) {
+			return openai.New(openAIConfig(cfg), modelName)
+		},
+		NewConnection: func(ctx context.Context, cfg Values) (ProviderConnection, error) {
+			return openai.New(openAIConfig(cfg), "")
+		},
+	})
+
+	Register(Factory{
+		Type:        "anthropic",
+		Aliases:     []string{"claude"},
+		Description: "an Anthropic provider",
+		Fields: []Field{
+			{Key: "base_url", Name: "Base URL", Usage: "Base URL of the Anthropic API", Default: anthropic.DefaultBaseURL},
+			{Key: "api_key", Name: "API Key", Usage: "API Key (optional, defaults to ANTHROPIC_API_KEY env var or .env file)", Env: "ANTHROPIC_API_KEY"},
+			{Key: "version", Name: "Version", Usage: "Version of the Anthropic API", Default: anthropic.DefaultVersion},
+		},
+		NewLLM: func(ctx context.Context, cfg Values, modelName string) (model.LLM, error) {
+			return anthropic.New(anthropicConfig(cfg), modelName)
+		},
+		NewConnection: func(ctx context.Context, cfg Values) (ProviderConnection, error) {
+			return anthropic.New(anthropicConfig(cfg), "")
+		},
+	})
+}
+
+// checkGoogle rejects configurations which are ambiguous for genai, which
+// doesn't take an API key together with a project or location. The Gemini
+// API is used with the API key only, its location is ignored. Vertex AI is
+// used either with a project and location or, in express mode, with an API
+// key.
+func checkGoogle(cfg Values) error {
+	if cfg.String("project_id") == "" {
+		return nil
+	}
+	if cfg.String("backend") != "vertex" {
+		return fmt.Errorf("google provider uses project_id only with the vertex backend, remove it or set backend to vertex")
+	}
+	if cfg.String("api_key") != "" {
+		return fmt.Errorf("google provider with vertex backend takes either api_key or project_id, remove one of them")
+	}
+	return nil
+}
+
+// googleClientConfig creates the genai client configuration of a
+// configuration accepted by checkGoogle.
+func googleClientConfig(cfg Values) *genai.ClientConfig {
+	cc := &genai.ClientConfig{
+		APIKey:  cfg.String("api_key"),
+		Backend: genai.BackendGeminiAPI,
+	}
+	if cfg.String("backend") == "vertex" {
+		cc.Backend = genai.BackendVertexAI
+		if project := cfg.String("project_id"); project != "" {
+			cc.Project = project
+			cc.Location = cfg.String("location")
+		}
+	}
+	return cc
+}
+
+func openAIConfig(cfg Values) openai.Config {
+	return openai.Config{
+		BaseURL:      cfg.String("base_url"),
+		APIKey:       cfg.String("api_key"),
+		Organization: cfg.String("organization"),
+		Headers:      cfg.StringMap("headers"),
+	}
+}
+
+func anthropicConfig(cfg Values) anthropic.Config {
+	return anthropic.Config{
+		BaseURL: cfg.String("base_url"),
+		APIKey:  cfg.String("api_key"),
+		Version: cfg.String("version"),
+	}
+}
@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Problems found by Diagnose.
+const (
+	ProblemAuth        = "auth"
+	ProblemTLS         = "tls"
+	ProblemEndpoint    = "endpoint"
+	ProblemNotFound    = "not-found"
+	ProblemUnavailable = "unavailable"
+	ProblemTimeout     = "timeout"
+	ProblemRequest     = "request"
+)
+
+// Diagnose classifies the error err of a request to a provider. It returns
+// one of the Problem constants and a hint how to fix it, or empty strings if
+// the error is unknown.
+func Diagnose(err error) (problem, hint string) {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var verification *tls.CertificateVerificationError
+	var recordHeader tls.RecordHeaderError
+	// The verification error of crypto/tls wraps the x509 errors, so the
+	// specific ones are checked first
+	switch {
+	case errors.As(err, &hostname):
+		return ProblemTLS, "the certificate of the server doesn't match its host name, check the endpoint URL"
+	case errors.As(err, &unknownAuthority), errors.As(err, &invalid), errors.As(err, &verification):
+		return ProblemTLS, "the certificate of the server isn't trusted, add its CA to the system or check the endpoint URL"
+	case errors.As(err, &recordHeader), err != nil && strings.Contains(err.Error(), "server gave HTTP response to HTTPS client"):
+		return ProblemTLS, "the server doesn't speak TLS, use http:// in the endpoint URL"
+	}
+
+	if code, ok := httpStatus(err); ok {
+		switch {
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			return ProblemAuth, "the provider rejected the credentials, check the API key in providers.conf or its environment variable"
+		case code == http.StatusNotFound:
+			return ProblemNotFound, "the endpoint doesn't know the resource, check the path of the endpoint URL and the model name"
+		case transientStatus(code):
+			return ProblemUnavailable, "the provider is overloaded or failing, try again later"
+		}
+		return ProblemRequest, "the provider refused the request, check the configuration of the provider"
+	}
+
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ProblemTimeout, "the provider didn't answer in time, check the endpoint URL or raise the timeout"
+	case errors.As(err, &dnsErr):
+		return ProblemEndpoint, "the host name of the endpoint can't be resolved, check the endpoint URL"
+	case errors.As(err, &opErr):
+		return ProblemEndpoint, "the endpoint can't be reached, check the endpoint URL and that the server is running"
+	}
+	return "", ""
+}
@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SUSE/allmend/pkg/provider/internal/genaiutil"
+	"github.com/ollama/ollama/api"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genai"
+)
+
+func TestDiagnose(t *testing.T) {
+	problem := func(err error) string {
+		p, _ := Diagnose(err)
+		return p
+	}
+	assert.Equal(t, ProblemAuth, problem(fmt.Errorf("listing: %w", &genaiutil.StatusError{API: "openai", StatusCode: 401})))
+	assert.Equal(t, ProblemAuth, problem(api.AuthorizationError{StatusCode: 401}))
+	assert.Equal(t, ProblemAuth, problem(genai.APIError{Code: 403}))
+	assert.Equal(t, ProblemNotFound, problem(api.StatusError{StatusCode: 404}))
+	assert.Equal(t, ProblemUnavailable, problem(api.StatusError{StatusCode: 503}))
+	assert.Equal(t, ProblemRequest, problem(api.StatusError{StatusCode: 400}))
+	assert.Equal(t, ProblemTimeout, problem(fmt.Errorf("listing: %w", context.DeadlineExceeded)))
+	assert.Equal(t, ProblemEndpoint, problem(&net.DNSError{Err: "no such host", Name: "ollama.invalid", IsNotFound: true}))
+	assert.Empty(t, problem(errors.New("provider requires api_key")))
+
+	_, err := http.Get("http://127.0.0.1:1")
+	assert.Equal(t, ProblemEndpoint, problem(err))
+
+	// A TLS server with a certificate the client doesn't trust
+	tlsSrv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	tlsSrv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	tlsSrv.StartTLS()
+	defer tlsSrv.Close()
+	_, err = http.Get(tlsSrv.URL)
+	p, hint := Diagnose(err)
+	assert.Equal(t, ProblemTLS, p)
+	assert.Contains(t, hint, "isn't trusted")
+
+	// A trusted certificate which doesn't name the host
+	_, err = tlsSrv.Client().Get(strings.Replace(tlsSrv.URL, "127.0.0.1", "localhost", 1))
+	p, hint = Diagnose(err)
+	assert.Equal(t, ProblemTLS, p)
+	assert.Contains(t, hint, "doesn't match its host name")
+
+	// TLS spoken to a plain HTTP server
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	_, err = http.Get(strings.Replace(srv.URL, "http://", "https://", 1))
+	p, hint = Diagnose(err)
+	assert.Equal(t, ProblemTLS, p)
+	assert.Contains(t, hint, "use http://")
+}
@@ -0,0 +1,192 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// ServedByKey is the key of the custom metadata of the responses of a
+// Fallback naming the model which served the turn.
+const ServedByKey = "served_by"
+
+// Candidate is a model of a fallback chain.
+type Candidate struct {
+	// Name identifies the model, like "local/granite4:3b".
+	Name string
+	LLM  model.LLM
+}
+
+// Fallback is a model.LLM trying a chain of models in order. Transient
+// errors, like an unreachable server or an overloaded API, are retried with
+// exponential backoff before the next model is tried, other errors fall
+// over to the next model right away. A model which already streamed a part
+// of its answer isn't retried, its error is returned.
+type Fallback struct {
+	Models []Candidate
+	// Retries is the number of retries of a model after transient errors,
+	// negative values count as 0.
+	Retries int
+	// Backoff is the delay before the first retry, it doubles with every
+	// further retry.
+	Backoff time.Duration
+	// Log receives a line for every failure and the model which served
+	// the turn in the end, if any.
+	Log io.Writer
+}
+
+// Name returns the name of the first model of the chain.
+func (f *Fallback) Name() string {
+	if len(f.Models) == 0 {
+		return ""
+	}
+	return f.Models[0].LLM.Name()
+}
+
+// GenerateContent generates the content with the first model of the chain
+// which succeeds. The responses name the model in their custom metadata.
+func (f *Fallback) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		if len(f.Models) == 0 {
+			yield(nil, fmt.Errorf("no models to generate content with"))
+			return
+		}
+		failed := false
+		var err error
+		for i, c := range f.Models {
+			delay := f.Backoff
+			for attempt := 0; attempt <= max(f.Retries, 0); attempt++ {
+				if attempt > 0 {
+					f.logf("Model '%s' failed: %v, retrying in %s\n", c.Name, err, delay)
+					if sleepErr := sleep(ctx, delay); sleepErr != nil {
+						yield(nil, sleepErr)
+						return
+					}
+					delay *= 2
+				}
+				var yielded, stopped bool
+				yielded, stopped, err = f.try(ctx, c, req, stream, yield)
+				switch {
+				case stopped:
+					return
+				case err == nil:
+					if failed {
+						f.logf("Turn served by model '%s'\n", c.Name)
+					}
+					return
+				case yielded || ctx.Err() != nil:
+					yield(nil, err)
+					return
+				}
+				failed = true
+				if !Transient(err) {
+					break
+				}
+			}
+			if i+1 < len(f.Models) {
+				f.logf("Model '%s' failed: %v, falling back to '%s'\n", c.Name, err, f.Models[i+1].Name)
+			}
+		}
+		yield(nil, err)
+	}
+}
+
+// try generates the content with the candidate c. It reports whether a
+// response was yielded and whether the consumer stopped the iteration.
+func (f *Fallback) try(ctx context.Context, c Candidate, req *model.LLMRequest, stream bool, yield func(*model.LLMResponse, error) bool) (yielded, stopped bool, err error) {
+	for resp, err := range c.LLM.GenerateContent(ctx, forModel(req, c.LLM), stream) {
+		if err != nil {
+			return yielded, false, err
+		}
+		served := *resp
+		served.CustomMetadata = maps.Clone(resp.CustomMetadata)
+		if served.CustomMetadata == nil {
+			served.CustomMetadata = make(map[string]any)
+		}
+		// A group names the member which served the turn itself
+		if _, ok := served.CustomMetadata[ServedByKey]; !ok {
+			served.CustomMetadata[ServedByKey] = c.Name
+		}
+		yielded = true
+		if !yield(&served, nil) {
+			return yielded, true, nil
+		}
+	}
+	return yielded, false, nil
+}
+
+// forModel returns a copy of req asking for the model of llm. ADK names the
+// model of the first candidate in the requests, which the OpenAI and
+// Anthropic adapters would send to every candidate.
+func forModel(req *model.LLMRequest, llm model.LLM) *model.LLMRequest {
+	r := *req
+	r.Model = llm.Name()
+	return &r
+}
+
+func (f *Fallback) logf(format string, args ...any) {
+	if f.Log != nil {
+		fmt.Fprintf(f.Log, format, args...)
+	}
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Transient reports whether err is worth retrying: network errors and the
+// HTTP status codes 408, 429 and 5xx.
+func Transient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if code, ok := httpStatus(err); ok {
+		return transientStatus(code)
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// httpStatus returns the HTTP status code of the error response err of a
+// provider API, if it is one.
+func httpStatus(err error) (int, bool) {
+	var status interface{ HTTPStatus() int }
+	if errors.As(err, &status) {
+		return status.HTTPStatus(), true
+	}
+	var ollamaErr api.StatusError
+	if errors.As(err, &ollamaErr) {
+		return ollamaErr.StatusCode, true
+	}
+	var ollamaAuthErr api.AuthorizationError
+	if errors.As(err, &ollamaAuthErr) {
+		return ollamaAuthErr.StatusCode, true
+	}
+	var genaiErr genai.APIError
+	if errors.As(err, &genaiErr) {
+		return genaiErr.Code, true
+	}
+	return 0, false
+}
+
+func transientStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
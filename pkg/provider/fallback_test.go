@@ -0,0 +1,219 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	allmendmodel "github.com/SUSE/allmend/pkg/model"
+	"github.com/SUSE/allmend/pkg/provider/internal/genaiutil"
+	"github.com/SUSE/allmend/pkg/provider/openai"
+	"github.com/ollama/ollama/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// flakyLLM fails with the errors in order, then answers with its name.
+// With partial it streams a partial response before failing.
+type flakyLLM struct {
+	name    string
+	errs    []error
+	partial bool
+	calls   int
+}
+
+func (f *flakyLLM) Name() string { return f.name }
+
+func (f *flakyLLM) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		f.calls++
+		if f.calls <= len(f.errs) {
+			if f.partial && !yield(&model.LLMResponse{Content: genai.NewContentFromText("Hal", genai.RoleModel), Partial: true}, nil) {
+				return
+			}
+			yield(nil, f.errs[f.calls-1])
+			return
+		}
+		yield(&model.LLMResponse{Content: genai.NewContentFromText(f.name, genai.RoleModel), TurnComplete: true}, nil)
+	}
+}
+
+// openAIServer is a stand-in for an OpenAI compatible server recording the
+// models requested. It answers with the model name, or 404 for models in
+// missing.
+func openAIServer(t *testing.T, missing ...string) (string, *[]string) {
+	t.Helper()
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requested = append(requested, req.Model)
+		if slices.Contains(missing, req.Model) {
+			http.Error(w, `{"error": {"message": "model not found"}}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"choices": [{"message": {"role": "assistant", "content": %q}, "finish_reason": "stop"}]}`, req.Model)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, &requested
+}
+
+func openAILLM(t *testing.T, baseURL, modelName string) model.LLM {
+	t.Helper()
+	llm, err := openai.New(openai.Config{BaseURL: baseURL}, modelName)
+	require.NoError(t, err)
+	return llm
+}
+
+func generate(f *Fallback) ([]*model.LLMResponse, error) {
+	var responses []*model.LLMResponse
+	for resp, err := range f.GenerateContent(context.Background(), &model.LLMRequest{}, true) {
+		if err != nil {
+			return responses, err
+		}
+		responses = append(responses, resp)
+	}
+	return responses, nil
+}
+
+func TestFallback(t *testing.T) {
+	overloaded := api.StatusError{StatusCode: http.StatusServiceUnavailable, ErrorMessage: "overloaded"}
+	unauthorized := api.StatusError{StatusCode: http.StatusUnauthorized, ErrorMessage: "unauthorized"}
+
+	t.Run("RetryTransient", func(t *testing.T) {
+		first := &flakyLLM{name: "first", errs: []error{overloaded, overloaded}}
+		var log bytes.Buffer
+		f := &Fallback{Models: []Candidate{{"local/first", first}}, Retries: 2, Log: &log}
+		responses, err := generate(f)
+		require.NoError(t, err)
+		require.Len(t, responses, 1)
+		assert.Equal(t, "first", responses[0].Content.Parts[0].Text)
+		assert.Equal(t, "local/first", responses[0].CustomMetadata[ServedByKey])
+		assert.Equal(t, 3, first.calls)
+		assert.Contains(t, log.String(), "Model 'local/first' failed: ")
+		assert.Contains(t, log.String(), "Turn served by model 'local/first'\n")
+	})
+
+	t.Run("FallOver", func(t *testing.T) {
+		first := &flakyLLM{name: "first", errs: []error{overloaded, overloaded, overloaded}}
+		second := &flakyLLM{name: "second", errs: []error{unauthorized}}
+		third := &flakyLLM{name: "third"}
+		var log bytes.Buffer
+		f := &Fallback{Models: []Candidate{{"a/first", first}, {"b/second", second}, {"c/third", third}}, Retries: 2, Log: &log}
+		responses, err := generate(f)
+		require.NoError(t, err)
+		assert.Equal(t, "c/third", responses[0].CustomMetadata[ServedByKey])
+		assert.Equal(t, 3, first.calls)
+		// Errors which aren't transient aren't retried
+		assert.Equal(t, 1, second.calls)
+		assert.Contains(t, log.String(), "falling back to 'b/second'")
+		assert.Contains(t, log.String(), "falling back to 'c/third'")
+		assert.Contains(t, log.String(), "Turn served by model 'c/third'")
+
+		// The next turn starts with the first model again
+		log.Reset()
+		responses, err = generate(f)
+		require.NoError(t, err)
+		assert.Equal(t, "a/first", responses[0].CustomMetadata[ServedByKey])
+		assert.Empty(t, log.String())
+	})
+
+	t.Run("AllFail", func(t *testing.T) {
+		f := &Fallback{Models: []Candidate{
+			{"a/first", &flakyLLM{errs: []error{unauthorized}}},
+			{"b/second", &flakyLLM{errs: []error{errors.New("model not found")}}},
+		}}
+		_, err := generate(f)
+		assert.EqualError(t, err, "model not found")
+	})
+
+	t.Run("NegativeRetries", func(t *testing.T) {
+		first := &flakyLLM{name: "first", errs: []error{overloaded}}
+		f := &Fallback{Models: []Candidate{{"local/first", first}}, Retries: -1}
+		_, err := generate(f)
+		assert.ErrorIs(t, err, overloaded)
+		assert.Equal(t, 1, first.calls)
+	})
+
+	t.Run("OwnModelName", func(t *testing.T) {
+		baseURL, requested := openAIServer(t, "gpt-a")
+		f := &Fallback{Models: []Candidate{
+			{"a/gpt-a", openAILLM(t, baseURL, "gpt-a")},
+			{"b/gpt-b", openAILLM(t, baseURL, "gpt-b")},
+		}}
+		// ADK names the model of the fallback in the request
+		var text string
+		for resp, err := range f.GenerateContent(context.Background(), &model.LLMRequest{Model: f.Name()}, false) {
+			require.NoError(t, err)
+			text = resp.Content.Parts[0].Text
+		}
+		assert.Equal(t, []string{"gpt-a", "gpt-b"}, *requested)
+		assert.Equal(t, "gpt-b", text)
+	})
+
+	t.Run("NoRetryAfterPartial", func(t *testing.T) {
+		first := &flakyLLM{name: "first", errs: []error{overloaded}, partial: true}
+		second := &flakyLLM{name: "second"}
+		f := &Fallback{Models: []Candidate{{"a/first", first}, {"b/second", second}}, Retries: 2}
+		responses, err := generate(f)
+		assert.ErrorIs(t, err, overloaded)
+		require.Len(t, responses, 1)
+		assert.True(t, responses[0].Partial)
+		assert.Equal(t, 0, second.calls)
+	})
+}
+
+func TestTransient(t *testing.T) {
+	assert.True(t, Transient(api.StatusError{StatusCode: http.StatusTooManyRequests}))
+	assert.False(t, Transient(api.StatusError{StatusCode: http.StatusNotFound}))
+	assert.True(t, Transient(genai.APIError{Code: http.StatusInternalServerError}))
+	assert.True(t, Transient(fmt.Errorf("request: %w", &genaiutil.StatusError{API: "anthropic", StatusCode: 529})))
+	_, err := http.Get("http://127.0.0.1:1")
+	assert.True(t, Transient(err))
+	assert.False(t, Transient(context.Canceled))
+	assert.False(t, Transient(errors.New("invalid request")))
+}
+
+// configLLM records the config of the last request.
+type configLLM struct {
+	flakyLLM
+	cfg *genai.GenerateContentConfig
+}
+
+func (c *configLLM) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	c.cfg = req.Config
+	return c.flakyLLM.GenerateContent(ctx, req, stream)
+}
+
+func TestWithParams(t *testing.T) {
+	inner := &configLLM{flakyLLM: flakyLLM{name: "gpt-4o"}}
+	temperature := 0.2
+	llm := WithParams(inner, allmendmodel.Params{Temperature: &temperature, MaxOutputTokens: 64})
+	assert.Equal(t, "gpt-4o", llm.Name())
+
+	req := &model.LLMRequest{Config: &genai.GenerateContentConfig{
+		SystemInstruction: genai.NewContentFromText("Be brief.", genai.RoleUser),
+		MaxOutputTokens:   16,
+	}}
+	for _, err := range llm.GenerateContent(context.Background(), req, false) {
+		require.NoError(t, err)
+	}
+	assert.Equal(t, float32(0.2), *inner.cfg.Temperature)
+	assert.Equal(t, int32(64), inner.cfg.MaxOutputTokens)
+	assert.Equal(t, "Be brief.", inner.cfg.SystemInstruction.Parts[0].Text)
+	// The request of the caller is left alone
+	assert.Equal(t, int32(16), req.Config.MaxOutputTokens)
+
+	assert.Same(t, inner, WithParams(inner, allmendmodel.Params{}))
+}
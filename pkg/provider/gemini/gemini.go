@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"strings"
 
-	"google.golang.org/api/iterator"
+	"github.com/SUSE/allmend/pkg/model"
 	"google.golang.org/genai"
 )
 
@@ -27,27 +27,51 @@ func New(ctx context.Context, config *genai.ClientConfig) (*Provider, error) {
 
 // GetModells returns a list of available models.
 func (p *Provider) GetModells(ctx context.Context) ([]string, error) {
-	it, err := p.client.Models.List(ctx, nil)
+	described, err := p.DescribeModells(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list gemini models: %w", err)
+		return nil, err
 	}
-	
-	var models []string
-	for {
-		// New genai client iterators take ctx in Next()
-		m, err := it.Next(ctx)
-		if err == iterator.Done {
-			break
-		}
+	models := make([]string, 0, len(described))
+	for _, m := range described {
+		models = append(models, m.Name)
+	}
+	return models, nil
+}
+
+// DescribeModells returns the available models with their token limits.
+// The supported actions tell whether a model generates or embeds content.
+func (p *Provider) DescribeModells(ctx context.Context) ([]model.Model, error) {
+	var models []model.Model
+	for m, err := range p.client.Models.All(ctx) {
 		if err != nil {
-			return nil, fmt.Errorf("failed to iterate gemini models: %w", err)
+			return nil, fmt.Errorf("failed to list gemini models: %w", err)
+		}
+		info := model.Info{
+			ContextLength:    int(m.InputTokenLimit),
+			InputTokenLimit:  int(m.InputTokenLimit),
+			OutputTokenLimit: int(m.OutputTokenLimit),
+		}
+		for _, action := range m.SupportedActions {
+			switch action {
+			case "generateContent":
+				info.Capabilities = append(info.Capabilities, model.CapabilityCompletion)
+			case "embedContent":
+				info.Capabilities = append(info.Capabilities, model.CapabilityEmbedding)
+			}
+		}
+		if m.Thinking {
+			info.Capabilities = append(info.Capabilities, model.CapabilityThinking)
 		}
-		// Typically model names are like "models/gemini-pro"
-		name := m.Name
-		if strings.HasPrefix(name, "models/") {
-			name = strings.TrimPrefix(name, "models/")
+		description := m.Description
+		if description == "" {
+			description = m.DisplayName
 		}
-		models = append(models, name)
+		// Model names are like "models/gemini-pro"
+		models = append(models, model.Model{
+			Name:        strings.TrimPrefix(m.Name, "models/"),
+			Description: description,
+			Info:        info,
+		})
 	}
 	return models, nil
 }
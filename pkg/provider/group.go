@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"maps"
+	"math/rand/v2"
+	"sync"
+
+	"google.golang.org/adk/model"
+)
+
+// Group is a model.LLM spreading the requests over its members, in turn or,
+// with Weights, at random in proportion to the weights. Within a Fallback a
+// retry of the group goes to the next pick, so failing members are routed
+// around.
+type Group struct {
+	Members []Candidate
+	// Weights are the shares of the requests of the members, nil to take
+	// turns.
+	Weights []int
+
+	mu     sync.Mutex
+	next   int
+	random func(n int) int
+}
+
+// Name returns the name of the first member.
+func (g *Group) Name() string {
+	if len(g.Members) == 0 {
+		return ""
+	}
+	return g.Members[0].LLM.Name()
+}
+
+// GenerateContent generates the content with the member picked for the
+// request. The responses name the member in their custom metadata.
+func (g *Group) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		if len(g.Members) == 0 {
+			yield(nil, fmt.Errorf("no models to generate content with"))
+			return
+		}
+		c := g.pick()
+		for resp, err := range c.LLM.GenerateContent(ctx, forModel(req, c.LLM), stream) {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			served := *resp
+			served.CustomMetadata = maps.Clone(resp.CustomMetadata)
+			if served.CustomMetadata == nil {
+				served.CustomMetadata = make(map[string]any)
+			}
+			served.CustomMetadata[ServedByKey] = c.Name
+			if !yield(&served, nil) {
+				return
+			}
+		}
+	}
+}
+
+// pick returns the member for the next request.
+func (g *Group) pick() Candidate {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.Weights) != len(g.Members) {
+		c := g.Members[g.next%len(g.Members)]
+		g.next++
+		return c
+	}
+	total := 0
+	for _, w := range g.Weights {
+		total += max(w, 1)
+	}
+	random := g.random
+	if random == nil {
+		random = rand.IntN
+	}
+	n := random(total)
+	for i, w := range g.Weights {
+		if n -= max(w, 1); n < 0 {
+			return g.Members[i]
+		}
+	}
+	return g.Members[len(g.Members)-1]
+}
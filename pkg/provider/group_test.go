@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/adk/model"
+)
+
+func servedBy(t *testing.T, llm model.LLM) string {
+	t.Helper()
+	var name string
+	for resp, err := range llm.GenerateContent(context.Background(), &model.LLMRequest{}, false) {
+		require.NoError(t, err)
+		name, _ = resp.CustomMetadata[ServedByKey].(string)
+	}
+	return name
+}
+
+func TestGroup(t *testing.T) {
+	members := []Candidate{{"local/qwen3", &flakyLLM{name: "a"}}, {"gpu-box/qwen3", &flakyLLM{name: "b"}}}
+
+	t.Run("RoundRobin", func(t *testing.T) {
+		g := &Group{Members: members}
+		var served []string
+		for range 3 {
+			served = append(served, servedBy(t, g))
+		}
+		assert.Equal(t, []string{"local/qwen3", "gpu-box/qwen3", "local/qwen3"}, served)
+	})
+
+	t.Run("Weighted", func(t *testing.T) {
+		var totals []int
+		n := 0
+		g := &Group{Members: members, Weights: []int{3, 0}, random: func(total int) int {
+			totals = append(totals, total)
+			n++
+			return n - 1
+		}}
+		var served []string
+		for range 4 {
+			served = append(served, servedBy(t, g))
+		}
+		// An unset weight counts as 1
+		assert.Equal(t, []int{4, 4, 4, 4}, totals)
+		assert.Equal(t, []string{"local/qwen3", "local/qwen3", "local/qwen3", "gpu-box/qwen3"}, served)
+	})
+
+	t.Run("OwnModelName", func(t *testing.T) {
+		baseURL, requested := openAIServer(t)
+		g := &Group{Members: []Candidate{
+			{"a/gpt-a", openAILLM(t, baseURL, "gpt-a")},
+			{"b/gpt-b", openAILLM(t, baseURL, "gpt-b")},
+		}}
+		// ADK names the model of the group in the requests
+		for range 2 {
+			for _, err := range g.GenerateContent(context.Background(), &model.LLMRequest{Model: g.Name()}, false) {
+				require.NoError(t, err)
+			}
+		}
+		assert.Equal(t, []string{"gpt-a", "gpt-b"}, *requested)
+	})
+
+	t.Run("InFallback", func(t *testing.T) {
+		overloaded := api.StatusError{StatusCode: http.StatusServiceUnavailable}
+		g := &Group{Members: []Candidate{
+			{"local/qwen3", &flakyLLM{name: "a", errs: []error{overloaded}}},
+			{"gpu-box/qwen3", &flakyLLM{name: "b"}},
+		}}
+		f := &Fallback{Models: []Candidate{{"spread", g}}, Retries: 1}
+		// The retry goes to the next member, which names itself
+		assert.Equal(t, "gpu-box/qwen3", servedBy(t, f))
+	})
+}
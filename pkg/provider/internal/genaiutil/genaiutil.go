@@ -0,0 +1,145 @@
+// Package genaiutil helps the providers to translate the genai requests of
+// ADK into the formats of other APIs.
+package genaiutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// SystemText returns the text of the system instruction of cfg.
+func SystemText(cfg *genai.GenerateContentConfig) string {
+	if cfg == nil || cfg.SystemInstruction == nil {
+		return ""
+	}
+	var texts []string
+	for _, p := range cfg.SystemInstruction.Parts {
+		if p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// FunctionDeclarations returns all function declarations of the tools in cfg.
+func FunctionDeclarations(cfg *genai.GenerateContentConfig) []*genai.FunctionDeclaration {
+	if cfg == nil {
+		return nil
+	}
+	var decls []*genai.FunctionDeclaration
+	for _, t := range cfg.Tools {
+		if t != nil {
+			decls = append(decls, t.FunctionDeclarations...)
+		}
+	}
+	return decls
+}
+
+// ParametersSchema returns the parameters of decl as JSON schema. The
+// genai schema, which uses upper case type names, is converted as well. A
+// function without parameters gets an empty object schema.
+func ParametersSchema(decl *genai.FunctionDeclaration) map[string]any {
+	var schema map[string]any
+	switch {
+	case decl.ParametersJsonSchema != nil:
+		schema = toMap(decl.ParametersJsonSchema)
+	case decl.Parameters != nil:
+		schema = toMap(decl.Parameters)
+		normalizeSchema(schema)
+	}
+	if schema == nil {
+		schema = map[string]any{}
+	}
+	if _, ok := schema["type"]; !ok {
+		schema["type"] = "object"
+	}
+	if schema["type"] == "object" {
+		if _, ok := schema["properties"]; !ok {
+			schema["properties"] = map[string]any{}
+		}
+	}
+	return schema
+}
+
+// ResponseSchema returns the schema the response has to follow as JSON
+// schema, nil if the response isn't restricted.
+func ResponseSchema(cfg *genai.GenerateContentConfig) map[string]any {
+	switch {
+	case cfg == nil:
+		return nil
+	case cfg.ResponseJsonSchema != nil:
+		return toMap(cfg.ResponseJsonSchema)
+	case cfg.ResponseSchema != nil:
+		schema := toMap(cfg.ResponseSchema)
+		normalizeSchema(schema)
+		return schema
+	}
+	return nil
+}
+
+// toMap returns the JSON object v as map. Maps are copied, so the schema
+// of the caller can be completed without changing it.
+func toMap(v any) map[string]any {
+	if m, ok := v.(map[string]any); ok {
+		return maps.Clone(m)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]any
+	if json.Unmarshal(data, &m) != nil {
+		return nil
+	}
+	return m
+}
+
+// normalizeSchema converts a marshalled genai.Schema into JSON schema.
+func normalizeSchema(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		if t, ok := v["type"].(string); ok {
+			v["type"] = strings.ToLower(t)
+		}
+		delete(v, "propertyOrdering")
+		for _, child := range v {
+			normalizeSchema(child)
+		}
+	case []any:
+		for _, child := range v {
+			normalizeSchema(child)
+		}
+	}
+}
+
+// ResponseText returns the text of the function response as it is passed
+// to APIs expecting text.
+func ResponseText(resp *genai.FunctionResponse) string {
+	data, err := json.Marshal(resp.Response)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// StatusError is the error of an API answering with an unsuccessful HTTP
+// status.
+type StatusError struct {
+	// API names the API, like "openai".
+	API        string
+	Status     string
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s api returned %s: %s", e.API, e.Status, e.Message)
+}
+
+// HTTPStatus returns the status code, so that callers can tell transient
+// errors apart.
+func (e *StatusError) HTTPStatus() int { return e.StatusCode }
@@ -2,11 +2,19 @@ package ollama
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"iter"
 	"net/http"
 	"net/url"
+	"os"
+	"strings"
+	"time"
 
+	allmendmodel "github.com/SUSE/allmend/pkg/model"
+	"github.com/SUSE/allmend/pkg/provider/internal/genaiutil"
+	"github.com/SUSE/allmend/pkg/runner"
 	"github.com/ollama/ollama/api"
 	"google.golang.org/adk/model"
 	"google.golang.org/genai"
@@ -18,6 +26,9 @@ var yieldErr = fmt.Errorf("yield stopped")
 type Provider struct {
 	client *api.Client
 	model  string
+	// numCtx is the context window requested for the model, 0 for the
+	// default of the server.
+	numCtx int
 }
 
 // New creates a new Ollama provider.
@@ -40,63 +51,326 @@ func (p *Provider) Name() string {
 	return p.model
 }
 
-// GenerateContent generates content from the model.
+// GenerateContent generates content from the model. When streaming, the
+// text and the thinking are yielded in partial responses followed by a
+// complete response with the whole text, the tool calls and the usage.
+// Otherwise only the complete response is yielded.
 func (p *Provider) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
 	return func(yield func(*model.LLMResponse, error) bool) {
-		messages := make([]api.Message, 0, len(req.Contents))
-		for _, content := range req.Contents {
-			role := content.Role
-			// Map genai roles to ollama roles
-			if role == "model" {
-				role = "assistant"
+		chatReq, err := p.chatRequest(ctx, req, stream)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		var (
+			text, thinking strings.Builder
+			calls          []api.ToolCall
+			final          *model.LLMResponse
+		)
+		err = p.client.Chat(ctx, chatReq, func(resp api.ChatResponse) error {
+			text.WriteString(resp.Message.Content)
+			thinking.WriteString(resp.Message.Thinking)
+			calls = append(calls, resp.Message.ToolCalls...)
+
+			if resp.Done {
+				final = newResponse(thinking.String(), text.String(), calls, resp)
+				return nil
 			}
-			
-			var textContent string
-			for _, part := range content.Parts {
-				if part.Text != "" {
-					textContent += part.Text
-				}
-				// TODO: Handle other part types like images if needed
+			if !stream || (resp.Message.Content == "" && resp.Message.Thinking == "") {
+				return nil
 			}
-			
-			messages = append(messages, api.Message{
-				Role:    role,
-				Content: textContent,
-			})
+			if !yield(&model.LLMResponse{
+				Content: newContent(resp.Message.Thinking, resp.Message.Content, nil),
+				Partial: true,
+			}, nil) {
+				return yieldErr
+			}
+			return nil
+		})
+
+		switch {
+		case err == yieldErr:
+		case err != nil:
+			yield(nil, err)
+		case final == nil:
+			yield(nil, fmt.Errorf("ollama response ended unexpectedly"))
+		default:
+			yield(final, nil)
 		}
+	}
+}
+
+// newResponse creates the complete ADK response from the aggregated
+// message and the final chunk.
+func newResponse(thinking, text string, calls []api.ToolCall, done api.ChatResponse) *model.LLMResponse {
+	return &model.LLMResponse{
+		Content:      newContent(thinking, text, calls),
+		TurnComplete: true,
+		FinishReason: finishReason(done.DoneReason),
+		UsageMetadata: &genai.GenerateContentResponseUsageMetadata{
+			PromptTokenCount:     int32(done.PromptEvalCount),
+			CandidatesTokenCount: int32(done.EvalCount),
+			TotalTokenCount:      int32(done.PromptEvalCount + done.EvalCount),
+		},
+	}
+}
+
+// newContent creates the model content with the thinking as thought part
+// followed by the text and the function calls.
+func newContent(thinking, text string, calls []api.ToolCall) *genai.Content {
+	content := &genai.Content{Role: "model"}
+	if thinking != "" {
+		content.Parts = append(content.Parts, &genai.Part{Text: thinking, Thought: true})
+	}
+	if text != "" {
+		content.Parts = append(content.Parts, &genai.Part{Text: text})
+	}
+	content.Parts = append(content.Parts, functionCalls(calls)...)
+	return content
+}
 
-		chatReq := &api.ChatRequest{
-			Model:    p.model,
-			Messages: messages,
-			Stream:   &stream,
+// chatRequest translates the ADK request into an Ollama chat request. The
+// system instruction becomes the first message and the generation config
+// is mapped to the options and the format.
+func (p *Provider) chatRequest(ctx context.Context, req *model.LLMRequest, stream bool) (*api.ChatRequest, error) {
+	messages := make([]api.Message, 0, len(req.Contents)+1)
+	if system := genaiutil.SystemText(req.Config); system != "" {
+		messages = append(messages, api.Message{Role: "system", Content: system})
+	}
+	for _, content := range req.Contents {
+		msgs, err := convertContent(ctx, content)
+		if err != nil {
+			return nil, err
 		}
+		messages = append(messages, msgs...)
+	}
+
+	tools, err := convertTools(req.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	chatReq := &api.ChatRequest{
+		Model:    p.model,
+		Messages: messages,
+		Tools:    tools,
+		Stream:   &stream,
+	}
+	if cfg := req.Config; cfg != nil {
+		chatReq.Options = options(cfg)
+		if tc := cfg.ThinkingConfig; tc != nil && tc.ThinkingBudget != nil {
+			chatReq.Think = &api.ThinkValue{Value: *tc.ThinkingBudget != 0}
+		}
+		if schema := genaiutil.ResponseSchema(cfg); schema != nil {
+			format, err := json.Marshal(schema)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode response schema: %w", err)
+			}
+			chatReq.Format = format
+		} else if cfg.ResponseMIMEType == "application/json" {
+			chatReq.Format = json.RawMessage(`"json"`)
+		}
+	}
+	if p.numCtx > 0 {
+		if chatReq.Options == nil {
+			chatReq.Options = map[string]any{}
+		}
+		chatReq.Options["num_ctx"] = p.numCtx
+	}
+	return chatReq, nil
+}
+
+// SetContextWindow sets the size of the context window, num_ctx, used for
+// the requests.
+func (p *Provider) SetContextWindow(tokens int) {
+	p.numCtx = tokens
+}
+
+// options maps the generation config to the Ollama model options.
+func options(cfg *genai.GenerateContentConfig) map[string]any {
+	opts := map[string]any{}
+	if cfg.Temperature != nil {
+		opts["temperature"] = *cfg.Temperature
+	}
+	if cfg.TopP != nil {
+		opts["top_p"] = *cfg.TopP
+	}
+	if cfg.TopK != nil {
+		opts["top_k"] = int(*cfg.TopK)
+	}
+	if cfg.MaxOutputTokens > 0 {
+		opts["num_predict"] = cfg.MaxOutputTokens
+	}
+	if len(cfg.StopSequences) > 0 {
+		opts["stop"] = cfg.StopSequences
+	}
+	if cfg.Seed != nil {
+		opts["seed"] = *cfg.Seed
+	}
+	if cfg.PresencePenalty != nil {
+		opts["presence_penalty"] = *cfg.PresencePenalty
+	}
+	if cfg.FrequencyPenalty != nil {
+		opts["frequency_penalty"] = *cfg.FrequencyPenalty
+	}
+	if len(opts) == 0 {
+		return nil
+	}
+	return opts
+}
+
+// finishReason maps the done reason of Ollama.
+func finishReason(reason string) genai.FinishReason {
+	if reason == "length" {
+		return genai.FinishReasonMaxTokens
+	}
+	return genai.FinishReasonStop
+}
+
+// convertContent translates a genai content into Ollama messages. Function
+// responses become tool messages of their own, images are attached to the
+// message.
+func convertContent(ctx context.Context, content *genai.Content) ([]api.Message, error) {
+	role := content.Role
+	// Map genai roles to ollama roles
+	if role == "model" {
+		role = "assistant"
+	}
 
-		err := p.client.Chat(ctx, chatReq, func(resp api.ChatResponse) error {
-			llmResp := &model.LLMResponse{
-				Content: &genai.Content{
-					Role: "model",
-					Parts: []*genai.Part{
-						{Text: resp.Message.Content},
-					},
+	msg := api.Message{Role: role}
+	var results []api.Message
+	for _, part := range content.Parts {
+		switch {
+		case part.Thought:
+			msg.Thinking += part.Text
+		case part.FunctionCall != nil:
+			args := api.NewToolCallFunctionArguments()
+			for k, v := range part.FunctionCall.Args {
+				args.Set(k, v)
+			}
+			msg.ToolCalls = append(msg.ToolCalls, api.ToolCall{
+				ID: part.FunctionCall.ID,
+				Function: api.ToolCallFunction{
+					Index:     len(msg.ToolCalls),
+					Name:      part.FunctionCall.Name,
+					Arguments: args,
 				},
-				// Map other fields as best as possible
-				TurnComplete: resp.Done,
+			})
+		case part.FunctionResponse != nil:
+			results = append(results, api.Message{
+				Role:       "tool",
+				Content:    genaiutil.ResponseText(part.FunctionResponse),
+				ToolName:   part.FunctionResponse.Name,
+				ToolCallID: part.FunctionResponse.ID,
+			})
+		case part.InlineData != nil:
+			if !strings.HasPrefix(part.InlineData.MIMEType, "image/") {
+				return nil, fmt.Errorf("unsupported attachment type %s", part.InlineData.MIMEType)
 			}
-			
-			if resp.Done {
-				llmResp.FinishReason = genai.FinishReasonStop
+			msg.Images = append(msg.Images, api.ImageData(part.InlineData.Data))
+		case part.FileData != nil:
+			image, err := readFile(ctx, part.FileData)
+			if err != nil {
+				return nil, err
 			}
+			msg.Images = append(msg.Images, image)
+		case part.Text != "":
+			msg.Content += part.Text
+		}
+	}
 
-			if !yield(llmResp, nil) {
-				return yieldErr
-			}
-			return nil
+	if msg.Content == "" && len(msg.ToolCalls) == 0 && len(msg.Images) == 0 && len(results) > 0 {
+		return results, nil
+	}
+	return append([]api.Message{msg}, results...), nil
+}
+
+// readFile reads the image a file part refers to, a local file or a file
+// on a web server.
+func readFile(ctx context.Context, file *genai.FileData) (api.ImageData, error) {
+	if file.MIMEType != "" && !strings.HasPrefix(file.MIMEType, "image/") {
+		return nil, fmt.Errorf("unsupported attachment type %s", file.MIMEType)
+	}
+	u, err := url.Parse(file.FileURI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file uri %s: %w", file.FileURI, err)
+	}
+	switch u.Scheme {
+	case "", "file":
+		data, err := os.ReadFile(u.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image: %w", err)
+		}
+		return data, nil
+	case "http", "https":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, file.FileURI, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := downloadClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download image: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to download image %s: %s", file.FileURI, resp.Status)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, runner.MaxAttachmentSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to download image %s: %w", file.FileURI, err)
+		}
+		if len(data) > runner.MaxAttachmentSize {
+			return nil, fmt.Errorf("image %s is larger than %d MB", file.FileURI, runner.MaxAttachmentSize/1024/1024)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("unsupported file uri %s", file.FileURI)
+}
+
+// downloadClient downloads the images of file parts on web servers.
+var downloadClient = &http.Client{Timeout: time.Minute}
+
+// convertTools translates the function declarations of the request into
+// Ollama tools.
+func convertTools(cfg *genai.GenerateContentConfig) (api.Tools, error) {
+	var tools api.Tools
+	for _, decl := range genaiutil.FunctionDeclarations(cfg) {
+		schema, err := json.Marshal(genaiutil.ParametersSchema(decl))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode parameters of %s: %w", decl.Name, err)
+		}
+		var params api.ToolFunctionParameters
+		if err := json.Unmarshal(schema, &params); err != nil {
+			return nil, fmt.Errorf("failed to convert parameters of %s: %w", decl.Name, err)
+		}
+		tools = append(tools, api.Tool{
+			Type: "function",
+			Function: api.ToolFunction{
+				Name:        decl.Name,
+				Description: decl.Description,
+				Parameters:  params,
+			},
 		})
+	}
+	return tools, nil
+}
 
-		if err != nil && err != yieldErr {
-			yield(nil, err)
+// functionCalls translates the tool calls of a response into genai parts.
+func functionCalls(calls []api.ToolCall) []*genai.Part {
+	parts := make([]*genai.Part, 0, len(calls))
+	for _, call := range calls {
+		args := call.Function.Arguments.ToMap()
+		if args == nil {
+			args = map[string]any{}
 		}
+		parts = append(parts, &genai.Part{FunctionCall: &genai.FunctionCall{
+			ID:   call.ID,
+			Name: call.Function.Name,
+			Args: args,
+		}})
 	}
+	return parts
 }
 
 // GetModells returns a list of available models.
@@ -112,3 +386,35 @@ func (p *Provider) GetModells(ctx context.Context) ([]string, error) {
 	}
 	return models, nil
 }
+
+// DescribeModells returns the available models with their details and the
+// capabilities and context length reported by /api/show.
+func (p *Provider) DescribeModells(ctx context.Context) ([]allmendmodel.Model, error) {
+	resp, err := p.client.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ollama models: %w", err)
+	}
+
+	models := make([]allmendmodel.Model, 0, len(resp.Models))
+	for _, m := range resp.Models {
+		info := allmendmodel.Info{
+			Family:        m.Details.Family,
+			ParameterSize: m.Details.ParameterSize,
+			Quantization:  m.Details.QuantizationLevel,
+		}
+		show, err := p.client.Show(ctx, &api.ShowRequest{Model: m.Name})
+		if err != nil {
+			return nil, fmt.Errorf("failed to show ollama model %s: %w", m.Name, err)
+		}
+		for _, c := range show.Capabilities {
+			info.Capabilities = append(info.Capabilities, string(c))
+		}
+		if arch, ok := show.ModelInfo["general.architecture"].(string); ok {
+			if length, ok := show.ModelInfo[arch+".context_length"].(float64); ok {
+				info.ContextLength = int(length)
+			}
+		}
+		models = append(models, allmendmodel.Model{Name: m.Name, Info: info})
+	}
+	return models, nil
+}
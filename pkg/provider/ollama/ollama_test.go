@@ -0,0 +1,310 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	allmendmodel "github.com/SUSE/allmend/pkg/model"
+	"github.com/SUSE/allmend/pkg/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// testServer is a stand-in for an Ollama server answering chat requests
+// with the given lines. It records the last chat request.
+func testServer(t *testing.T, lines ...string) (*Provider, *map[string]any) {
+	t.Helper()
+	var last map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		last = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&last))
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	p, err := New(srv.URL, "qwen3")
+	require.NoError(t, err)
+	return p, &last
+}
+
+func collect(t *testing.T, p *Provider, req *model.LLMRequest, stream bool) []*model.LLMResponse {
+	t.Helper()
+	var responses []*model.LLMResponse
+	for resp, err := range p.GenerateContent(context.Background(), req, stream) {
+		require.NoError(t, err)
+		responses = append(responses, resp)
+	}
+	return responses
+}
+
+func TestToolCalls(t *testing.T) {
+	p, last := testServer(t,
+		`{"model": "qwen3", "message": {"role": "assistant", "content": "", "tool_calls": [{"id": "call_2", "function": {"index": 0, "name": "lookup", "arguments": {"host": "example.org"}}}]}, "done": true, "done_reason": "stop"}`,
+	)
+
+	req := &model.LLMRequest{
+		Contents: []*genai.Content{
+			genai.NewContentFromText("Where is example.com?", genai.RoleUser),
+			{Role: genai.RoleModel, Parts: []*genai.Part{
+				{FunctionCall: &genai.FunctionCall{ID: "call_1", Name: "lookup", Args: map[string]any{"host": "example.com"}}},
+			}},
+			{Role: genai.RoleUser, Parts: []*genai.Part{
+				{FunctionResponse: &genai.FunctionResponse{ID: "call_1", Name: "lookup", Response: map[string]any{"output": "93.184.216.34"}}},
+			}},
+		},
+		Config: &genai.GenerateContentConfig{
+			Tools: []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{{
+				Name:        "lookup",
+				Description: "Resolves a host name",
+				Parameters: &genai.Schema{
+					Type:       genai.TypeObject,
+					Properties: map[string]*genai.Schema{"host": {Type: genai.TypeString, Description: "Host name"}},
+					Required:   []string{"host"},
+				},
+			}}}},
+		},
+	}
+	responses := collect(t, p, req, false)
+
+	sent := *last
+	assert.Equal(t, []any{
+		map[string]any{"role": "user", "content": "Where is example.com?"},
+		map[string]any{"role": "assistant", "content": "", "tool_calls": []any{map[string]any{
+			"id": "call_1", "function": map[string]any{"index": float64(0), "name": "lookup", "arguments": map[string]any{"host": "example.com"}},
+		}}},
+		map[string]any{"role": "tool", "content": `{"output":"93.184.216.34"}`, "tool_name": "lookup", "tool_call_id": "call_1"},
+	}, sent["messages"])
+	assert.Equal(t, []any{map[string]any{"type": "function", "function": map[string]any{
+		"name":        "lookup",
+		"description": "Resolves a host name",
+		"parameters": map[string]any{
+			"type":       "object",
+			"required":   []any{"host"},
+			"properties": map[string]any{"host": map[string]any{"type": "string", "description": "Host name"}},
+		},
+	}}}, sent["tools"])
+
+	require.Len(t, responses, 1)
+	parts := responses[0].Content.Parts
+	require.Len(t, parts, 1)
+	assert.Equal(t, &genai.FunctionCall{ID: "call_2", Name: "lookup", Args: map[string]any{"host": "example.org"}}, parts[0].FunctionCall)
+}
+
+func TestGenerationConfig(t *testing.T) {
+	p, last := testServer(t,
+		`{"model": "qwen3", "message": {"role": "assistant", "content": "{\"answer\": 42}"}, "done": true, "done_reason": "length", "prompt_eval_count": 26, "eval_count": 9}`,
+	)
+
+	temperature, topP, topK := float32(0.5), float32(0.9), float32(40)
+	seed := int32(7)
+	req := &model.LLMRequest{
+		Contents: []*genai.Content{genai.NewContentFromText("What is the answer?", genai.RoleUser)},
+		Config: &genai.GenerateContentConfig{
+			SystemInstruction: genai.NewContentFromText("You answer in JSON.", genai.RoleUser),
+			Temperature:       &temperature,
+			TopP:              &topP,
+			TopK:              &topK,
+			MaxOutputTokens:   9,
+			StopSequences:     []string{"END"},
+			Seed:              &seed,
+			ResponseMIMEType:  "application/json",
+			ResponseSchema: &genai.Schema{
+				Type:       genai.TypeObject,
+				Properties: map[string]*genai.Schema{"answer": {Type: genai.TypeInteger}},
+			},
+		},
+	}
+	responses := collect(t, p, req, false)
+
+	sent := *last
+	messages := sent["messages"].([]any)
+	require.Len(t, messages, 2)
+	assert.Equal(t, map[string]any{"role": "system", "content": "You answer in JSON."}, messages[0])
+	assert.Equal(t, map[string]any{
+		"temperature": 0.5,
+		"top_p":       0.9,
+		"top_k":       float64(40),
+		"num_predict": float64(9),
+		"stop":        []any{"END"},
+		"seed":        float64(7),
+	}, sent["options"])
+	assert.Equal(t, map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"answer": map[string]any{"type": "integer"}},
+	}, sent["format"])
+
+	require.Len(t, responses, 1)
+	resp := responses[0]
+	assert.Equal(t, genai.FinishReasonMaxTokens, resp.FinishReason)
+	assert.Equal(t, &genai.GenerateContentResponseUsageMetadata{
+		PromptTokenCount:     26,
+		CandidatesTokenCount: 9,
+		TotalTokenCount:      35,
+	}, resp.UsageMetadata)
+
+	// Without schema a JSON response is requested with the json format
+	req.Config.ResponseSchema = nil
+	collect(t, p, req, false)
+	assert.Equal(t, "json", (*last)["format"])
+
+	// The context window is sent as num_ctx, a thinking budget of 0
+	// disables thinking
+	p.SetContextWindow(32768)
+	req.Config = &genai.GenerateContentConfig{ThinkingConfig: &genai.ThinkingConfig{ThinkingBudget: genai.Ptr[int32](0)}}
+	collect(t, p, req, false)
+	assert.Equal(t, map[string]any{"num_ctx": float64(32768)}, (*last)["options"])
+	assert.Equal(t, false, (*last)["think"])
+}
+
+func TestImages(t *testing.T) {
+	p, last := testServer(t,
+		`{"model": "llava", "message": {"role": "assistant", "content": "Two cats."}, "done": true}`,
+	)
+	file := filepath.Join(t.TempDir(), "cats.jpg")
+	require.NoError(t, os.WriteFile(file, []byte{4, 5, 6}, 0644))
+
+	responses := collect(t, p, &model.LLMRequest{
+		Contents: []*genai.Content{{Role: genai.RoleUser, Parts: []*genai.Part{
+			genai.NewPartFromText("What is on these pictures?"),
+			genai.NewPartFromBytes([]byte{1, 2, 3}, "image/png"),
+			genai.NewPartFromURI("file://"+file, "image/jpeg"),
+		}}},
+	}, false)
+	require.Len(t, responses, 1)
+
+	assert.Equal(t, []any{map[string]any{
+		"role":    "user",
+		"content": "What is on these pictures?",
+		"images":  []any{"AQID", "BAUG"},
+	}}, (*last)["messages"])
+
+	for resp, err := range p.GenerateContent(context.Background(), &model.LLMRequest{
+		Contents: []*genai.Content{{Role: genai.RoleUser, Parts: []*genai.Part{
+			genai.NewPartFromBytes([]byte{1}, "application/pdf"),
+		}}},
+	}, false) {
+		assert.Nil(t, resp)
+		assert.ErrorContains(t, err, "unsupported attachment type application/pdf")
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size := int64(3)
+		if r.URL.Path == "/huge.png" {
+			size = runner.MaxAttachmentSize + 1
+		}
+		io.CopyN(w, zeros{}, size)
+	}))
+	defer srv.Close()
+
+	data, err := readFile(context.Background(), &genai.FileData{FileURI: srv.URL + "/cat.png", MIMEType: "image/png"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0}, []byte(data))
+
+	_, err = readFile(context.Background(), &genai.FileData{FileURI: srv.URL + "/huge.png", MIMEType: "image/png"})
+	assert.EqualError(t, err, "image "+srv.URL+"/huge.png is larger than 20 MB")
+}
+
+// zeros is an endless reader of zero bytes.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestStreaming(t *testing.T) {
+	p, last := testServer(t,
+		`{"model": "qwen3", "message": {"role": "assistant", "content": "", "thinking": "The user "}, "done": false}`,
+		`{"model": "qwen3", "message": {"role": "assistant", "content": "", "thinking": "greets."}, "done": false}`,
+		`{"model": "qwen3", "message": {"role": "assistant", "content": "Hel"}, "done": false}`,
+		`{"model": "qwen3", "message": {"role": "assistant", "content": "lo"}, "done": false}`,
+		`{"model": "qwen3", "message": {"role": "assistant", "content": "", "tool_calls": [{"function": {"name": "wave", "arguments": {}}}]}, "done": false}`,
+		`{"model": "qwen3", "message": {"role": "assistant", "content": ""}, "done": true, "done_reason": "stop", "prompt_eval_count": 5, "eval_count": 12}`,
+	)
+	req := &model.LLMRequest{
+		Contents: []*genai.Content{genai.NewContentFromText("Hi", genai.RoleUser)},
+	}
+
+	responses := collect(t, p, req, true)
+	assert.Equal(t, true, (*last)["stream"])
+	require.Len(t, responses, 5)
+	for _, resp := range responses[:4] {
+		assert.True(t, resp.Partial)
+		assert.False(t, resp.TurnComplete)
+	}
+	assert.Equal(t, &genai.Part{Text: "The user ", Thought: true}, responses[0].Content.Parts[0])
+	assert.Equal(t, &genai.Part{Text: "Hel"}, responses[2].Content.Parts[0])
+
+	final := responses[4]
+	assert.False(t, final.Partial)
+	assert.True(t, final.TurnComplete)
+	assert.Equal(t, []*genai.Part{
+		{Text: "The user greets.", Thought: true},
+		{Text: "Hello"},
+		{FunctionCall: &genai.FunctionCall{Name: "wave", Args: map[string]any{}}},
+	}, final.Content.Parts)
+	assert.Equal(t, int32(17), final.UsageMetadata.TotalTokenCount)
+
+	// Without streaming only the complete response is returned
+	responses = collect(t, p, req, false)
+	assert.Equal(t, false, (*last)["stream"])
+	require.Len(t, responses, 1)
+	assert.False(t, responses[0].Partial)
+	assert.Equal(t, "Hello", responses[0].Content.Parts[1].Text)
+
+	// The thinking is sent back separately
+	req.Contents = append(req.Contents, final.Content)
+	collect(t, p, req, false)
+	messages := (*last)["messages"].([]any)
+	assert.Equal(t, "The user greets.", messages[1].(map[string]any)["thinking"])
+	assert.Equal(t, "Hello", messages[1].(map[string]any)["content"])
+}
+
+func TestDescribeModells(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			fmt.Fprint(w, `{"models": [{"name": "granite4:3b", "model": "granite4:3b", "details": {"family": "granite", "parameter_size": "3.4B", "quantization_level": "Q4_K_M"}}]}`)
+		case "/api/show":
+			var req map[string]any
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, "granite4:3b", req["model"])
+			fmt.Fprint(w, `{"capabilities": ["completion", "tools"], "model_info": {"general.architecture": "granite", "granite.context_length": 131072}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p, err := New(srv.URL, "")
+	require.NoError(t, err)
+	models, err := p.DescribeModells(context.Background())
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	assert.Equal(t, "granite4:3b", models[0].Name)
+	assert.Equal(t, allmendmodel.Info{
+		Family:        "granite",
+		ParameterSize: "3.4B",
+		Quantization:  "Q4_K_M",
+		ContextLength: 131072,
+		Capabilities:  []string{"completion", "tools"},
+	}, models[0].Info)
+	assert.Equal(t, "chat", models[0].DefaultType())
+}
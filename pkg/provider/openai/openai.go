@@ -0,0 +1,518 @@
+// Package openai implements a provider for the chat completions API of
+// OpenAI and the many servers compatible with it, like vLLM, the llama.cpp
+// server, LocalAI and LiteLLM.
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"sort"
+	"strings"
+
+	allmendmodel "github.com/SUSE/allmend/pkg/model"
+	"github.com/SUSE/allmend/pkg/provider/internal/genaiutil"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// DefaultBaseURL is the API of OpenAI itself.
+const DefaultBaseURL = "https://api.openai.com/v1"
+
+// Config configures the connection to the API.
+type Config struct {
+	// BaseURL of the API including the version, like http://localhost:8000/v1
+	BaseURL      string
+	APIKey       string
+	Organization string
+	// Headers are added to every request.
+	Headers map[string]string
+	// Client is used for the requests, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// Provider implements the model.LLM interface for OpenAI compatible APIs.
+type Provider struct {
+	config Config
+	model  string
+}
+
+// New creates a new OpenAI compatible provider.
+func New(config Config, modelName string) (*Provider, error) {
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultBaseURL
+	}
+	if !strings.HasPrefix(config.BaseURL, "http://") && !strings.HasPrefix(config.BaseURL, "https://") {
+		return nil, fmt.Errorf("invalid openai base url: %s", config.BaseURL)
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	return &Provider{config: config, model: modelName}, nil
+}
+
+// Name returns the name of the model.
+func (p *Provider) Name() string {
+	return p.model
+}
+
+// GenerateContent generates content from the model. When streaming, the
+// text is yielded in partial responses followed by a complete response
+// with the whole text, the tool calls and the usage.
+func (p *Provider) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		body, err := p.chatRequest(req, stream)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		resp, err := p.post(ctx, "/chat/completions", body)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		if !stream {
+			var completion chatResponse
+			if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+				yield(nil, fmt.Errorf("failed to decode openai response: %w", err))
+				return
+			}
+			if len(completion.Choices) == 0 {
+				yield(nil, fmt.Errorf("openai response has no choices"))
+				return
+			}
+			choice := completion.Choices[0]
+			yield(newResponse(choice.Message.Content, choice.Message.ToolCalls, choice.FinishReason, completion.Usage), nil)
+			return
+		}
+
+		var (
+			text         strings.Builder
+			calls        = make(map[int]*toolCall)
+			finishReason string
+			usage        *chatUsage
+		)
+		err = readEvents(resp.Body, func(chunk *chatResponse) bool {
+			if chunk.Usage != nil {
+				usage = chunk.Usage
+			}
+			if len(chunk.Choices) == 0 {
+				return true
+			}
+			choice := chunk.Choices[0]
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+			for _, d := range choice.Delta.ToolCalls {
+				call, ok := calls[d.Index]
+				if !ok {
+					call = &toolCall{Type: "function"}
+					calls[d.Index] = call
+				}
+				if d.ID != "" {
+					call.ID = d.ID
+				}
+				call.Function.Name += d.Function.Name
+				call.Function.Arguments += d.Function.Arguments
+			}
+			if choice.Delta.Content == "" {
+				return true
+			}
+			text.WriteString(choice.Delta.Content)
+			return yield(&model.LLMResponse{
+				Content: genai.NewContentFromText(choice.Delta.Content, genai.RoleModel),
+				Partial: true,
+			}, nil)
+		})
+		if err != nil {
+			if err != errStopped {
+				yield(nil, err)
+			}
+			return
+		}
+
+		indexes := make([]int, 0, len(calls))
+		for i := range calls {
+			indexes = append(indexes, i)
+		}
+		sort.Ints(indexes)
+		toolCalls := make([]toolCall, 0, len(indexes))
+		for _, i := range indexes {
+			toolCalls = append(toolCalls, *calls[i])
+		}
+		yield(newResponse(text.String(), toolCalls, finishReason, usage), nil)
+	}
+}
+
+// GetModells returns a list of available models.
+func (p *Provider) GetModells(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.BaseURL+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list openai models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode openai models: %w", err)
+	}
+	var models []string
+	for _, m := range list.Data {
+		models = append(models, m.ID)
+	}
+	return models, nil
+}
+
+// DescribeModells returns the available models. The API reports nothing but
+// their names.
+func (p *Provider) DescribeModells(ctx context.Context) ([]allmendmodel.Model, error) {
+	names, err := p.GetModells(ctx)
+	if err != nil {
+		return nil, err
+	}
+	models := make([]allmendmodel.Model, 0, len(names))
+	for _, name := range names {
+		models = append(models, allmendmodel.Model{Name: name})
+	}
+	return models, nil
+}
+
+func (p *Provider) post(ctx context.Context, path string, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode openai request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.BaseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return p.do(req)
+}
+
+// do sends the request with the configured headers and turns error
+// responses into errors.
+func (p *Provider) do(req *http.Request) (*http.Response, error) {
+	if p.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	}
+	if p.config.Organization != "" {
+		req.Header.Set("OpenAI-Organization", p.config.Organization)
+	}
+	for k, v := range p.config.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := p.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+			msg = apiErr.Error.Message
+		}
+		return nil, &genaiutil.StatusError{API: "openai", Status: resp.Status, StatusCode: resp.StatusCode, Message: msg}
+	}
+	return resp, nil
+}
+
+// Chat completions API
+
+type chatRequest struct {
+	Model            string         `json:"model"`
+	Messages         []*chatMessage `json:"messages"`
+	Tools            []*chatTool    `json:"tools,omitempty"`
+	Stream           bool           `json:"stream,omitempty"`
+	StreamOptions    *streamOptions `json:"stream_options,omitempty"`
+	Temperature      *float32       `json:"temperature,omitempty"`
+	TopP             *float32       `json:"top_p,omitempty"`
+	MaxTokens        int32          `json:"max_tokens,omitempty"`
+	Stop             []string       `json:"stop,omitempty"`
+	Seed             *int32         `json:"seed,omitempty"`
+	PresencePenalty  *float32       `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float32       `json:"frequency_penalty,omitempty"`
+	ResponseFormat   map[string]any `json:"response_format,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatMessage struct {
+	Role string `json:"role"`
+	// Content is a string or a list of content parts.
+	Content    any        `json:"content,omitempty"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+type contentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *imageURL `json:"image_url,omitempty"`
+}
+
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+type toolCall struct {
+	Index    int    `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments,omitempty"`
+	} `json:"function"`
+}
+
+type chatTool struct {
+	Type     string       `json:"type"`
+	Function chatFunction `json:"function"`
+}
+
+type chatFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content   string     `json:"content"`
+			ToolCalls []toolCall `json:"tool_calls"`
+		} `json:"message"`
+		Delta struct {
+			Content   string     `json:"content"`
+			ToolCalls []toolCall `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *chatUsage `json:"usage"`
+	// Error is sent by some servers in the stream
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type chatUsage struct {
+	PromptTokens     int32 `json:"prompt_tokens"`
+	CompletionTokens int32 `json:"completion_tokens"`
+	TotalTokens      int32 `json:"total_tokens"`
+}
+
+// chatRequest translates the ADK request.
+func (p *Provider) chatRequest(req *model.LLMRequest, stream bool) (*chatRequest, error) {
+	modelName := p.model
+	if req.Model != "" {
+		modelName = req.Model
+	}
+	out := &chatRequest{Model: modelName, Stream: stream}
+	if stream {
+		out.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+
+	if system := genaiutil.SystemText(req.Config); system != "" {
+		out.Messages = append(out.Messages, &chatMessage{Role: "system", Content: system})
+	}
+	for _, content := range req.Contents {
+		msgs, err := convertContent(content)
+		if err != nil {
+			return nil, err
+		}
+		out.Messages = append(out.Messages, msgs...)
+	}
+
+	for _, decl := range genaiutil.FunctionDeclarations(req.Config) {
+		out.Tools = append(out.Tools, &chatTool{
+			Type: "function",
+			Function: chatFunction{
+				Name:        decl.Name,
+				Description: decl.Description,
+				Parameters:  genaiutil.ParametersSchema(decl),
+			},
+		})
+	}
+
+	if cfg := req.Config; cfg != nil {
+		out.Temperature = cfg.Temperature
+		out.TopP = cfg.TopP
+		out.MaxTokens = cfg.MaxOutputTokens
+		out.Stop = cfg.StopSequences
+		out.Seed = cfg.Seed
+		out.PresencePenalty = cfg.PresencePenalty
+		out.FrequencyPenalty = cfg.FrequencyPenalty
+		if cfg.ResponseMIMEType == "application/json" {
+			out.ResponseFormat = map[string]any{"type": "json_object"}
+		}
+	}
+	return out, nil
+}
+
+// convertContent translates a genai content into chat messages. Function
+// responses become tool messages of their own.
+func convertContent(content *genai.Content) ([]*chatMessage, error) {
+	role := content.Role
+	if role == genai.RoleModel {
+		role = "assistant"
+	}
+	if role == "" {
+		role = genai.RoleUser
+	}
+
+	var (
+		msgs     []*chatMessage
+		parts    []contentPart
+		calls    []toolCall
+		hasImage bool
+	)
+	for _, part := range content.Parts {
+		switch {
+		case part.Thought:
+			continue
+		case part.FunctionCall != nil:
+			args, err := json.Marshal(part.FunctionCall.Args)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode arguments of %s: %w", part.FunctionCall.Name, err)
+			}
+			call := toolCall{ID: part.FunctionCall.ID, Type: "function"}
+			call.Function.Name = part.FunctionCall.Name
+			call.Function.Arguments = string(args)
+			calls = append(calls, call)
+		case part.FunctionResponse != nil:
+			msgs = append(msgs, &chatMessage{
+				Role:       "tool",
+				ToolCallID: part.FunctionResponse.ID,
+				Content:    genaiutil.ResponseText(part.FunctionResponse),
+			})
+		case part.InlineData != nil:
+			if !strings.HasPrefix(part.InlineData.MIMEType, "image/") {
+				return nil, fmt.Errorf("unsupported attachment type %s", part.InlineData.MIMEType)
+			}
+			hasImage = true
+			parts = append(parts, contentPart{Type: "image_url", ImageURL: &imageURL{
+				URL: "data:" + part.InlineData.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(part.InlineData.Data),
+			}})
+		case part.Text != "":
+			parts = append(parts, contentPart{Type: "text", Text: part.Text})
+		}
+	}
+
+	if len(parts) > 0 || len(calls) > 0 {
+		msg := &chatMessage{Role: role, ToolCalls: calls}
+		if hasImage {
+			msg.Content = parts
+		} else if len(parts) > 0 {
+			var text strings.Builder
+			for _, p := range parts {
+				text.WriteString(p.Text)
+			}
+			msg.Content = text.String()
+		}
+		msgs = append([]*chatMessage{msg}, msgs...)
+	}
+	return msgs, nil
+}
+
+// newResponse creates the complete ADK response of a completion.
+func newResponse(text string, calls []toolCall, finishReason string, usage *chatUsage) *model.LLMResponse {
+	content := &genai.Content{Role: genai.RoleModel}
+	if text != "" {
+		content.Parts = append(content.Parts, genai.NewPartFromText(text))
+	}
+	for _, c := range calls {
+		args := map[string]any{}
+		if c.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(c.Function.Arguments), &args); err != nil {
+				// Models sometimes produce broken JSON, the tool reports it
+				args = map[string]any{"_raw_arguments": c.Function.Arguments}
+			}
+		}
+		content.Parts = append(content.Parts, &genai.Part{FunctionCall: &genai.FunctionCall{
+			ID:   c.ID,
+			Name: c.Function.Name,
+			Args: args,
+		}})
+	}
+	resp := &model.LLMResponse{
+		Content:      content,
+		TurnComplete: true,
+		FinishReason: finishReasons[finishReason],
+	}
+	if resp.FinishReason == "" {
+		resp.FinishReason = genai.FinishReasonStop
+	}
+	if usage != nil {
+		resp.UsageMetadata = &genai.GenerateContentResponseUsageMetadata{
+			PromptTokenCount:     usage.PromptTokens,
+			CandidatesTokenCount: usage.CompletionTokens,
+			TotalTokenCount:      usage.TotalTokens,
+		}
+	}
+	return resp
+}
+
+var finishReasons = map[string]genai.FinishReason{
+	"stop":           genai.FinishReasonStop,
+	"tool_calls":     genai.FinishReasonStop,
+	"function_call":  genai.FinishReasonStop,
+	"length":         genai.FinishReasonMaxTokens,
+	"content_filter": genai.FinishReasonSafety,
+}
+
+var errStopped = fmt.Errorf("yield stopped")
+
+// readEvents reads the server-sent events of a streamed completion and
+// calls handle for every chunk until the stream ends or handle returns
+// false.
+func readEvents(r io.Reader, handle func(*chatResponse) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return nil
+		}
+		var chunk chatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to decode openai stream: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("openai api error: %s", chunk.Error.Message)
+		}
+		if !handle(&chunk) {
+			return errStopped
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,220 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// testServer is a stand-in for an OpenAI compatible server. It records the
+// last chat request and answers with handler.
+func testServer(t *testing.T, handler func(w http.ResponseWriter, req map[string]any)) (*httptest.Server, *map[string]any, *http.Header) {
+	t.Helper()
+	var last map[string]any
+	var headers http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		switch r.URL.Path {
+		case "/v1/models":
+			fmt.Fprint(w, `{"object": "list", "data": [{"id": "llama-3"}, {"id": "qwen"}]}`)
+		case "/v1/chat/completions":
+			last = nil
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&last))
+			handler(w, last)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &last, &headers
+}
+
+func collect(t *testing.T, p *Provider, req *model.LLMRequest, stream bool) ([]*model.LLMResponse, error) {
+	t.Helper()
+	var responses []*model.LLMResponse
+	for resp, err := range p.GenerateContent(context.Background(), req, stream) {
+		if err != nil {
+			return responses, err
+		}
+		responses = append(responses, resp)
+	}
+	return responses, nil
+}
+
+func TestGenerateContent(t *testing.T) {
+	srv, last, headers := testServer(t, func(w http.ResponseWriter, req map[string]any) {
+		fmt.Fprint(w, `{
+			"choices": [{
+				"message": {"role": "assistant", "content": "", "tool_calls": [
+					{"id": "call_1", "type": "function", "function": {"name": "lookup", "arguments": "{\"host\": \"example.org\"}"}}
+				]},
+				"finish_reason": "tool_calls"
+			}],
+			"usage": {"prompt_tokens": 12, "completion_tokens": 5, "total_tokens": 17}
+		}`)
+	})
+	p, err := New(Config{
+		BaseURL:      srv.URL + "/v1/",
+		APIKey:       "secret",
+		Organization: "org-1",
+		Headers:      map[string]string{"X-Team": "allmend"},
+	}, "llama-3")
+	require.NoError(t, err)
+
+	temperature := float32(0.2)
+	req := &model.LLMRequest{
+		Contents: []*genai.Content{
+			genai.NewContentFromText("Where is example.org?", genai.RoleUser),
+			{Role: genai.RoleModel, Parts: []*genai.Part{
+				{FunctionCall: &genai.FunctionCall{ID: "call_0", Name: "lookup", Args: map[string]any{"host": "example.com"}}},
+			}},
+			{Role: genai.RoleUser, Parts: []*genai.Part{
+				{FunctionResponse: &genai.FunctionResponse{ID: "call_0", Name: "lookup", Response: map[string]any{"output": "93.184.216.34"}}},
+			}},
+		},
+		Config: &genai.GenerateContentConfig{
+			SystemInstruction: genai.NewContentFromText("You are a network helper.", genai.RoleUser),
+			Temperature:       &temperature,
+			MaxOutputTokens:   100,
+			Tools: []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{{
+				Name:        "lookup",
+				Description: "Resolves a host name",
+				Parameters: &genai.Schema{
+					Type:       genai.TypeObject,
+					Properties: map[string]*genai.Schema{"host": {Type: genai.TypeString}},
+					Required:   []string{"host"},
+				},
+			}}}},
+		},
+	}
+	responses, err := collect(t, p, req, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Bearer secret", headers.Get("Authorization"))
+	assert.Equal(t, "org-1", headers.Get("OpenAI-Organization"))
+	assert.Equal(t, "allmend", headers.Get("X-Team"))
+
+	sent := *last
+	assert.Equal(t, "llama-3", sent["model"])
+	assert.InDelta(t, 0.2, sent["temperature"], 0.001)
+	assert.Equal(t, float64(100), sent["max_tokens"])
+	assert.Nil(t, sent["stream"])
+	messages := sent["messages"].([]any)
+	require.Len(t, messages, 4)
+	assert.Equal(t, map[string]any{"role": "system", "content": "You are a network helper."}, messages[0])
+	assert.Equal(t, map[string]any{"role": "user", "content": "Where is example.org?"}, messages[1])
+	assert.Equal(t, map[string]any{"role": "assistant", "tool_calls": []any{map[string]any{
+		"id": "call_0", "type": "function", "function": map[string]any{"name": "lookup", "arguments": `{"host":"example.com"}`},
+	}}}, messages[2])
+	assert.Equal(t, map[string]any{"role": "tool", "tool_call_id": "call_0", "content": `{"output":"93.184.216.34"}`}, messages[3])
+	assert.Equal(t, []any{map[string]any{"type": "function", "function": map[string]any{
+		"name":        "lookup",
+		"description": "Resolves a host name",
+		"parameters": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"host": map[string]any{"type": "string"}},
+			"required":   []any{"host"},
+		},
+	}}}, sent["tools"])
+
+	require.Len(t, responses, 1)
+	resp := responses[0]
+	assert.True(t, resp.TurnComplete)
+	assert.Equal(t, genai.FinishReasonStop, resp.FinishReason)
+	require.Len(t, resp.Content.Parts, 1)
+	assert.Equal(t, &genai.FunctionCall{ID: "call_1", Name: "lookup", Args: map[string]any{"host": "example.org"}}, resp.Content.Parts[0].FunctionCall)
+	assert.Equal(t, int32(17), resp.UsageMetadata.TotalTokenCount)
+}
+
+func TestGenerateContentStream(t *testing.T) {
+	srv, last, _ := testServer(t, func(w http.ResponseWriter, req map[string]any) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"choices": [{"delta": {"role": "assistant", "content": "Hel"}}]}`,
+			`{"choices": [{"delta": {"content": "lo"}}]}`,
+			`{"choices": [{"delta": {"tool_calls": [{"index": 0, "id": "call_1", "type": "function", "function": {"name": "greet", "arguments": "{\"na"}}]}}]}`,
+			`{"choices": [{"delta": {"tool_calls": [{"index": 0, "function": {"arguments": "me\": \"Bob\"}"}}]}}]}`,
+			`{"choices": [{"delta": {}, "finish_reason": "length"}]}`,
+			`{"choices": [], "usage": {"prompt_tokens": 3, "completion_tokens": 4, "total_tokens": 7}}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+	p, err := New(Config{BaseURL: srv.URL + "/v1"}, "qwen")
+	require.NoError(t, err)
+
+	responses, err := collect(t, p, &model.LLMRequest{
+		Contents: []*genai.Content{genai.NewContentFromText("Hi", genai.RoleUser)},
+	}, true)
+	require.NoError(t, err)
+
+	assert.Equal(t, true, (*last)["stream"])
+	assert.Equal(t, map[string]any{"include_usage": true}, (*last)["stream_options"])
+	require.Len(t, responses, 3)
+	assert.True(t, responses[0].Partial)
+	assert.Equal(t, "Hel", responses[0].Content.Parts[0].Text)
+	assert.Equal(t, "lo", responses[1].Content.Parts[0].Text)
+
+	final := responses[2]
+	assert.False(t, final.Partial)
+	assert.True(t, final.TurnComplete)
+	assert.Equal(t, genai.FinishReasonMaxTokens, final.FinishReason)
+	require.Len(t, final.Content.Parts, 2)
+	assert.Equal(t, "Hello", final.Content.Parts[0].Text)
+	assert.Equal(t, map[string]any{"name": "Bob"}, final.Content.Parts[1].FunctionCall.Args)
+	assert.Equal(t, int32(7), final.UsageMetadata.TotalTokenCount)
+}
+
+func TestGenerateContentError(t *testing.T) {
+	srv, _, _ := testServer(t, func(w http.ResponseWriter, req map[string]any) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error": {"message": "Incorrect API key provided"}}`)
+	})
+	p, err := New(Config{BaseURL: srv.URL + "/v1"}, "qwen")
+	require.NoError(t, err)
+
+	_, err = collect(t, p, &model.LLMRequest{
+		Contents: []*genai.Content{genai.NewContentFromText("Hi", genai.RoleUser)},
+	}, false)
+	assert.ErrorContains(t, err, "401 Unauthorized: Incorrect API key provided")
+
+	_, err = New(Config{BaseURL: "localhost:8000"}, "qwen")
+	assert.ErrorContains(t, err, "invalid openai base url")
+}
+
+func TestImages(t *testing.T) {
+	msgs, err := convertContent(&genai.Content{Role: genai.RoleUser, Parts: []*genai.Part{
+		genai.NewPartFromText("What is this?"),
+		genai.NewPartFromBytes([]byte{1, 2, 3}, "image/png"),
+	}})
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, []contentPart{
+		{Type: "text", Text: "What is this?"},
+		{Type: "image_url", ImageURL: &imageURL{URL: "data:image/png;base64,AQID"}},
+	}, msgs[0].Content)
+
+	_, err = convertContent(&genai.Content{Role: genai.RoleUser, Parts: []*genai.Part{
+		genai.NewPartFromBytes([]byte{1}, "application/pdf"),
+	}})
+	assert.ErrorContains(t, err, "unsupported attachment type application/pdf")
+}
+
+func TestGetModells(t *testing.T) {
+	srv, _, _ := testServer(t, nil)
+	p, err := New(Config{BaseURL: srv.URL + "/v1"}, "")
+	require.NoError(t, err)
+
+	models, err := p.GetModells(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"llama-3", "qwen"}, models)
+}
@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"context"
+	"iter"
+
+	allmendmodel "github.com/SUSE/allmend/pkg/model"
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// WithParams returns llm applying the generation parameters p to every
+// request, over the ones of the request. This way every model of a fallback
+// chain or group gets the parameters of its own config. The context window
+// isn't applied, see ContextWindowSetter.
+func WithParams(llm model.LLM, p allmendmodel.Params) model.LLM {
+	cfg := p.GenerateContentConfig()
+	if cfg == nil {
+		return llm
+	}
+	return &paramsLLM{LLM: llm, cfg: cfg}
+}
+
+type paramsLLM struct {
+	model.LLM
+	cfg *genai.GenerateContentConfig
+}
+
+func (l *paramsLLM) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	var cfg genai.GenerateContentConfig
+	if req.Config != nil {
+		cfg = *req.Config
+	}
+	if l.cfg.Temperature != nil {
+		cfg.Temperature = l.cfg.Temperature
+	}
+	if l.cfg.TopP != nil {
+		cfg.TopP = l.cfg.TopP
+	}
+	if l.cfg.TopK != nil {
+		cfg.TopK = l.cfg.TopK
+	}
+	if l.cfg.MaxOutputTokens != 0 {
+		cfg.MaxOutputTokens = l.cfg.MaxOutputTokens
+	}
+	if l.cfg.Seed != nil {
+		cfg.Seed = l.cfg.Seed
+	}
+	if l.cfg.StopSequences != nil {
+		cfg.StopSequences = l.cfg.StopSequences
+	}
+	if l.cfg.ThinkingConfig != nil {
+		cfg.ThinkingConfig = l.cfg.ThinkingConfig
+	}
+	r := *req
+	r.Config = &cfg
+	return l.LLM.GenerateContent(ctx, &r, stream)
+}
@@ -1,7 +1,20 @@
 package provider
 
-import "context"
+import (
+	"context"
+
+	"github.com/SUSE/allmend/pkg/model"
+)
 
 type ProviderConnection interface {
 	GetModells(ctx context.Context) ([]string, error)
+	// DescribeModells returns the available models with the metadata the
+	// provider reports about them. The provider of the models is left empty.
+	DescribeModells(ctx context.Context) ([]model.Model, error)
+}
+
+// ContextWindowSetter is implemented by models where the client chooses the
+// size of the context window, like Ollama.
+type ContextWindowSetter interface {
+	SetContextWindow(tokens int)
 }
@@ -0,0 +1,231 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/adk/model"
+
+	"github.com/SUSE/allmend/internal/config"
+)
+
+// Factory describes a provider type. Backends register a factory with
+// Register, usually in an init function, and are then available for
+// configurations, model connections and 'provider add'.
+type Factory struct {
+	// Type is the name used as type in the provider configuration.
+	Type string
+	// Aliases are alternative type names.
+	Aliases []string
+	// Description names the backend, like "an Ollama provider".
+	Description string
+	// Help is an optional longer description for 'provider add'.
+	Help string
+	// Fields are the configuration keys of the type.
+	Fields []Field
+	// NewLLM creates the model for ADK agents.
+	NewLLM func(ctx context.Context, cfg Values, modelName string) (model.LLM, error)
+	// NewConnection creates a connection for management tasks.
+	NewConnection func(ctx context.Context, cfg Values) (ProviderConnection, error)
+	// Check optionally validates the combination of the values, after the
+	// fields were checked one by one.
+	Check func(cfg Values) error
+}
+
+// Field describes a configuration key of a provider type.
+type Field struct {
+	// Key in the configuration, like "api_key".
+	Key string
+	// Flag is the name of the flag of 'provider add', by default the key
+	// with dashes instead of underscores.
+	Flag string
+	// Name is the human readable name, like "API Key".
+	Name string
+	// Usage is the help text of the flag.
+	Usage string
+	// Default is used if the key isn't configured.
+	Default string
+	// Env is the environment variable, or the key in the .env file of the
+	// current directory, read if the key isn't configured.
+	Env string
+	// Required fields must have a value.
+	Required bool
+	// Choices are the allowed values, any value if empty.
+	Choices []string
+	// Map fields hold KEY=VALUE pairs, like extra headers, instead of a
+	// single string.
+	Map bool
+}
+
+// FlagName returns the name of the flag for the field.
+func (f Field) FlagName() string {
+	if f.Flag != "" {
+		return f.Flag
+	}
+	return strings.ReplaceAll(f.Key, "_", "-")
+}
+
+// Values is the configuration of a provider with defaults and environment
+// variables applied.
+type Values map[string]any
+
+// String returns the value of key as string.
+func (v Values) String(key string) string {
+	switch s := v[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	default:
+		return fmt.Sprint(s)
+	}
+}
+
+// StringMap returns the value of a map field.
+func (v Values) StringMap(key string) map[string]string {
+	var out map[string]string
+	switch m := v[key].(type) {
+	case map[string]string:
+		return m
+	case map[string]any:
+		out = make(map[string]string, len(m))
+		for k, val := range m {
+			out[k] = fmt.Sprint(val)
+		}
+	}
+	return out
+}
+
+var (
+	registryMu sync.RWMutex
+	factories  = make(map[string]Factory)
+	aliases    = make(map[string]string)
+)
+
+// Register makes a provider type available. It panics if the type or one
+// of its aliases is already registered or if a constructor is missing.
+func Register(f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if f.Type == "" || f.NewLLM == nil || f.NewConnection == nil {
+		panic("provider: Register of incomplete provider type " + f.Type)
+	}
+	for _, name := range append([]string{f.Type}, f.Aliases...) {
+		if _, dup := factories[name]; dup {
+			panic("provider: Register called twice for provider type " + name)
+		}
+		if _, dup := aliases[name]; dup {
+			panic("provider: Register called twice for provider type " + name)
+		}
+	}
+	factories[f.Type] = f
+	for _, alias := range f.Aliases {
+		aliases[alias] = f.Type
+	}
+}
+
+// Unregister removes a provider type and its aliases. It is meant for tests
+// registering types of their own.
+func Unregister(typeName string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for alias, name := range aliases {
+		if name == typeName {
+			delete(aliases, alias)
+		}
+	}
+	delete(factories, typeName)
+}
+
+// Lookup returns the factory of a provider type or alias.
+func Lookup(typeName string) (Factory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if name, ok := aliases[typeName]; ok {
+		typeName = name
+	}
+	f, ok := factories[typeName]
+	return f, ok
+}
+
+// Types returns the registered provider types sorted by name.
+func Types() []Factory {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	types := make([]Factory, 0, len(factories))
+	for _, f := range factories {
+		types = append(types, f)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Type < types[j].Type })
+	return types
+}
+
+// Resolve applies the environment variables, the .env file and the defaults
+// of the fields to cfg and validates the result.
+func (f Factory) Resolve(cfg map[string]any) (Values, error) {
+	values := make(Values, len(cfg)+len(f.Fields))
+	for k, v := range cfg {
+		values[k] = v
+	}
+	for _, field := range f.Fields {
+		if !field.Map && values.String(field.Key) == "" {
+			if v := f.EnvValue(values, field); v != "" {
+				values[field.Key] = v
+			}
+		}
+	}
+	for _, field := range f.Fields {
+		if !field.Map && values.String(field.Key) == "" && field.Default != "" {
+			values[field.Key] = field.Default
+		}
+	}
+	return values, f.Validate(values)
+}
+
+// EnvValue returns the value of the environment variable, or of the .env
+// file, of field. A value which the Check of the type rejects together with
+// values, like an API key for a config with a project, isn't used.
+func (f Factory) EnvValue(values Values, field Field) string {
+	if field.Env == "" {
+		return ""
+	}
+	v := config.GetEnvOrFile(field.Env)
+	if v == "" || f.Check == nil {
+		return v
+	}
+	with := maps.Clone(values)
+	with[field.Key] = v
+	if f.Check(with) != nil {
+		return ""
+	}
+	return v
+}
+
+// Validate checks that the required fields are set, that the values are
+// among the choices and runs the Check of the type.
+func (f Factory) Validate(values Values) error {
+	for _, field := range f.Fields {
+		if field.Map {
+			continue
+		}
+		v := values.String(field.Key)
+		if v == "" {
+			if field.Required {
+				return fmt.Errorf("%s provider requires %s", f.Type, field.Key)
+			}
+			continue
+		}
+		if len(field.Choices) > 0 && !slices.Contains(field.Choices, v) {
+			return fmt.Errorf("invalid %s '%s' for %s provider, use one of %s", field.Key, v, f.Type, strings.Join(field.Choices, ", "))
+		}
+	}
+	if f.Check != nil {
+		return f.Check(values)
+	}
+	return nil
+}
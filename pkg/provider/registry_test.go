@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"context"
+	"iter"
+	"os"
+	"path/filepath"
+	"testing"
+
+	allmendmodel "github.com/SUSE/allmend/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/adk/model"
+)
+
+type fakeBackend struct {
+	cfg   Values
+	model string
+}
+
+func (f *fakeBackend) Name() string { return f.model }
+
+func (f *fakeBackend) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {}
+}
+
+func (f *fakeBackend) GetModells(ctx context.Context) ([]string, error) {
+	return []string{f.cfg.String("region")}, nil
+}
+
+func (f *fakeBackend) DescribeModells(ctx context.Context) ([]allmendmodel.Model, error) {
+	return []allmendmodel.Model{{Name: f.cfg.String("region")}}, nil
+}
+
+func TestRegistry(t *testing.T) {
+	Register(Factory{
+		Type:        "fake",
+		Aliases:     []string{"fake-alias"},
+		Description: "a fake provider",
+		Fields: []Field{
+			{Key: "token", Env: "FAKE_TOKEN", Required: true},
+			{Key: "region", Default: "eu", Choices: []string{"eu", "us"}},
+		},
+		NewLLM: func(ctx context.Context, cfg Values, modelName string) (model.LLM, error) {
+			return &fakeBackend{cfg: cfg, model: modelName}, nil
+		},
+		NewConnection: func(ctx context.Context, cfg Values) (ProviderConnection, error) {
+			return &fakeBackend{cfg: cfg}, nil
+		},
+	})
+	t.Cleanup(func() { Unregister("fake") })
+
+	t.Run("Lookup", func(t *testing.T) {
+		f, ok := Lookup("fake-alias")
+		require.True(t, ok)
+		assert.Equal(t, "fake", f.Type)
+		_, ok = Lookup("unknown")
+		assert.False(t, ok)
+
+		var types []string
+		for _, f := range Types() {
+			types = append(types, f.Type)
+		}
+		assert.Equal(t, []string{"anthropic", "fake", "google", "ollama", "openai"}, types)
+
+		assert.Panics(t, func() {
+			Register(Factory{Type: "other", Aliases: []string{"fake"}, NewLLM: f.NewLLM, NewConnection: f.NewConnection})
+		})
+	})
+
+	t.Run("Resolve", func(t *testing.T) {
+		f, _ := Lookup("fake")
+		_, err := f.Resolve(map[string]any{})
+		assert.ErrorContains(t, err, "fake provider requires token")
+
+		os.Setenv("FAKE_TOKEN", "env-token")
+		defer os.Unsetenv("FAKE_TOKEN")
+		values, err := f.Resolve(map[string]any{"extra": 1})
+		require.NoError(t, err)
+		assert.Equal(t, Values{"token": "env-token", "region": "eu", "extra": 1}, values)
+
+		os.Unsetenv("FAKE_TOKEN")
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("# secrets\nFAKE_TOKEN=\"file-token\"\n"), 0o600))
+		t.Chdir(dir)
+		values, err = f.Resolve(map[string]any{})
+		require.NoError(t, err)
+		assert.Equal(t, "file-token", values.String("token"))
+
+		values, err = f.Resolve(map[string]any{"token": "own", "region": "us"})
+		require.NoError(t, err)
+		assert.Equal(t, "own", values.String("token"))
+
+		_, err = f.Resolve(map[string]any{"region": "mars"})
+		assert.ErrorContains(t, err, "invalid region 'mars' for fake provider, use one of eu, us")
+	})
+
+	t.Run("Provider", func(t *testing.T) {
+		p := Provider{Name: "test", Type: "fake-alias", Config: map[string]any{"token": "t", "region": "us"}}
+		llm, err := p.CreateLLM(context.Background(), "tiny")
+		require.NoError(t, err)
+		assert.Equal(t, "tiny", llm.Name())
+
+		conn, err := p.GetConnection(context.Background())
+		require.NoError(t, err)
+		models, err := conn.GetModells(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"us"}, models)
+
+		_, err = Provider{Type: "unknown"}.CreateLLM(context.Background(), "tiny")
+		assert.ErrorContains(t, err, "unsupported provider type for ADK: unknown")
+	})
+}
+
+func TestValuesStringMap(t *testing.T) {
+	values := Values{"headers": map[string]any{"X-Num": 1, "X-Team": "allmend"}}
+	assert.Equal(t, map[string]string{"X-Num": "1", "X-Team": "allmend"}, values.StringMap("headers"))
+	assert.Nil(t, values.StringMap("missing"))
+}
+
+func TestGoogleConfig(t *testing.T) {
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Chdir(t.TempDir())
+	f, ok := Lookup("gemini")
+	require.True(t, ok)
+
+	values, err := f.Resolve(map[string]any{"api_key": "key"})
+	require.NoError(t, err)
+	cc := googleClientConfig(values)
+	assert.Equal(t, "key", cc.APIKey)
+	assert.Empty(t, cc.Project)
+	assert.Empty(t, cc.Location)
+
+	values, err = f.Resolve(map[string]any{"backend": "vertex", "project_id": "proj", "location": "europe-west4"})
+	require.NoError(t, err)
+	cc = googleClientConfig(values)
+	assert.Empty(t, cc.APIKey)
+	assert.Equal(t, "proj", cc.Project)
+	assert.Equal(t, "europe-west4", cc.Location)
+
+	values, err = f.Resolve(map[string]any{"backend": "vertex", "api_key": "key"})
+	require.NoError(t, err)
+	cc = googleClientConfig(values)
+	assert.Equal(t, "key", cc.APIKey)
+	assert.Empty(t, cc.Project)
+
+	_, err = f.Resolve(map[string]any{"backend": "vertex", "api_key": "key", "project_id": "proj"})
+	assert.EqualError(t, err, "google provider with vertex backend takes either api_key or project_id, remove one of them")
+	_, err = f.Resolve(map[string]any{"api_key": "key", "project_id": "proj"})
+	assert.EqualError(t, err, "google provider uses project_id only with the vertex backend, remove it or set backend to vertex")
+
+	// The key from the environment is only used where it isn't ambiguous
+	t.Setenv("GEMINI_API_KEY", "env-key")
+	values, err = f.Resolve(map[string]any{"backend": "vertex", "project_id": "proj"})
+	require.NoError(t, err)
+	assert.Empty(t, values.String("api_key"))
+	values, err = f.Resolve(map[string]any{"backend": "gemini"})
+	require.NoError(t, err)
+	assert.Equal(t, "env-key", googleClientConfig(values).APIKey)
+}
@@ -0,0 +1,49 @@
+package runner
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/genai"
+)
+
+// MaxAttachmentSize limits the size of a file attached to a prompt.
+const MaxAttachmentSize = 20 * 1024 * 1024
+
+// LoadAttachment reads a file to send along with a prompt. Images are
+// attached as inline data, for vision models, and text files are included
+// in the message together with their name. Other files are rejected.
+func LoadAttachment(path string) (*genai.Part, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > MaxAttachmentSize {
+		return nil, fmt.Errorf("%s is larger than %d MB", path, MaxAttachmentSize/1024/1024)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return genai.NewPartFromBytes(data, mimeType), nil
+	case strings.HasPrefix(mimeType, "text/") || utf8.Valid(data) && !strings.ContainsRune(string(data), 0):
+		return genai.NewPartFromText(fmt.Sprintf("\n\nContent of the file %s:\n\n%s", filepath.Base(path), data)), nil
+	}
+	return nil, fmt.Errorf("unsupported type %s of %s, only images and text files can be attached", mimeType, path)
+}
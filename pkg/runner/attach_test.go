@@ -0,0 +1,42 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadAttachment(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0644))
+		return path
+	}
+
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	part, err := LoadAttachment(write("diagram.png", png))
+	require.NoError(t, err)
+	require.NotNil(t, part.InlineData)
+	assert.Equal(t, "image/png", part.InlineData.MIMEType)
+	assert.Equal(t, png, part.InlineData.Data)
+
+	// The content decides without a known extension
+	part, err = LoadAttachment(write("photo", png))
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", part.InlineData.MIMEType)
+
+	part, err = LoadAttachment(write("main.go", []byte("package main\n")))
+	require.NoError(t, err)
+	assert.Nil(t, part.InlineData)
+	assert.Equal(t, "\n\nContent of the file main.go:\n\npackage main\n", part.Text)
+
+	_, err = LoadAttachment(write("archive.bin", []byte{0x1f, 0x8b, 0x08, 0x00}))
+	assert.ErrorContains(t, err, "only images and text files can be attached")
+
+	_, err = LoadAttachment(dir)
+	assert.ErrorContains(t, err, "is a directory")
+}
@@ -0,0 +1,241 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Record is a single input of a batch run.
+type Record struct {
+	// ID identifies the record in the results, the line number of the
+	// record in the input is used if it is empty.
+	ID     string `json:"id,omitempty"`
+	Prompt string `json:"prompt"`
+	// Variables are the mission variables for this record.
+	Variables map[string]any `json:"variables,omitempty"`
+}
+
+// Values returns the variables of the record as strings.
+func (r *Record) Values() map[string]string {
+	values := make(map[string]string, len(r.Variables))
+	for k, v := range r.Variables {
+		switch v := v.(type) {
+		case string:
+			values[k] = v
+		case float64:
+			values[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			values[k] = fmt.Sprint(v)
+		}
+	}
+	return values
+}
+
+// RecordResult is the outcome of a record, as written to the results.
+type RecordResult struct {
+	ID string `json:"id"`
+	*Result
+}
+
+// ReadRecords reads JSONL records, one per line. Empty lines are skipped.
+func ReadRecords(r io.Reader) ([]*Record, error) {
+	var records []*Record
+	seen := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		rec := &Record{}
+		if err := json.Unmarshal(data, rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if rec.ID == "" {
+			rec.ID = strconv.Itoa(line)
+		}
+		if prev, dup := seen[rec.ID]; dup {
+			return nil, fmt.Errorf("line %d: id '%s' is already used in line %d", line, rec.ID, prev)
+		}
+		seen[rec.ID] = line
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// Completed reads the results of a previous batch run and returns the IDs
+// of the records which succeeded. A missing file has no results. A
+// truncated last line, as left by an interrupted run, is ignored.
+func Completed(path string) (map[string]bool, error) {
+	done := make(map[string]bool)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var res struct {
+			ID    string `json:"id"`
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &res) != nil {
+			continue
+		}
+		// A later result of the record decides
+		done[res.ID] = res.Error == ""
+	}
+	for id, ok := range done {
+		if !ok {
+			delete(done, id)
+		}
+	}
+	return done, scanner.Err()
+}
+
+// Batch runs many records with bounded concurrency.
+type Batch struct {
+	// Concurrency is the number of records run at the same time, at least 1.
+	Concurrency int
+	// Run executes a single record.
+	Run func(ctx context.Context, rec *Record) (*Result, error)
+	// Output receives a JSON line with the RecordResult of every finished
+	// record. Records cut short by the cancellation of the context are not
+	// written, so that they are run again when the batch is resumed.
+	Output io.Writer
+	// Skip holds the IDs of records which are already done.
+	Skip map[string]bool
+	// Progress is called after every finished record, if set.
+	Progress func(res *RecordResult)
+}
+
+// Summary describes the outcome of a batch run.
+type Summary struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+	// Interrupted counts the records which didn't run to the end.
+	Interrupted int           `json:"interrupted"`
+	Duration    time.Duration `json:"duration"`
+	// Latency of the records which were run
+	MeanLatency time.Duration `json:"mean_latency"`
+	P50Latency  time.Duration `json:"p50_latency"`
+	P95Latency  time.Duration `json:"p95_latency"`
+	MaxLatency  time.Duration `json:"max_latency"`
+}
+
+// String returns the summary in human readable form.
+func (s *Summary) String() string {
+	return fmt.Sprintf("%d records: %d succeeded, %d failed, %d skipped, %d interrupted in %s\nlatency: mean %s, p50 %s, p95 %s, max %s",
+		s.Total, s.Succeeded, s.Failed, s.Skipped, s.Interrupted, s.Duration.Round(time.Millisecond),
+		s.MeanLatency.Round(time.Millisecond), s.P50Latency.Round(time.Millisecond),
+		s.P95Latency.Round(time.Millisecond), s.MaxLatency.Round(time.Millisecond))
+}
+
+// Execute runs all records which are not skipped. An error is only
+// returned if the results couldn't be written, failures of records are
+// part of their results and counted in the summary.
+func (b *Batch) Execute(ctx context.Context, records []*Record) (*Summary, error) {
+	start := time.Now()
+	sum := &Summary{Total: len(records)}
+	concurrency := max(b.Concurrency, 1)
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		writeErr  error
+	)
+	enc := json.NewEncoder(b.Output)
+	finish := func(rec *Record, res *Result, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil && ctx.Err() != nil {
+			sum.Interrupted++
+			return
+		}
+		if err != nil {
+			sum.Failed++
+		} else {
+			sum.Succeeded++
+		}
+		latencies = append(latencies, time.Duration(res.DurationMS)*time.Millisecond)
+		out := &RecordResult{ID: rec.ID, Result: res}
+		if e := enc.Encode(out); e != nil && writeErr == nil {
+			writeErr = e
+		}
+		if b.Progress != nil {
+			b.Progress(out)
+		}
+	}
+
+	queue := make(chan *Record)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rec := range queue {
+				res, err := b.Run(ctx, rec)
+				if res == nil {
+					res = &Result{}
+					if err != nil {
+						res.Error = err.Error()
+					}
+				}
+				finish(rec, res, err)
+			}
+		}()
+	}
+
+	skipped, interrupted := 0, 0
+	for _, rec := range records {
+		if b.Skip[rec.ID] {
+			skipped++
+			continue
+		}
+		if ctx.Err() != nil {
+			interrupted++
+			continue
+		}
+		select {
+		case queue <- rec:
+		case <-ctx.Done():
+			interrupted++
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	sum.Skipped += skipped
+	sum.Interrupted += interrupted
+	sum.Duration = time.Since(start)
+	if n := len(latencies); n > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		var total time.Duration
+		for _, l := range latencies {
+			total += l
+		}
+		sum.MeanLatency = total / time.Duration(n)
+		sum.P50Latency = latencies[(n-1)*50/100]
+		sum.P95Latency = latencies[(n-1)*95/100]
+		sum.MaxLatency = latencies[n-1]
+	}
+	return sum, writeErr
+}
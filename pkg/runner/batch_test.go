@@ -0,0 +1,124 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadRecords(t *testing.T) {
+	input := `{"id": "a", "prompt": "first", "variables": {"target": "10.0.0.1", "port": 22}}
+
+{"prompt": "second"}
+`
+	records, err := ReadRecords(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "a", records[0].ID)
+	assert.Equal(t, map[string]string{"target": "10.0.0.1", "port": "22"}, records[0].Values())
+	assert.Equal(t, "3", records[1].ID)
+	assert.Equal(t, "second", records[1].Prompt)
+
+	_, err = ReadRecords(strings.NewReader("{\"id\": \"x\"}\n{\"id\": \"x\"}\n"))
+	assert.ErrorContains(t, err, "line 2: id 'x' is already used in line 1")
+
+	_, err = ReadRecords(strings.NewReader("{\"id\": \"x\"}\nnot json\n"))
+	assert.ErrorContains(t, err, "line 2:")
+}
+
+func TestCompleted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	done, err := Completed(path)
+	require.NoError(t, err)
+	assert.Empty(t, done)
+
+	results := `{"id": "a", "text": "ok"}
+{"id": "b", "error": "failed"}
+{"id": "c", "error": "failed"}
+{"id": "c", "text": "ok"}
+{"id": "d", "te`
+	require.NoError(t, os.WriteFile(path, []byte(results), 0644))
+	done, err = Completed(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"a": true, "c": true}, done)
+}
+
+func TestBatchExecute(t *testing.T) {
+	var records []*Record
+	for _, id := range []string{"1", "2", "3", "4", "5", "6"} {
+		records = append(records, &Record{ID: id, Prompt: "prompt " + id})
+	}
+
+	var running, maxRunning atomic.Int32
+	var out bytes.Buffer
+	batch := &Batch{
+		Concurrency: 2,
+		Output:      &out,
+		Skip:        map[string]bool{"1": true},
+		Run: func(ctx context.Context, rec *Record) (*Result, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			if rec.ID == "4" {
+				return nil, errors.New("broken")
+			}
+			return &Result{Text: "answer to " + rec.Prompt, DurationMS: 10}, nil
+		},
+	}
+	sum, err := batch.Execute(context.Background(), records)
+	require.NoError(t, err)
+
+	assert.Equal(t, 6, sum.Total)
+	assert.Equal(t, 4, sum.Succeeded)
+	assert.Equal(t, 1, sum.Failed)
+	assert.Equal(t, 1, sum.Skipped)
+	assert.Equal(t, 0, sum.Interrupted)
+	assert.LessOrEqual(t, maxRunning.Load(), int32(2))
+
+	results := make(map[string]RecordResult)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var res RecordResult
+		require.NoError(t, json.Unmarshal([]byte(line), &res))
+		results[res.ID] = res
+	}
+	assert.Len(t, results, 5)
+	assert.Equal(t, "answer to prompt 2", results["2"].Text)
+	assert.Equal(t, "broken", results["4"].Error)
+}
+
+func TestBatchInterrupted(t *testing.T) {
+	records := []*Record{{ID: "1"}, {ID: "2"}, {ID: "3"}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var out bytes.Buffer
+	batch := &Batch{
+		Concurrency: 1,
+		Output:      &out,
+		Run: func(ctx context.Context, rec *Record) (*Result, error) {
+			if rec.ID == "2" {
+				cancel()
+				return nil, ctx.Err()
+			}
+			return &Result{Text: "ok"}, nil
+		},
+	}
+	sum, err := batch.Execute(ctx, records)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sum.Succeeded)
+	assert.Equal(t, 2, sum.Interrupted)
+	assert.Equal(t, 1, strings.Count(out.String(), "\n"))
+}
@@ -0,0 +1,172 @@
+// Package runner executes single, non-interactive turns of an ADK agent
+// and collects their outcome.
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	adkagent "google.golang.org/adk/agent"
+	adkrunner "google.golang.org/adk/runner"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+const (
+	// AppName is the ADK application name used for all sessions.
+	AppName = "allmend"
+	// UserID is the ADK user owning the sessions.
+	UserID = "allmend_user"
+)
+
+// Config describes a single turn.
+type Config struct {
+	Agent adkagent.Agent
+	// SessionService stores the conversation, an in-memory service is
+	// used if it is nil.
+	SessionService session.Service
+	// SessionID continues an existing session, a new one is created if empty.
+	SessionID string
+	// Message is the user input of the turn.
+	Message *genai.Content
+	// Stream receives the text of the answer while it is generated, if set.
+	Stream io.Writer
+	// ModelKey is the key of the custom metadata of the responses naming
+	// the model which served them, like provider.ServedByKey.
+	ModelKey string
+}
+
+// ToolCall is a function call of the model together with its response.
+type ToolCall struct {
+	ID       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Args     map[string]any `json:"args,omitempty"`
+	Response map[string]any `json:"response,omitempty"`
+}
+
+// Usage sums up the token counts of all model calls of a turn.
+type Usage struct {
+	PromptTokens     int32 `json:"prompt_tokens"`
+	CandidatesTokens int32 `json:"candidates_tokens"`
+	ThoughtsTokens   int32 `json:"thoughts_tokens,omitempty"`
+	TotalTokens      int32 `json:"total_tokens"`
+}
+
+// Result is the outcome of a turn.
+type Result struct {
+	SessionID string      `json:"session_id"`
+	Text      string      `json:"text"`
+	ToolCalls []*ToolCall `json:"tool_calls,omitempty"`
+	Usage     Usage       `json:"usage"`
+	// Model is the model which served the last model call of the turn,
+	// as named in the responses with Config.ModelKey.
+	Model string `json:"model,omitempty"`
+	// Duration of the turn in milliseconds
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Run executes one turn of the agent: the message is sent and the agent
+// runs, including tool calls, until it produced its final answer. The
+// returned Result is filled as far as the turn got, also on error.
+func Run(ctx context.Context, cfg Config) (*Result, error) {
+	start := time.Now()
+	res := &Result{SessionID: cfg.SessionID}
+	err := run(ctx, cfg, res)
+	res.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res, err
+}
+
+func run(ctx context.Context, cfg Config, res *Result) error {
+	sessions := cfg.SessionService
+	if sessions == nil {
+		sessions = session.InMemoryService()
+	}
+	if res.SessionID == "" {
+		created, err := sessions.Create(ctx, &session.CreateRequest{AppName: AppName, UserID: UserID})
+		if err != nil {
+			return fmt.Errorf("creating session: %w", err)
+		}
+		res.SessionID = created.Session.ID()
+	}
+
+	r, err := adkrunner.New(adkrunner.Config{
+		AppName:        AppName,
+		Agent:          cfg.Agent,
+		SessionService: sessions,
+	})
+	if err != nil {
+		return fmt.Errorf("creating runner: %w", err)
+	}
+
+	calls := make(map[string]*ToolCall)
+	streamed := ""
+	for event, err := range r.Run(ctx, UserID, res.SessionID, cfg.Message, adkagent.RunConfig{
+		StreamingMode: adkagent.StreamingModeSSE,
+	}) {
+		if err != nil {
+			return err
+		}
+		if event.ErrorCode != "" || event.ErrorMessage != "" {
+			return fmt.Errorf("model error %s: %s", event.ErrorCode, event.ErrorMessage)
+		}
+		if served, ok := event.CustomMetadata[cfg.ModelKey].(string); ok && !event.Partial {
+			res.Model = served
+		}
+		if u := event.UsageMetadata; u != nil && !event.Partial {
+			res.Usage.PromptTokens += u.PromptTokenCount
+			res.Usage.CandidatesTokens += u.CandidatesTokenCount
+			res.Usage.ThoughtsTokens += u.ThoughtsTokenCount
+			res.Usage.TotalTokens += u.TotalTokenCount
+		}
+		if event.Content == nil {
+			continue
+		}
+
+		text := ""
+		for _, p := range event.Content.Parts {
+			switch {
+			case p.FunctionCall != nil:
+				call := &ToolCall{ID: p.FunctionCall.ID, Name: p.FunctionCall.Name, Args: p.FunctionCall.Args}
+				res.ToolCalls = append(res.ToolCalls, call)
+				calls[call.ID] = call
+			case p.FunctionResponse != nil:
+				if call, ok := calls[p.FunctionResponse.ID]; ok {
+					call.Response = p.FunctionResponse.Response
+				}
+			case p.Text != "" && !p.Thought:
+				text += p.Text
+			}
+		}
+
+		// Partial events stream the text, the following complete event
+		// repeats it and only has to be written if nothing was streamed.
+		if event.Partial {
+			streamed += text
+			write(cfg.Stream, text)
+			continue
+		}
+		if text == "" {
+			continue
+		}
+		if text != streamed {
+			write(cfg.Stream, text)
+		}
+		streamed = ""
+		if event.IsFinalResponse() {
+			res.Text += text
+		}
+	}
+	return nil
+}
+
+func write(w io.Writer, text string) {
+	if w != nil && text != "" {
+		io.WriteString(w, text)
+	}
+}
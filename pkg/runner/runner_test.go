@@ -0,0 +1,108 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"iter"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/adk/agent/llmagent"
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/tool"
+	"google.golang.org/adk/tool/functiontool"
+	"google.golang.org/genai"
+)
+
+// fakeLLM answers every call with the next list of responses.
+type fakeLLM struct {
+	turns [][]*model.LLMResponse
+	calls int
+}
+
+func (f *fakeLLM) Name() string { return "fake" }
+
+func (f *fakeLLM) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		turn := f.turns[f.calls]
+		f.calls++
+		for _, r := range turn {
+			if !yield(r, nil) {
+				return
+			}
+		}
+	}
+}
+
+func usage(prompt, candidates int32) *genai.GenerateContentResponseUsageMetadata {
+	return &genai.GenerateContentResponseUsageMetadata{
+		PromptTokenCount:     prompt,
+		CandidatesTokenCount: candidates,
+		TotalTokenCount:      prompt + candidates,
+	}
+}
+
+func TestRun(t *testing.T) {
+	echo, err := functiontool.New(functiontool.Config{
+		Name:        "echo",
+		Description: "Echoes the text",
+	}, func(ctx tool.Context, args map[string]any) (map[string]any, error) {
+		return map[string]any{"output": args["text"]}, nil
+	})
+	require.NoError(t, err)
+
+	llm := &fakeLLM{turns: [][]*model.LLMResponse{
+		{{
+			Content: &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{
+				{FunctionCall: &genai.FunctionCall{ID: "call-1", Name: "echo", Args: map[string]any{"text": "hi"}}},
+			}},
+			UsageMetadata:  usage(10, 2),
+			CustomMetadata: map[string]any{"served_by": "local/granite4:3b"},
+		}},
+		{
+			{Content: genai.NewContentFromText("Hel", genai.RoleModel), Partial: true},
+			{Content: genai.NewContentFromText("lo", genai.RoleModel), Partial: true},
+			{Content: genai.NewContentFromText("Hello", genai.RoleModel), UsageMetadata: usage(20, 3), TurnComplete: true,
+				CustomMetadata: map[string]any{"served_by": "gpu-box/qwen3"}},
+		},
+	}}
+	a, err := llmagent.New(llmagent.Config{Name: "test", Model: llm, Tools: []tool.Tool{echo}})
+	require.NoError(t, err)
+
+	var stream bytes.Buffer
+	res, err := Run(context.Background(), Config{
+		Agent:    a,
+		Message:  genai.NewContentFromText("Say hello", genai.RoleUser),
+		Stream:   &stream,
+		ModelKey: "served_by",
+	})
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, res.SessionID)
+	assert.Equal(t, "Hello", res.Text)
+	assert.Equal(t, "Hello", stream.String())
+	require.Len(t, res.ToolCalls, 1)
+	assert.Equal(t, "echo", res.ToolCalls[0].Name)
+	assert.Equal(t, map[string]any{"text": "hi"}, res.ToolCalls[0].Args)
+	assert.Equal(t, map[string]any{"output": "hi"}, res.ToolCalls[0].Response)
+	assert.Equal(t, Usage{PromptTokens: 30, CandidatesTokens: 5, TotalTokens: 35}, res.Usage)
+	// The model of the last call, after falling back
+	assert.Equal(t, "gpu-box/qwen3", res.Model)
+	assert.Empty(t, res.Error)
+}
+
+func TestRunModelError(t *testing.T) {
+	llm := &fakeLLM{turns: [][]*model.LLMResponse{
+		{{ErrorCode: "429", ErrorMessage: "rate limited"}},
+	}}
+	a, err := llmagent.New(llmagent.Config{Name: "test", Model: llm})
+	require.NoError(t, err)
+
+	res, err := Run(context.Background(), Config{
+		Agent:   a,
+		Message: genai.NewContentFromText("Hi", genai.RoleUser),
+	})
+	assert.ErrorContains(t, err, "rate limited")
+	assert.Equal(t, err.Error(), res.Error)
+}
@@ -0,0 +1,211 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	adksession "google.golang.org/adk/session"
+)
+
+// FileService is an ADK session service which keeps the sessions of an
+// agent in memory and writes every session to a JSONL file in its
+// directory. Sessions are read from their file when they are first used.
+type FileService struct {
+	dir    string
+	agent  string
+	inner  adksession.Service
+	mu     sync.Mutex
+	loaded map[string]bool
+}
+
+var _ adksession.Service = (*FileService)(nil)
+
+func newFileService(dir, agent string) *FileService {
+	return &FileService{
+		dir:    dir,
+		agent:  agent,
+		inner:  adksession.InMemoryService(),
+		loaded: make(map[string]bool),
+	}
+}
+
+func (s *FileService) path(id string) string {
+	return filepath.Join(s.dir, id+Ext)
+}
+
+// Exists reports whether the session with id is stored.
+func (s *FileService) Exists(id string) bool {
+	_, err := os.Stat(s.path(id))
+	return err == nil
+}
+
+// Create implements adksession.Service.
+func (s *FileService) Create(ctx context.Context, req *adksession.CreateRequest) (*adksession.CreateResponse, error) {
+	if req.SessionID != "" {
+		if err := checkID(req.SessionID); err != nil {
+			return nil, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.SessionID != "" && s.Exists(req.SessionID) {
+		return nil, fmt.Errorf("session %s already exists", req.SessionID)
+	}
+	resp, err := s.inner.Create(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	id := resp.Session.ID()
+	s.loaded[id] = true
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	info := &Info{
+		ID:      id,
+		Agent:   s.agent,
+		AppName: req.AppName,
+		UserID:  req.UserID,
+		Created: time.Now(),
+	}
+	if err := s.write(id, os.O_CREATE|os.O_EXCL|os.O_WRONLY, &entry{Session: info}); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Get implements adksession.Service.
+func (s *FileService) Get(ctx context.Context, req *adksession.GetRequest) (*adksession.GetResponse, error) {
+	s.mu.Lock()
+	err := s.load(ctx, req.SessionID)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return s.inner.Get(ctx, req)
+}
+
+// List implements adksession.Service.
+func (s *FileService) List(ctx context.Context, req *adksession.ListRequest) (*adksession.ListResponse, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*"+Ext))
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	for _, f := range files {
+		if err := s.load(ctx, strings.TrimSuffix(filepath.Base(f), Ext)); err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+	}
+	s.mu.Unlock()
+	return s.inner.List(ctx, req)
+}
+
+// Delete implements adksession.Service.
+func (s *FileService) Delete(ctx context.Context, req *adksession.DeleteRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loaded[req.SessionID] {
+		if err := s.inner.Delete(ctx, req); err != nil {
+			return err
+		}
+		delete(s.loaded, req.SessionID)
+	}
+	if err := os.Remove(s.path(req.SessionID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// AppendEvent implements adksession.Service. Partial events are not stored.
+func (s *FileService) AppendEvent(ctx context.Context, sess adksession.Session, event *adksession.Event) error {
+	if err := s.inner.AppendEvent(ctx, sess, event); err != nil {
+		return err
+	}
+	if event.Partial {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(sess.ID(), os.O_APPEND|os.O_WRONLY, &entry{Event: event})
+}
+
+// RecordRun stores the setup of the agent starting to work in the session id.
+func (s *FileService) RecordRun(id string, run *Run) error {
+	if run.Started.IsZero() {
+		run.Started = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(id, os.O_APPEND|os.O_WRONLY, &entry{Run: run})
+}
+
+// write writes e as a line to the file of the session id.
+func (s *FileService) write(id string, flag int, e *entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode session %s: %w", id, err)
+	}
+	f, err := os.OpenFile(s.path(id), flag, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write session %s: %w", id, err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write session %s: %w", id, err)
+	}
+	return nil
+}
+
+// load reads the session id into the in-memory service, if it isn't
+// already. s.mu has to be held.
+func (s *FileService) load(ctx context.Context, id string) error {
+	if s.loaded[id] {
+		return nil
+	}
+	stored, err := readFile(s.path(id))
+	if err != nil {
+		return err
+	}
+	// New entries are appended to the file, so a truncated last line of an
+	// interrupted process is cut off to not run into them
+	if err := trimTruncated(s.path(id)); err != nil {
+		return fmt.Errorf("failed to repair session %s: %w", id, err)
+	}
+	resp, err := s.inner.Create(ctx, &adksession.CreateRequest{
+		AppName:   stored.AppName,
+		UserID:    stored.UserID,
+		SessionID: stored.ID,
+	})
+	if err != nil {
+		return err
+	}
+	// Replaying the events restores the state of the session as well
+	for _, e := range stored.Events {
+		if err := s.inner.AppendEvent(ctx, resp.Session, e); err != nil {
+			return fmt.Errorf("failed to restore session %s: %w", id, err)
+		}
+	}
+	s.loaded[id] = true
+	return nil
+}
+
+// trimTruncated cuts off the last line of the file at path if it doesn't
+// end with a newline.
+func trimTruncated(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 || data[len(data)-1] == '\n' {
+		return err
+	}
+	return os.Truncate(path, int64(bytes.LastIndexByte(data, '\n')+1))
+}
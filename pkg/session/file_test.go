@@ -0,0 +1,156 @@
+package session
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/adk/model"
+	adksession "google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+func newEvent(author, text string) *adksession.Event {
+	e := adksession.NewEvent("inv-1")
+	e.Author = author
+	role := genai.RoleModel
+	if author == "user" {
+		role = genai.RoleUser
+	}
+	e.LLMResponse = model.LLMResponse{Content: genai.NewContentFromText(text, genai.Role(role))}
+	return e
+}
+
+func TestFileService(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(t.TempDir())
+
+	svc := store.Service("Test Agent")
+	created, err := svc.Create(ctx, &adksession.CreateRequest{AppName: "app", UserID: "user", SessionID: "s1"})
+	require.NoError(t, err)
+	sess := created.Session
+
+	userEvent := newEvent("user", "Hello agent")
+	userEvent.Actions.StateDelta["topic"] = "greeting"
+	require.NoError(t, svc.AppendEvent(ctx, sess, userEvent))
+	partial := newEvent("Test Agent", "Hel")
+	partial.Partial = true
+	require.NoError(t, svc.AppendEvent(ctx, sess, partial))
+	require.NoError(t, svc.AppendEvent(ctx, sess, newEvent("Test Agent", "Hello user")))
+
+	_, err = svc.Create(ctx, &adksession.CreateRequest{AppName: "app", UserID: "user", SessionID: "s1"})
+	assert.ErrorContains(t, err, "already exists")
+
+	// A new service reads the session from its file
+	resumed := store.Service("Test Agent")
+	assert.True(t, resumed.Exists("s1"))
+	got, err := resumed.Get(ctx, &adksession.GetRequest{AppName: "app", UserID: "user", SessionID: "s1"})
+	require.NoError(t, err)
+	require.Equal(t, 2, got.Session.Events().Len())
+	assert.Equal(t, "Hello agent", got.Session.Events().At(0).Content.Parts[0].Text)
+	assert.Equal(t, "Hello user", got.Session.Events().At(1).Content.Parts[0].Text)
+	topic, err := got.Session.State().Get("topic")
+	require.NoError(t, err)
+	assert.Equal(t, "greeting", topic)
+
+	require.NoError(t, resumed.AppendEvent(ctx, got.Session, newEvent("user", "Bye")))
+	list, err := resumed.List(ctx, &adksession.ListRequest{AppName: "app", UserID: "user"})
+	require.NoError(t, err)
+	assert.Len(t, list.Sessions, 1)
+
+	_, err = store.Service("Test Agent").Get(ctx, &adksession.GetRequest{AppName: "app", UserID: "user", SessionID: "missing"})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(t.TempDir())
+
+	for _, s := range []struct{ agent, id, text string }{
+		{"Alpha", "a1", "First question to alpha"},
+		{"Alpha", "a2", "Second question to alpha"},
+		{"Beta", "b1", "Question to beta"},
+	} {
+		svc := store.Service(s.agent)
+		created, err := svc.Create(ctx, &adksession.CreateRequest{AppName: "app", UserID: "user", SessionID: s.id})
+		require.NoError(t, err)
+		require.NoError(t, svc.AppendEvent(ctx, created.Session, newEvent("user", s.text)))
+		time.Sleep(time.Millisecond)
+	}
+
+	all, err := store.List("")
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, "b1", all[0].ID)
+
+	alpha, err := store.List("Alpha")
+	require.NoError(t, err)
+	require.Len(t, alpha, 2)
+	assert.Equal(t, "a2", alpha[0].ID)
+	assert.Equal(t, "Alpha", alpha[0].Agent)
+	assert.Equal(t, 1, alpha[0].EventCount)
+	assert.Equal(t, "Second question to alpha", alpha[0].Preview)
+
+	sess, err := store.Load("a1")
+	require.NoError(t, err)
+	assert.Equal(t, "Alpha", sess.Agent)
+	require.Len(t, sess.Events, 1)
+
+	require.NoError(t, store.Delete("a1"))
+	_, err = store.Load("a1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.Delete("a1"), ErrNotFound)
+
+	_, err = store.Load("../a2")
+	assert.ErrorContains(t, err, "invalid session id")
+	for _, id := range []string{"*", "?2*", "a[12]", ""} {
+		_, err = store.Find(id)
+		assert.ErrorContains(t, err, "invalid session id", id)
+	}
+	assert.ErrorContains(t, store.Delete("*"), "invalid session id")
+	_, err = store.Load("a2")
+	assert.NoError(t, err)
+
+	_, err = store.Service("Beta").Create(ctx, &adksession.CreateRequest{AppName: "app", UserID: "user", SessionID: "b*"})
+	assert.ErrorContains(t, err, "invalid session id 'b*'")
+}
+
+func TestReadFileTruncated(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(t.TempDir())
+	svc := store.Service("Agent")
+	created, err := svc.Create(ctx, &adksession.CreateRequest{AppName: "app", UserID: "user", SessionID: "s"})
+	require.NoError(t, err)
+	require.NoError(t, svc.AppendEvent(ctx, created.Session, newEvent("user", "Hi")))
+
+	f, err := os.OpenFile(filepath.Join(store.Dir, "Agent", "s"+Ext), os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	f.WriteString(`{"event": {"ID": "x", "Auth`)
+	f.Close()
+
+	sess, err := store.Load("s")
+	require.NoError(t, err)
+	assert.Len(t, sess.Events, 1)
+
+	// Resuming the session cuts off the truncated line before appending
+	svc = NewStore(store.Dir).Service("Agent")
+	resumed, err := svc.Get(ctx, &adksession.GetRequest{AppName: "app", UserID: "user", SessionID: "s"})
+	require.NoError(t, err)
+	require.NoError(t, svc.AppendEvent(ctx, resumed.Session, newEvent("user", "Again")))
+	sess, err = store.Load("s")
+	require.NoError(t, err)
+	assert.Len(t, sess.Events, 2)
+
+	// Lines which can't be decoded are only tolerated at the end
+	f, err = os.OpenFile(filepath.Join(store.Dir, "Agent", "s"+Ext), os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	f.WriteString("{\"event\": \n")
+	f.Close()
+	require.NoError(t, svc.AppendEvent(ctx, resumed.Session, newEvent("user", "Still there?")))
+	_, err = store.Load("s")
+	assert.ErrorContains(t, err, "is corrupt at line 4: ")
+}
@@ -0,0 +1,237 @@
+// Package session stores the conversations of agents in files, so that
+// they can be resumed and inspected later.
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	adksession "google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+// Ext is the file extension of session files.
+const Ext = ".jsonl"
+
+// ErrNotFound is returned for unknown sessions.
+var ErrNotFound = errors.New("session not found")
+
+// validID matches the session ids, which are used as file names.
+var validID = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// checkID returns an error if id can't be the id of a session.
+func checkID(id string) error {
+	if !validID.MatchString(id) {
+		return fmt.Errorf("invalid session id '%s', use letters, digits, '.', '_' and '-'", id)
+	}
+	return nil
+}
+
+// Info describes a stored session.
+type Info struct {
+	ID      string    `json:"id"`
+	Agent   string    `json:"agent"`
+	AppName string    `json:"app_name"`
+	UserID  string    `json:"user_id"`
+	Created time.Time `json:"created"`
+	// Updated is the time of the last event.
+	Updated time.Time `json:"updated,omitzero"`
+	// EventCount is the number of events in the session.
+	EventCount int `json:"event_count,omitempty"`
+	// Preview is the start of the first user message.
+	Preview string `json:"preview,omitempty"`
+}
+
+// Run describes how the agent was set up when it started to work in a
+// session. A session resumed several times has several runs. Model is the
+// ID of the first model of the chain, the events name the model which
+// served them in their custom metadata.
+type Run struct {
+	Started      time.Time `json:"started"`
+	Agent        string    `json:"agent"`
+	AgentVersion string    `json:"agent_version,omitempty"`
+	Model        string    `json:"model"`
+	Provider     string    `json:"provider"`
+	// Instruction is the system instruction with substituted variables.
+	Instruction string `json:"instruction"`
+}
+
+// entry is a line of a session file. The first line holds the session,
+// every following line a run or an event.
+type entry struct {
+	Session *Info             `json:"session,omitempty"`
+	Run     *Run              `json:"run,omitempty"`
+	Event   *adksession.Event `json:"event,omitempty"`
+}
+
+// Session is a stored session with its runs and events.
+type Session struct {
+	Info
+	Runs   []*Run              `json:"runs,omitempty"`
+	Events []*adksession.Event `json:"events"`
+}
+
+// Store holds the sessions of all agents, in a directory per agent.
+type Store struct {
+	Dir string
+}
+
+// NewStore returns the store in dir.
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// Service returns the ADK session service storing the sessions of agent.
+func (s *Store) Service(agent string) *FileService {
+	return newFileService(filepath.Join(s.Dir, agentDir(agent)), agent)
+}
+
+// List returns the sessions of agent, or of all agents if agent is empty,
+// with the latest updated first.
+func (s *Store) List(agent string) ([]*Info, error) {
+	pattern := filepath.Join(s.Dir, "*", "*"+Ext)
+	if agent != "" {
+		pattern = filepath.Join(s.Dir, agentDir(agent), "*"+Ext)
+	}
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var infos []*Info
+	for _, f := range files {
+		sess, err := readFile(f)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, &sess.Info)
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].Updated.After(infos[j].Updated)
+	})
+	return infos, nil
+}
+
+// Find returns the path of the session with the given id.
+func (s *Store) Find(id string) (string, error) {
+	if err := checkID(id); err != nil {
+		return "", err
+	}
+	dirs, err := os.ReadDir(s.Dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		path := filepath.Join(s.Dir, d.Name(), id+Ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// Load reads the session with the given id.
+func (s *Store) Load(id string) (*Session, error) {
+	path, err := s.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	return readFile(path)
+}
+
+// Delete removes the session with the given id.
+func (s *Store) Delete(id string) error {
+	path, err := s.Find(id)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// agentDir maps an agent name to the name of its session directory.
+func agentDir(agent string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, agent)
+}
+
+// readFile reads a session file. A truncated last line, as written by an
+// interrupted process, is ignored, other lines which can't be decoded are
+// an error.
+func readFile(path string) (*Session, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, strings.TrimSuffix(filepath.Base(path), Ext))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sess := &Session{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	var corrupt error
+	for line := 1; scanner.Scan(); line++ {
+		if corrupt != nil {
+			return nil, corrupt
+		}
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			corrupt = fmt.Errorf("session file %s is corrupt at line %d: %w", path, line, err)
+			continue
+		}
+		switch {
+		case e.Session != nil:
+			sess.Info = *e.Session
+		case e.Run != nil:
+			sess.Runs = append(sess.Runs, e.Run)
+		case e.Event != nil:
+			sess.Events = append(sess.Events, e.Event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read session %s: %w", path, err)
+	}
+	if sess.ID == "" {
+		return nil, fmt.Errorf("session file %s has no header", path)
+	}
+
+	sess.EventCount = len(sess.Events)
+	sess.Updated = sess.Created
+	for _, e := range sess.Events {
+		if e.Timestamp.After(sess.Updated) {
+			sess.Updated = e.Timestamp
+		}
+		if sess.Preview == "" && e.Author == "user" && e.Content != nil {
+			sess.Preview = preview(e.Content)
+		}
+	}
+	return sess, nil
+}
+
+// preview returns the start of the text of content on a single line.
+func preview(content *genai.Content) string {
+	var sb strings.Builder
+	for _, p := range content.Parts {
+		sb.WriteString(p.Text)
+	}
+	text := strings.Join(strings.Fields(sb.String()), " ")
+	if r := []rune(text); len(r) > 60 {
+		text = string(r[:57]) + "..."
+	}
+	return text
+}
@@ -0,0 +1,207 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Types of transcript entries
+const (
+	EntryMessage    = "message"
+	EntryToolCall   = "tool_call"
+	EntryToolResult = "tool_result"
+	EntryError      = "error"
+)
+
+// Transcript is the readable record of a session: how the agent was set up
+// and everything which was said and done.
+type Transcript struct {
+	SessionID string    `json:"session_id"`
+	Agent     string    `json:"agent"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+	// Runs holds the setup of every start of the agent in the session.
+	Runs    []*Run             `json:"runs"`
+	Entries []*TranscriptEntry `json:"entries"`
+}
+
+// TranscriptEntry is a message, tool call, tool result or error.
+type TranscriptEntry struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+	// Author is "user" or the name of the agent.
+	Author string         `json:"author"`
+	Text   string         `json:"text,omitempty"`
+	Tool   string         `json:"tool,omitempty"`
+	CallID string         `json:"call_id,omitempty"`
+	Args   map[string]any `json:"args,omitempty"`
+	Result map[string]any `json:"result,omitempty"`
+}
+
+// NewTranscript creates the transcript of a stored session. Partial and
+// thought texts are left out.
+func NewTranscript(sess *Session) *Transcript {
+	t := &Transcript{
+		SessionID: sess.ID,
+		Agent:     sess.Agent,
+		Created:   sess.Created,
+		Updated:   sess.Updated,
+		Runs:      sess.Runs,
+		Entries:   []*TranscriptEntry{},
+	}
+	if t.Runs == nil {
+		t.Runs = []*Run{}
+	}
+	for _, e := range sess.Events {
+		if e.ErrorCode != "" || e.ErrorMessage != "" {
+			t.Entries = append(t.Entries, &TranscriptEntry{
+				Time:   e.Timestamp,
+				Type:   EntryError,
+				Author: e.Author,
+				Text:   strings.TrimSpace(e.ErrorCode + " " + e.ErrorMessage),
+			})
+		}
+		if e.Content == nil || e.Partial {
+			continue
+		}
+		text := ""
+		for _, p := range e.Content.Parts {
+			switch {
+			case p.FunctionCall != nil:
+				t.Entries = append(t.Entries, &TranscriptEntry{
+					Time:   e.Timestamp,
+					Type:   EntryToolCall,
+					Author: e.Author,
+					Tool:   p.FunctionCall.Name,
+					CallID: p.FunctionCall.ID,
+					Args:   p.FunctionCall.Args,
+				})
+			case p.FunctionResponse != nil:
+				t.Entries = append(t.Entries, &TranscriptEntry{
+					Time:   e.Timestamp,
+					Type:   EntryToolResult,
+					Author: e.Author,
+					Tool:   p.FunctionResponse.Name,
+					CallID: p.FunctionResponse.ID,
+					Result: p.FunctionResponse.Response,
+				})
+			case p.InlineData != nil:
+				text += fmt.Sprintf("\n[attached %s, %d bytes]", p.InlineData.MIMEType, len(p.InlineData.Data))
+			case p.Text != "" && !p.Thought:
+				text += p.Text
+			}
+		}
+		if text != "" {
+			t.Entries = append(t.Entries, &TranscriptEntry{
+				Time:   e.Timestamp,
+				Type:   EntryMessage,
+				Author: e.Author,
+				Text:   text,
+			})
+		}
+	}
+	return t
+}
+
+// WriteJSON writes the transcript as indented JSON.
+func (t *Transcript) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
+
+// WriteMarkdown writes the transcript as Markdown document. Every run
+// starts a section with the setup of the agent, followed by the entries
+// of the run.
+func (t *Transcript) WriteMarkdown(w io.Writer) error {
+	mw := &markdownWriter{w: w}
+	mw.printf("# Transcript of session %s\n\n", t.SessionID)
+	mw.printf("- **Agent:** %s\n", t.Agent)
+	mw.printf("- **Created:** %s\n", formatTime(t.Created))
+	mw.printf("- **Updated:** %s\n", formatTime(t.Updated))
+
+	runs := append([]*Run(nil), t.Runs...)
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Started.Before(runs[j].Started) })
+	next := 0
+	for _, e := range t.Entries {
+		for next < len(runs) && !runs[next].Started.After(e.Time) {
+			mw.run(next+1, runs[next])
+			next++
+		}
+		mw.entry(e)
+	}
+	for ; next < len(runs); next++ {
+		mw.run(next+1, runs[next])
+	}
+	return mw.err
+}
+
+type markdownWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (mw *markdownWriter) printf(format string, args ...any) {
+	if mw.err == nil {
+		_, mw.err = fmt.Fprintf(mw.w, format, args...)
+	}
+}
+
+func (mw *markdownWriter) run(n int, r *Run) {
+	mw.printf("\n## Run %d\n\n", n)
+	mw.printf("- **Started:** %s\n", formatTime(r.Started))
+	version := ""
+	if r.AgentVersion != "" {
+		version = " (version " + r.AgentVersion + ")"
+	}
+	mw.printf("- **Agent:** %s%s\n", r.Agent, version)
+	mw.printf("- **Model:** %s\n", r.Model)
+	mw.printf("- **Provider:** %s\n", r.Provider)
+	mw.printf("\n### System instruction\n\n")
+	mw.codeBlock("", r.Instruction)
+	mw.printf("\n### Conversation\n")
+}
+
+func (mw *markdownWriter) entry(e *TranscriptEntry) {
+	switch e.Type {
+	case EntryMessage:
+		mw.printf("\n**%s** (%s):\n\n%s\n", e.Author, formatTime(e.Time), strings.TrimRight(e.Text, "\n"))
+	case EntryToolCall:
+		mw.printf("\n**%s** calls tool `%s` (%s):\n\n", e.Author, e.Tool, formatTime(e.Time))
+		mw.codeBlock("json", indentJSON(e.Args))
+	case EntryToolResult:
+		mw.printf("\nResult of tool `%s` (%s):\n\n", e.Tool, formatTime(e.Time))
+		mw.codeBlock("json", indentJSON(e.Result))
+	case EntryError:
+		mw.printf("\n**Error** of %s (%s): %s\n", e.Author, formatTime(e.Time), e.Text)
+	}
+}
+
+// codeBlock writes text as fenced code block, with a fence longer than
+// any backtick run in the text.
+func (mw *markdownWriter) codeBlock(lang, text string) {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	mw.printf("%s%s\n%s\n%s\n", fence, lang, strings.TrimRight(text, "\n"), fence)
+}
+
+func indentJSON(v any) string {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,108 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/adk/model"
+	adksession "google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+func testSession() *Session {
+	start := time.Date(2026, time.March, 1, 10, 0, 0, 0, time.UTC)
+	at := func(sec int) time.Time { return start.Add(time.Duration(sec) * time.Second) }
+	event := func(sec int, author string, parts ...*genai.Part) *adksession.Event {
+		return &adksession.Event{
+			Timestamp:   at(sec),
+			Author:      author,
+			LLMResponse: model.LLMResponse{Content: &genai.Content{Parts: parts}},
+		}
+	}
+	return &Session{
+		Info: Info{ID: "s1", Agent: "Scanner", Created: start, Updated: at(4)},
+		Runs: []*Run{{Started: at(0), Agent: "Scanner", AgentVersion: "0.2", Model: "gemma", Provider: "ollama", Instruction: "Scan ```carefully```."}},
+		Events: []*adksession.Event{
+			event(1, "user", genai.NewPartFromText("Scan 10.0.0.1")),
+			event(2, "Scanner", &genai.Part{Text: "thinking", Thought: true},
+				&genai.Part{FunctionCall: &genai.FunctionCall{ID: "c1", Name: "nmap", Args: map[string]any{"host": "10.0.0.1"}}}),
+			event(3, "Scanner", &genai.Part{FunctionResponse: &genai.FunctionResponse{ID: "c1", Name: "nmap", Response: map[string]any{"output": "22/tcp open"}}}),
+			event(4, "Scanner", genai.NewPartFromText("Port 22 is open.")),
+			{Timestamp: at(5), Author: "Scanner", LLMResponse: model.LLMResponse{ErrorCode: "500", ErrorMessage: "overloaded"}},
+		},
+	}
+}
+
+func TestTranscript(t *testing.T) {
+	tr := NewTranscript(testSession())
+	require.Len(t, tr.Entries, 5)
+	assert.Equal(t, &TranscriptEntry{Time: tr.Entries[0].Time, Type: EntryMessage, Author: "user", Text: "Scan 10.0.0.1"}, tr.Entries[0])
+	assert.Equal(t, EntryToolCall, tr.Entries[1].Type)
+	assert.Equal(t, map[string]any{"host": "10.0.0.1"}, tr.Entries[1].Args)
+	assert.Equal(t, EntryToolResult, tr.Entries[2].Type)
+	assert.Equal(t, "c1", tr.Entries[2].CallID)
+	assert.Equal(t, "Port 22 is open.", tr.Entries[3].Text)
+	assert.Equal(t, &TranscriptEntry{Time: tr.Entries[4].Time, Type: EntryError, Author: "Scanner", Text: "500 overloaded"}, tr.Entries[4])
+
+	var buf bytes.Buffer
+	require.NoError(t, tr.WriteJSON(&buf))
+	var decoded Transcript
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, "gemma", decoded.Runs[0].Model)
+	assert.Len(t, decoded.Entries, 5)
+}
+
+func TestTranscriptMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, NewTranscript(testSession()).WriteMarkdown(&buf))
+	assert.Equal(t, "# Transcript of session s1\n"+
+		"\n"+
+		"- **Agent:** Scanner\n"+
+		"- **Created:** 2026-03-01T10:00:00Z\n"+
+		"- **Updated:** 2026-03-01T10:00:04Z\n"+
+		"\n"+
+		"## Run 1\n"+
+		"\n"+
+		"- **Started:** 2026-03-01T10:00:00Z\n"+
+		"- **Agent:** Scanner (version 0.2)\n"+
+		"- **Model:** gemma\n"+
+		"- **Provider:** ollama\n"+
+		"\n"+
+		"### System instruction\n"+
+		"\n"+
+		"````\n"+
+		"Scan ```carefully```.\n"+
+		"````\n"+
+		"\n"+
+		"### Conversation\n"+
+		"\n"+
+		"**user** (2026-03-01T10:00:01Z):\n"+
+		"\n"+
+		"Scan 10.0.0.1\n"+
+		"\n"+
+		"**Scanner** calls tool `nmap` (2026-03-01T10:00:02Z):\n"+
+		"\n"+
+		"```json\n"+
+		"{\n"+
+		"  \"host\": \"10.0.0.1\"\n"+
+		"}\n"+
+		"```\n"+
+		"\n"+
+		"Result of tool `nmap` (2026-03-01T10:00:03Z):\n"+
+		"\n"+
+		"```json\n"+
+		"{\n"+
+		"  \"output\": \"22/tcp open\"\n"+
+		"}\n"+
+		"```\n"+
+		"\n"+
+		"**Scanner** (2026-03-01T10:00:04Z):\n"+
+		"\n"+
+		"Port 22 is open.\n"+
+		"\n"+
+		"**Error** of Scanner (2026-03-01T10:00:05Z): 500 overloaded\n", buf.String())
+}
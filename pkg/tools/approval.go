@@ -0,0 +1,230 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SUSE/allmend/pkg/agent"
+	"google.golang.org/adk/agent/llmagent"
+	"google.golang.org/adk/tool"
+	"gopkg.in/yaml.v3"
+)
+
+// Answer is the reply of the user when asked to approve a tool call.
+type Answer int
+
+const (
+	Deny Answer = iota
+	Approve
+	AlwaysApprove
+)
+
+// Policy decides tool calls without asking. Allow and Deny hold tool
+// names or shell patterns like "fs_*", Deny takes precedence.
+type Policy struct {
+	Allow []string `yaml:"allow,omitempty"`
+	Deny  []string `yaml:"deny,omitempty"`
+	// Default is used for tools matching neither list: "ask", "allow"
+	// or "deny". Empty means ask.
+	Default string `yaml:"default,omitempty"`
+}
+
+// LoadPolicy reads an approval policy from a YAML file.
+func LoadPolicy(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open policy file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	p := &Policy{}
+	if err := yaml.NewDecoder(f).Decode(p); err != nil {
+		return nil, fmt.Errorf("failed to decode policy from %s: %w", path, err)
+	}
+	switch p.Default {
+	case "", "ask", "allow", "deny":
+	default:
+		return nil, fmt.Errorf("invalid default %q in policy %s, expected ask, allow or deny", p.Default, path)
+	}
+	return p, nil
+}
+
+// decide returns "allow", "deny" or "ask" for the tool.
+func (p *Policy) decide(name string) string {
+	if p == nil {
+		return "ask"
+	}
+	if matchAny(p.Deny, name) {
+		return "deny"
+	}
+	if matchAny(p.Allow, name) {
+		return "allow"
+	}
+	if p.Default == "" {
+		return "ask"
+	}
+	return p.Default
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ApprovalRecord documents the decision about a single tool call.
+type ApprovalRecord struct {
+	Time     time.Time      `json:"time"`
+	Tool     string         `json:"tool"`
+	Server   string         `json:"server,omitempty"`
+	Args     map[string]any `json:"args,omitempty"`
+	Approved bool           `json:"approved"`
+	// Reason is one of "read-only", "always", "policy", "yes" or "user".
+	Reason string `json:"reason"`
+}
+
+// Approver gates tool calls of tools which are not marked read-only.
+type Approver struct {
+	// Specs maps tool names to the declaration of the providing MCP server.
+	Specs map[string]*agent.MCPTools
+	// Policy decides calls before the user is asked.
+	Policy *Policy
+	// Yes approves all calls the policy doesn't deny.
+	Yes bool
+	// Prompt asks the user, calls are denied if it is nil.
+	Prompt func(name, server string, args map[string]any) (Answer, error)
+	// Log receives every record as a JSON line, if set.
+	Log io.Writer
+
+	mu      sync.Mutex
+	always  map[string]bool
+	records []ApprovalRecord
+}
+
+// Records returns the decisions taken so far.
+func (a *Approver) Records() []ApprovalRecord {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]ApprovalRecord(nil), a.records...)
+}
+
+// Check decides whether the named tool may be called with args.
+func (a *Approver) Check(name string, args map[string]any) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rec := ApprovalRecord{Time: time.Now(), Tool: name, Args: args}
+	spec := a.Specs[name]
+	if spec != nil {
+		rec.Server = spec.Name
+	}
+
+	switch {
+	case spec != nil && spec.ReadOnly:
+		rec.Approved, rec.Reason = true, "read-only"
+	case a.always[name]:
+		rec.Approved, rec.Reason = true, "always"
+	case a.Policy.decide(name) != "ask":
+		rec.Approved, rec.Reason = a.Policy.decide(name) == "allow", "policy"
+	case a.Yes:
+		rec.Approved, rec.Reason = true, "yes"
+	default:
+		rec.Reason = "user"
+		if a.Prompt != nil {
+			answer, err := a.Prompt(name, rec.Server, args)
+			if err != nil {
+				return false, fmt.Errorf("asking for approval of tool '%s': %w", name, err)
+			}
+			rec.Approved = answer != Deny
+			if answer == AlwaysApprove {
+				if a.always == nil {
+					a.always = make(map[string]bool)
+				}
+				a.always[name] = true
+			}
+		}
+	}
+
+	a.records = append(a.records, rec)
+	slog.Debug("tool call decision", "tool", name, "approved", rec.Approved, "reason", rec.Reason)
+	if a.Log != nil {
+		if err := json.NewEncoder(a.Log).Encode(rec); err != nil {
+			return false, fmt.Errorf("writing approval record: %w", err)
+		}
+	}
+	return rec.Approved, nil
+}
+
+// BeforeToolCallback returns an llmagent callback which skips denied tool
+// calls and tells the model that the user denied them.
+func (a *Approver) BeforeToolCallback() llmagent.BeforeToolCallback {
+	return func(ctx tool.Context, t tool.Tool, args map[string]any) (map[string]any, error) {
+		ok, err := a.Check(t.Name(), args)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return map[string]any{"error": fmt.Sprintf("The call of tool '%s' was denied by the user.", t.Name())}, nil
+		}
+		return nil, nil
+	}
+}
+
+// ConsolePrompt returns a prompt which asks on out and reads the answer
+// from in. It reads byte by byte so no input of a following prompt is
+// consumed.
+func ConsolePrompt(in io.Reader, out io.Writer) func(string, string, map[string]any) (Answer, error) {
+	return func(name, server string, args map[string]any) (Answer, error) {
+		argBytes, _ := json.Marshal(args)
+		if server != "" {
+			fmt.Fprintf(out, "\nTool '%s' of '%s' is not read-only and wants to run with:\n  %s\n", name, server, argBytes)
+		} else {
+			fmt.Fprintf(out, "\nTool '%s' wants to run with:\n  %s\n", name, argBytes)
+		}
+		for {
+			fmt.Fprint(out, "Approve? [y]es, [n]o, [a]lways: ")
+			line, err := readLine(in)
+			if err != nil {
+				return Deny, err
+			}
+			switch strings.ToLower(strings.TrimSpace(line)) {
+			case "y", "yes":
+				return Approve, nil
+			case "n", "no", "":
+				return Deny, nil
+			case "a", "always":
+				return AlwaysApprove, nil
+			}
+		}
+	}
+}
+
+func readLine(r io.Reader) (string, error) {
+	var sb strings.Builder
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				return sb.String(), nil
+			}
+			sb.WriteByte(b[0])
+		}
+		if err != nil {
+			if err == io.EOF && sb.Len() > 0 {
+				return sb.String(), nil
+			}
+			return "", err
+		}
+	}
+}
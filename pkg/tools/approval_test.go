@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/SUSE/allmend/pkg/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApproverCheck(t *testing.T) {
+	specs := map[string]*agent.MCPTools{
+		"fs_read":  {Name: "fs", ReadOnly: true},
+		"fs_write": {Name: "fs"},
+		"sh_exec":  {Name: "sh"},
+	}
+
+	t.Run("ReadOnlyNeedsNoPrompt", func(t *testing.T) {
+		a := &Approver{Specs: specs}
+		ok, err := a.Check("fs_read", nil)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "read-only", a.Records()[0].Reason)
+	})
+
+	t.Run("DeniedWithoutPrompt", func(t *testing.T) {
+		a := &Approver{Specs: specs}
+		ok, err := a.Check("fs_write", nil)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("Prompt", func(t *testing.T) {
+		var asked []string
+		answers := []Answer{Deny, Approve, AlwaysApprove}
+		a := &Approver{Specs: specs, Prompt: func(name, server string, args map[string]any) (Answer, error) {
+			asked = append(asked, name)
+			ans := answers[0]
+			answers = answers[1:]
+			return ans, nil
+		}}
+		for _, want := range []bool{false, true, true, true} {
+			ok, err := a.Check("fs_write", map[string]any{"path": "/tmp/x"})
+			require.NoError(t, err)
+			assert.Equal(t, want, ok)
+		}
+		// the last call was covered by always
+		assert.Len(t, asked, 3)
+		records := a.Records()
+		require.Len(t, records, 4)
+		assert.Equal(t, "always", records[3].Reason)
+		assert.Equal(t, "fs", records[3].Server)
+		assert.Equal(t, "/tmp/x", records[0].Args["path"])
+	})
+
+	t.Run("PolicyAndYes", func(t *testing.T) {
+		var log bytes.Buffer
+		a := &Approver{
+			Specs:  specs,
+			Policy: &Policy{Allow: []string{"fs_*"}, Deny: []string{"sh_*"}},
+			Yes:    true,
+			Log:    &log,
+		}
+		ok, _ := a.Check("fs_write", nil)
+		assert.True(t, ok)
+		ok, _ = a.Check("sh_exec", nil)
+		assert.False(t, ok)
+		ok, _ = a.Check("other", nil)
+		assert.True(t, ok)
+
+		lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+		require.Len(t, lines, 3)
+		var rec ApprovalRecord
+		require.NoError(t, json.Unmarshal([]byte(lines[1]), &rec))
+		assert.Equal(t, "sh_exec", rec.Tool)
+		assert.False(t, rec.Approved)
+		assert.Equal(t, "policy", rec.Reason)
+	})
+}
+
+func TestConsolePrompt(t *testing.T) {
+	in := strings.NewReader("maybe\nA\ny\n")
+	var out bytes.Buffer
+	prompt := ConsolePrompt(in, &out)
+
+	ans, err := prompt("fs_write", "fs", map[string]any{"path": "/etc"})
+	require.NoError(t, err)
+	assert.Equal(t, AlwaysApprove, ans)
+	assert.Contains(t, out.String(), `Tool 'fs_write' of 'fs' is not read-only and wants to run with:`)
+	assert.Contains(t, out.String(), `{"path":"/etc"}`)
+
+	// the rest of the input is left for the next prompt
+	ans, err = prompt("fs_write", "fs", nil)
+	require.NoError(t, err)
+	assert.Equal(t, Approve, ans)
+
+	_, err = prompt("fs_write", "fs", nil)
+	assert.Error(t, err)
+}
+
+func TestLoadPolicy(t *testing.T) {
+	path := t.TempDir() + "/policy.yaml"
+	require.NoError(t, os.WriteFile(path, []byte("allow: [fs_read]\ndefault: deny\n"), 0644))
+	p, err := LoadPolicy(path)
+	require.NoError(t, err)
+	assert.Equal(t, "allow", p.decide("fs_read"))
+	assert.Equal(t, "deny", p.decide("fs_write"))
+
+	require.NoError(t, os.WriteFile(path, []byte("default: sometimes\n"), 0644))
+	_, err = LoadPolicy(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// clientImplementation is announced to the MCP servers.
+var clientImplementation = &mcp.Implementation{Name: "allmend", Version: "0.0.1"}
+
+// Transport creates the MCP transport for the server configuration.
+func (s Server) Transport() (mcp.Transport, error) {
+	switch s.Type {
+	case "stdio", "":
+		if s.Command == "" {
+			return nil, fmt.Errorf("tool '%s': no command configured", s.Name)
+		}
+		cmd := exec.Command(s.Command, s.Args...)
+		cmd.Env = os.Environ()
+		for k, v := range s.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+		// Diagnostics of the server end up on our stderr
+		cmd.Stderr = os.Stderr
+		return &mcp.CommandTransport{Command: cmd}, nil
+	case "http", "streamable-http":
+		if s.URL == "" {
+			return nil, fmt.Errorf("tool '%s': no url configured", s.Name)
+		}
+		client := http.DefaultClient
+		if len(s.Headers) > 0 {
+			client = &http.Client{Transport: &headerTransport{headers: s.Headers, base: http.DefaultTransport}}
+		}
+		return &mcp.StreamableClientTransport{Endpoint: s.URL, HTTPClient: client}, nil
+	default:
+		return nil, fmt.Errorf("tool '%s': unsupported transport type: %s", s.Name, s.Type)
+	}
+}
+
+// headerTransport adds the configured headers to every request.
+type headerTransport struct {
+	headers map[string]string
+	base    http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// Connection is an initialized session with an MCP server.
+type Connection struct {
+	Server  Server
+	session *mcp.ClientSession
+}
+
+// Connect starts or connects to the MCP server and performs the
+// initialization handshake.
+func (s Server) Connect(ctx context.Context) (*Connection, error) {
+	transport, err := s.Transport()
+	if err != nil {
+		return nil, err
+	}
+	client := mcp.NewClient(clientImplementation, nil)
+	session, err := client.Connect(ctx, transport, nil)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to tool '%s': %w", s.Name, err)
+	}
+	return &Connection{Server: s, session: session}, nil
+}
+
+// Version returns the version the server reported during initialization.
+func (c *Connection) Version() string {
+	if res := c.session.InitializeResult(); res != nil && res.ServerInfo != nil {
+		return res.ServerInfo.Version
+	}
+	return ""
+}
+
+// ListTools returns all tools offered by the server.
+func (c *Connection) ListTools(ctx context.Context) ([]*mcp.Tool, error) {
+	var tools []*mcp.Tool
+	cursor := ""
+	for {
+		resp, err := c.session.ListTools(ctx, &mcp.ListToolsParams{Cursor: cursor})
+		if err != nil {
+			return nil, fmt.Errorf("listing tools of '%s': %w", c.Server.Name, err)
+		}
+		tools = append(tools, resp.Tools...)
+		if resp.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = resp.NextCursor
+	}
+}
+
+// CallTool calls the named tool of the server.
+func (c *Connection) CallTool(ctx context.Context, name string, args map[string]any) (*mcp.CallToolResult, error) {
+	return c.session.CallTool(ctx, &mcp.CallToolParams{Name: name, Arguments: args})
+}
+
+// Close ends the session, stdio servers are terminated.
+func (c *Connection) Close() error {
+	return c.session.Close()
+}
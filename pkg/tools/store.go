@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Server represents the configuration of a single MCP server in the
+// tools registry. The name of the server is the tool name agents refer to.
+type Server struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	// Type of the transport, "stdio" for a subprocess or "http" for
+	// the streamable HTTP transport.
+	Type string `yaml:"type"`
+	// Command and Args start the server for the stdio transport.
+	Command string            `yaml:"command,omitempty"`
+	Args    []string          `yaml:"args,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty"`
+	// URL and Headers are used by the http transport.
+	URL     string            `yaml:"url,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Signature is the path of the detached signature of the server,
+	// see Verify.
+	Signature string `yaml:"signature,omitempty"`
+}
+
+// Store represents a collection of MCP server configurations.
+type Store struct {
+	Items map[string]Server `yaml:",inline"`
+	// Path is the file path where the servers are stored.
+	Path string `yaml:"-"`
+}
+
+// Load reads the tools registry from the specified file.
+func Load(path string) (*Store, error) {
+	store := &Store{
+		Items: make(map[string]Server),
+		Path:  path,
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return store, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tools file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if err := yaml.NewDecoder(f).Decode(&store.Items); err != nil {
+		return nil, fmt.Errorf("failed to decode tools from %s: %w", path, err)
+	}
+
+	// Ensure the name field is set to the key if it's empty
+	for k, v := range store.Items {
+		if v.Name == "" {
+			v.Name = k
+			store.Items[k] = v
+		}
+	}
+
+	return store, nil
+}
+
+// Save writes the tools registry to the specified file.
+func (s *Store) Save() error {
+	if s.Path == "" {
+		return fmt.Errorf("no path specified for tools store")
+	}
+
+	f, err := os.Create(s.Path)
+	if err != nil {
+		return fmt.Errorf("failed to create tools file %s: %w", s.Path, err)
+	}
+	defer f.Close()
+
+	enc := yaml.NewEncoder(f)
+	enc.SetIndent(2)
+	if err := enc.Encode(s.Items); err != nil {
+		return fmt.Errorf("failed to encode tools to %s: %w", s.Path, err)
+	}
+	return nil
+}
+
+// List returns a sorted slice of server configurations.
+func (s *Store) List() []Server {
+	keys := make([]string, 0, len(s.Items))
+	for k := range s.Items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	servers := make([]Server, 0, len(keys))
+	for _, k := range keys {
+		servers = append(servers, s.Items[k])
+	}
+	return servers
+}
@@ -0,0 +1,168 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/SUSE/allmend/pkg/agent"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/adk/tool"
+	"google.golang.org/adk/tool/functiontool"
+)
+
+// Toolbox holds the connected MCP servers of an agent and the ADK tools
+// created from them.
+type Toolbox struct {
+	// Tools are handed to the model.
+	Tools []tool.Tool
+	// Specs maps the name of every ADK tool to the agent declaration of
+	// the MCP server providing it.
+	Specs map[string]*agent.MCPTools
+	// Verified holds the signature verification of every signed MCP
+	// server by its name.
+	Verified map[string]*Verification
+	conns    []*Connection
+}
+
+// Resolve connects to the MCP servers named by the required and recommended
+// tools of the agent and checks their version constraints and, if the agent
+// lists trusted keys for them, their signatures. A required tool which is
+// not in the registry, can't be reached, has the wrong version or a missing
+// or bad signature is an error, recommended tools are skipped with a
+// warning in that case.
+// The caller has to Close the returned Toolbox.
+func Resolve(ctx context.Context, store *Store, tools *agent.AgentTools) (*Toolbox, error) {
+	box := &Toolbox{
+		Specs:    make(map[string]*agent.MCPTools),
+		Verified: make(map[string]*Verification),
+	}
+	if tools == nil {
+		return box, nil
+	}
+	for _, spec := range tools.Required {
+		if err := box.add(ctx, store, spec); err != nil {
+			box.Close()
+			return nil, fmt.Errorf("required tool: %w", err)
+		}
+	}
+	for _, spec := range tools.Recommended {
+		if err := box.add(ctx, store, spec); err != nil {
+			slog.Warn("skipping recommended tool", "tool", spec.Name, "error", err)
+		}
+	}
+	return box, nil
+}
+
+func (box *Toolbox) add(ctx context.Context, store *Store, spec *agent.MCPTools) error {
+	server, ok := store.Items[spec.Name]
+	if !ok {
+		return fmt.Errorf("tool '%s' not found in tools registry %s", spec.Name, store.Path)
+	}
+	constraint, err := agent.ParseConstraint(spec.Version)
+	if err != nil {
+		return fmt.Errorf("tool '%s': %w", spec.Name, err)
+	}
+	// Binaries are verified before they are started
+	var verification *Verification
+	if len(spec.Keys) > 0 && server.isStdio() {
+		if verification, err = VerifyBinary(server, spec.Keys); err != nil {
+			return err
+		}
+	}
+	conn, err := server.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	if spec.Version != "" {
+		ok, err := constraint.CheckString(conn.Version())
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("tool '%s' reports version %q: %w", spec.Name, conn.Version(), err)
+		}
+		if !ok {
+			conn.Close()
+			return fmt.Errorf("tool '%s' has version %s, but %s is required", spec.Name, conn.Version(), spec.Version)
+		}
+	}
+	mcpTools, err := conn.ListTools(ctx)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if len(spec.Keys) > 0 && !server.isStdio() {
+		if verification, err = VerifyManifest(server, spec.Keys, mcpTools); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	var adkTools []tool.Tool
+	for _, t := range mcpTools {
+		if _, dup := box.Specs[t.Name]; dup {
+			conn.Close()
+			return fmt.Errorf("tool '%s' offers '%s' which is already provided by '%s'", spec.Name, t.Name, box.Specs[t.Name].Name)
+		}
+		adkTool, err := newMCPTool(conn, t)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		adkTools = append(adkTools, adkTool)
+	}
+	for _, t := range adkTools {
+		box.Specs[t.Name()] = spec
+	}
+	if verification != nil {
+		box.Verified[spec.Name] = verification
+	}
+	box.Tools = append(box.Tools, adkTools...)
+	box.conns = append(box.conns, conn)
+	slog.Debug("connected tool", "tool", spec.Name, "version", conn.Version(), "functions", len(adkTools))
+	return nil
+}
+
+// Close ends the sessions with all MCP servers.
+func (box *Toolbox) Close() error {
+	var errs []error
+	for _, c := range box.conns {
+		errs = append(errs, c.Close())
+	}
+	box.conns = nil
+	return errors.Join(errs...)
+}
+
+// newMCPTool wraps a tool of an MCP server as ADK function tool.
+func newMCPTool(conn *Connection, t *mcp.Tool) (tool.Tool, error) {
+	return functiontool.New(functiontool.Config{
+		Name:        t.Name,
+		Description: t.Description,
+		InputSchema: t.InputSchema,
+	}, func(ctx tool.Context, args map[string]any) (map[string]any, error) {
+		res, err := conn.CallTool(ctx, t.Name, args)
+		if err != nil {
+			return nil, fmt.Errorf("calling tool '%s': %w", t.Name, err)
+		}
+		text := resultText(res)
+		if res.IsError {
+			return nil, fmt.Errorf("tool '%s' failed: %s", t.Name, text)
+		}
+		if res.StructuredContent != nil {
+			return map[string]any{"output": res.StructuredContent}, nil
+		}
+		return map[string]any{"output": text}, nil
+	})
+}
+
+// resultText concatenates the text contents of a tool result.
+func resultText(res *mcp.CallToolResult) string {
+	var sb strings.Builder
+	for _, c := range res.Content {
+		if t, ok := c.(*mcp.TextContent); ok {
+			sb.WriteString(t.Text)
+		}
+	}
+	return sb.String()
+}
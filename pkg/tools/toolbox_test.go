@@ -0,0 +1,166 @@
+package tools
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/SUSE/allmend/pkg/agent"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serverEnv makes the test binary act as a stdio MCP server.
+const serverEnv = "ALLMEND_TEST_MCP_SERVER"
+
+type echoInput struct {
+	Text string `json:"text" jsonschema:"text to echo"`
+}
+
+type echoOutput struct {
+	Echo string `json:"echo"`
+}
+
+func TestMain(m *testing.M) {
+	if name := os.Getenv(serverEnv); name != "" {
+		runTestServer(name)
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// runTestServer serves a "<name>_echo" and a "<name>_fail" tool on stdio.
+func runTestServer(name string) {
+	server := mcp.NewServer(&mcp.Implementation{Name: name, Version: "1.2.3"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: name + "_echo", Description: "echoes the text"},
+		func(ctx context.Context, req *mcp.CallToolRequest, in echoInput) (*mcp.CallToolResult, echoOutput, error) {
+			return nil, echoOutput{Echo: in.Text}, nil
+		})
+	mcp.AddTool(server, &mcp.Tool{Name: name + "_fail", Description: "always fails"},
+		func(ctx context.Context, req *mcp.CallToolRequest, in echoInput) (*mcp.CallToolResult, any, error) {
+			return &mcp.CallToolResult{
+				IsError: true,
+				Content: []mcp.Content{&mcp.TextContent{Text: "broken on purpose"}},
+			}, nil, nil
+		})
+	if err := server.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// testServer returns a registry entry starting the test binary as MCP server.
+func testServer(name string) Server {
+	return Server{
+		Name:    name,
+		Type:    "stdio",
+		Command: os.Args[0],
+		Env:     map[string]string{serverEnv: name},
+	}
+}
+
+func TestConnection(t *testing.T) {
+	ctx := context.Background()
+	conn, err := testServer("fixture").Connect(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.Equal(t, "1.2.3", conn.Version())
+
+	tools, err := conn.ListTools(ctx)
+	require.NoError(t, err)
+	var names []string
+	for _, tl := range tools {
+		names = append(names, tl.Name)
+	}
+	assert.ElementsMatch(t, []string{"fixture_echo", "fixture_fail"}, names)
+
+	res, err := conn.CallTool(ctx, "fixture_echo", map[string]any{"text": "hello"})
+	require.NoError(t, err)
+	assert.False(t, res.IsError)
+	assert.Equal(t, map[string]any{"echo": "hello"}, res.StructuredContent)
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	store := &Store{Items: map[string]Server{
+		"alpha": testServer("alpha"),
+		"beta":  testServer("beta"),
+	}}
+
+	t.Run("RequiredAndRecommended", func(t *testing.T) {
+		box, err := Resolve(ctx, store, &agent.AgentTools{
+			Required:    []*agent.MCPTools{{Name: "alpha", Version: ">=1.2, <2"}},
+			Recommended: []*agent.MCPTools{{Name: "beta", ReadOnly: true}, {Name: "missing"}},
+		})
+		require.NoError(t, err)
+		defer box.Close()
+
+		assert.Len(t, box.Tools, 4)
+		assert.Equal(t, "alpha", box.Specs["alpha_echo"].Name)
+		assert.True(t, box.Specs["beta_fail"].ReadOnly)
+	})
+
+	t.Run("RequiredMissing", func(t *testing.T) {
+		_, err := Resolve(ctx, store, &agent.AgentTools{
+			Required: []*agent.MCPTools{{Name: "alpha"}, {Name: "missing"}},
+		})
+		assert.ErrorContains(t, err, "tool 'missing' not found in tools registry")
+	})
+
+	t.Run("RequiredWrongVersion", func(t *testing.T) {
+		_, err := Resolve(ctx, store, &agent.AgentTools{
+			Required: []*agent.MCPTools{{Name: "alpha", Version: "^2"}},
+		})
+		assert.ErrorContains(t, err, "tool 'alpha' has version 1.2.3, but ^2 is required")
+	})
+
+	t.Run("RecommendedWrongVersion", func(t *testing.T) {
+		box, err := Resolve(ctx, store, &agent.AgentTools{
+			Required:    []*agent.MCPTools{{Name: "alpha"}},
+			Recommended: []*agent.MCPTools{{Name: "beta", Version: "<1"}},
+		})
+		require.NoError(t, err)
+		defer box.Close()
+		assert.Len(t, box.Tools, 2)
+	})
+
+	t.Run("BrokenCommand", func(t *testing.T) {
+		broken := &Store{Items: map[string]Server{
+			"broken": {Name: "broken", Type: "stdio", Command: "/nonexistent/mcp-server"},
+		}}
+		_, err := Resolve(ctx, broken, &agent.AgentTools{
+			Required: []*agent.MCPTools{{Name: "broken"}},
+		})
+		assert.ErrorContains(t, err, "connecting to tool 'broken'")
+	})
+}
+
+func TestLoadStore(t *testing.T) {
+	path := t.TempDir() + "/tools.conf"
+	content := `fs:
+  type: stdio
+  command: mcp-server-filesystem
+  args: ["/tmp"]
+web:
+  type: http
+  url: http://localhost:8080/mcp
+  headers:
+    Authorization: Bearer secret
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	store, err := Load(path)
+	require.NoError(t, err)
+	servers := store.List()
+	require.Len(t, servers, 2)
+	assert.Equal(t, "fs", servers[0].Name)
+	assert.Equal(t, []string{"/tmp"}, servers[0].Args)
+	assert.Equal(t, "Bearer secret", servers[1].Headers["Authorization"])
+
+	_, err = servers[1].Transport()
+	assert.NoError(t, err)
+	_, err = Server{Name: "odd", Type: "carrier-pigeon"}.Transport()
+	assert.Error(t, err)
+}
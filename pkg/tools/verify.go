@@ -0,0 +1,187 @@
+package tools
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/SUSE/allmend/pkg/agent"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ErrUnsigned is returned when a tool has trusted keys but no signature.
+var ErrUnsigned = errors.New("tool is not signed")
+
+// Verification describes a successfully verified tool.
+type Verification struct {
+	// Artifact is the signed file or "manifest" for HTTP servers.
+	Artifact string
+	// Key is the trusted key which made the signature.
+	Key string
+}
+
+// executable returns the absolute path of the command of a stdio server.
+func (s Server) executable() (string, error) {
+	if s.Command == "" {
+		return "", fmt.Errorf("tool '%s': no command configured", s.Name)
+	}
+	return exec.LookPath(s.Command)
+}
+
+func (s Server) isStdio() bool {
+	return s.Type == "stdio" || s.Type == ""
+}
+
+// readSignature reads the base64 encoded signature from path.
+func readSignature(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: no signature %s", ErrUnsigned, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature %s: %w", path, err)
+	}
+	return sig, nil
+}
+
+// verifySignature checks sig against data with every key and returns the
+// first key which made the signature. Keys of other types than ed25519 and
+// malformed keys are skipped, so that agents can list keys for newer
+// versions, it fails only if no key is usable or none made the signature.
+func verifySignature(keys []string, data, sig []byte) (string, error) {
+	var skipped []string
+	for _, k := range keys {
+		typ, encoded, _ := strings.Cut(k, ":")
+		pub, err := base64.StdEncoding.DecodeString(encoded)
+		if typ != "ed25519" || err != nil || len(pub) != ed25519.PublicKeySize {
+			skipped = append(skipped, fmt.Sprintf("%q", k))
+			continue
+		}
+		if ed25519.Verify(ed25519.PublicKey(pub), data, sig) {
+			return k, nil
+		}
+	}
+	if len(skipped) == len(keys) {
+		return "", fmt.Errorf("no usable trusted key, only ed25519 keys are supported, skipped %s", strings.Join(skipped, ", "))
+	}
+	return "", errors.New("signature doesn't match any trusted key")
+}
+
+// VerifyBinary checks the signature of the executable of a stdio server.
+// Only the executable found for the command is verified, not its arguments.
+// For a server run by an interpreter, like "python3 server.py", the
+// signature thus covers the interpreter and not the script, such servers
+// should be packaged as a single executable to be signed.
+func VerifyBinary(s Server, keys []string) (*Verification, error) {
+	exe, err := s.executable()
+	if err != nil {
+		return nil, fmt.Errorf("tool '%s': %w", s.Name, err)
+	}
+	sigPath := s.Signature
+	if sigPath == "" {
+		sigPath = exe + ".sig"
+	}
+	sig, err := readSignature(sigPath)
+	if err != nil {
+		return nil, fmt.Errorf("tool '%s': %w", s.Name, err)
+	}
+	data, err := os.ReadFile(exe)
+	if err != nil {
+		return nil, fmt.Errorf("tool '%s': %w", s.Name, err)
+	}
+	key, err := verifySignature(keys, data, sig)
+	if err != nil {
+		return nil, fmt.Errorf("tool '%s': %s: %w", s.Name, exe, err)
+	}
+	return &Verification{Artifact: exe, Key: key}, nil
+}
+
+// Manifest returns the canonical JSON description of the tools which is
+// signed for HTTP servers: name, description and input schema of every
+// tool, sorted by name.
+func Manifest(tools []*mcp.Tool) ([]byte, error) {
+	type entry struct {
+		Name        string `json:"name"`
+		Description string `json:"description,omitempty"`
+		InputSchema any    `json:"inputSchema,omitempty"`
+	}
+	entries := make([]entry, 0, len(tools))
+	for _, t := range tools {
+		e := entry{Name: t.Name, Description: t.Description}
+		if t.InputSchema != nil {
+			e.InputSchema = t.InputSchema
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return json.Marshal(entries)
+}
+
+// VerifyManifest checks the signature of the tool manifest of a server.
+func VerifyManifest(s Server, keys []string, tools []*mcp.Tool) (*Verification, error) {
+	if s.Signature == "" {
+		return nil, fmt.Errorf("tool '%s': %w: no signature configured", s.Name, ErrUnsigned)
+	}
+	sig, err := readSignature(s.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("tool '%s': %w", s.Name, err)
+	}
+	data, err := Manifest(tools)
+	if err != nil {
+		return nil, fmt.Errorf("tool '%s': %w", s.Name, err)
+	}
+	key, err := verifySignature(keys, data, sig)
+	if err != nil {
+		return nil, fmt.Errorf("tool '%s': manifest: %w", s.Name, err)
+	}
+	return &Verification{Artifact: "manifest", Key: key}, nil
+}
+
+// Verify checks the signature of the tool declared by spec. Tools without
+// trusted keys don't need a signature and yield a nil Verification.
+//
+// Signatures are detached ed25519 signatures, stored base64 encoded in a
+// file. For stdio servers the executable is signed and the signature is
+// read from the configured signature file or "<executable>.sig", the
+// arguments of the command aren't covered (see VerifyBinary). HTTP
+// servers can't be signed as binary, instead the manifest of their tools
+// (see Manifest) is signed and the signature file has to be configured.
+// Trusted keys are given as "ed25519:<base64 public key>", other keys are
+// skipped.
+//
+// A signature can be created with openssl:
+//
+//	openssl pkeyutl -sign -inkey key.pem -rawin -in server | base64 -w0 > server.sig
+func Verify(ctx context.Context, store *Store, spec *agent.MCPTools) (*Verification, error) {
+	server, ok := store.Items[spec.Name]
+	if !ok {
+		return nil, fmt.Errorf("tool '%s' not found in tools registry %s", spec.Name, store.Path)
+	}
+	if len(spec.Keys) == 0 {
+		return nil, nil
+	}
+	if server.isStdio() {
+		return VerifyBinary(server, spec.Keys)
+	}
+	conn, err := server.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	mcpTools, err := conn.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return VerifyManifest(server, spec.Keys, mcpTools)
+}
@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUSE/allmend/pkg/agent"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKey(t *testing.T) (string, ed25519.PrivateKey) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	return "ed25519:" + base64.StdEncoding.EncodeToString(pub), priv
+}
+
+func writeSignature(t *testing.T, path string, priv ed25519.PrivateKey, data []byte) {
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data))
+	require.NoError(t, os.WriteFile(path, []byte(sig+"\n"), 0644))
+}
+
+func TestVerifyBinary(t *testing.T) {
+	ctx := context.Background()
+	trusted, priv := newKey(t)
+	other, otherPriv := newKey(t)
+
+	binary, err := os.ReadFile(os.Args[0])
+	require.NoError(t, err)
+	dir := t.TempDir()
+	goodSig := filepath.Join(dir, "good.sig")
+	writeSignature(t, goodSig, priv, binary)
+	badSig := filepath.Join(dir, "bad.sig")
+	writeSignature(t, badSig, otherPriv, binary)
+
+	server := func(sig string) *Store {
+		s := testServer("signed")
+		s.Signature = sig
+		return &Store{Items: map[string]Server{"signed": s}}
+	}
+
+	t.Run("Signed", func(t *testing.T) {
+		box, err := Resolve(ctx, server(goodSig), &agent.AgentTools{
+			Required: []*agent.MCPTools{{Name: "signed", Keys: []string{other, trusted}}},
+		})
+		require.NoError(t, err)
+		defer box.Close()
+		require.Contains(t, box.Verified, "signed")
+		assert.Equal(t, trusted, box.Verified["signed"].Key)
+		assert.Len(t, box.Tools, 2)
+	})
+
+	t.Run("WrongKey", func(t *testing.T) {
+		_, err := Resolve(ctx, server(badSig), &agent.AgentTools{
+			Required: []*agent.MCPTools{{Name: "signed", Keys: []string{trusted}}},
+		})
+		assert.ErrorContains(t, err, "signature doesn't match any trusted key")
+	})
+
+	t.Run("Unsigned", func(t *testing.T) {
+		_, err := Verify(ctx, server(filepath.Join(dir, "missing.sig")), &agent.MCPTools{Name: "signed", Keys: []string{trusted}})
+		assert.ErrorIs(t, err, ErrUnsigned)
+	})
+
+	t.Run("NoKeys", func(t *testing.T) {
+		v, err := Verify(ctx, server(""), &agent.MCPTools{Name: "signed"})
+		assert.NoError(t, err)
+		assert.Nil(t, v)
+	})
+
+	t.Run("UnsupportedKey", func(t *testing.T) {
+		_, err := Verify(ctx, server(goodSig), &agent.MCPTools{Name: "signed", Keys: []string{"pgp:ABCDEF"}})
+		assert.ErrorContains(t, err, "only ed25519 keys are supported")
+	})
+
+	t.Run("SkippedKeys", func(t *testing.T) {
+		v, err := Verify(ctx, server(goodSig), &agent.MCPTools{Name: "signed", Keys: []string{"pgp:ABCDEF", "ed25519:short", trusted}})
+		require.NoError(t, err)
+		assert.Equal(t, trusted, v.Key)
+
+		_, err = Verify(ctx, server(badSig), &agent.MCPTools{Name: "signed", Keys: []string{"pgp:ABCDEF", trusted}})
+		assert.ErrorContains(t, err, "signature doesn't match any trusted key")
+	})
+}
+
+func TestVerifyManifest(t *testing.T) {
+	ctx := context.Background()
+	trusted, priv := newKey(t)
+
+	mcpServer := mcp.NewServer(&mcp.Implementation{Name: "remote", Version: "2.0.0"}, nil)
+	mcp.AddTool(mcpServer, &mcp.Tool{Name: "remote_echo", Description: "echoes the text"},
+		func(ctx context.Context, req *mcp.CallToolRequest, in echoInput) (*mcp.CallToolResult, echoOutput, error) {
+			return nil, echoOutput{Echo: in.Text}, nil
+		})
+	httpServer := httptest.NewServer(mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return mcpServer }, nil))
+	defer httpServer.Close()
+
+	remote := Server{Name: "remote", Type: "http", URL: httpServer.URL}
+	conn, err := remote.Connect(ctx)
+	require.NoError(t, err)
+	mcpTools, err := conn.ListTools(ctx)
+	require.NoError(t, err)
+	conn.Close()
+	manifest, err := Manifest(mcpTools)
+	require.NoError(t, err)
+
+	remote.Signature = filepath.Join(t.TempDir(), "remote.sig")
+	writeSignature(t, remote.Signature, priv, manifest)
+	store := &Store{Items: map[string]Server{"remote": remote}}
+	spec := &agent.MCPTools{Name: "remote", Keys: []string{trusted}}
+
+	v, err := Verify(ctx, store, spec)
+	require.NoError(t, err)
+	assert.Equal(t, "manifest", v.Artifact)
+
+	box, err := Resolve(ctx, store, &agent.AgentTools{Required: []*agent.MCPTools{spec}})
+	require.NoError(t, err)
+	assert.Len(t, box.Tools, 1)
+	box.Close()
+
+	// a changed tool no longer matches the signed manifest
+	mcp.AddTool(mcpServer, &mcp.Tool{Name: "remote_sneaky", Description: "new and unsigned"},
+		func(ctx context.Context, req *mcp.CallToolRequest, in echoInput) (*mcp.CallToolResult, echoOutput, error) {
+			return nil, echoOutput{}, nil
+		})
+	_, err = Resolve(ctx, store, &agent.AgentTools{Required: []*agent.MCPTools{spec}})
+	assert.ErrorContains(t, err, "manifest: signature doesn't match any trusted key")
+}